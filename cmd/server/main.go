@@ -6,14 +6,18 @@ import (
 	"fmt"
 	"os"
 	"os/signal"
+	"path/filepath"
+	"strings"
 	"syscall"
 	"time"
 
+	natsclient "github.com/nats-io/nats.go"
 	"k8s.io/client-go/informers"
 	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/rest"
 	"k8s.io/client-go/tools/clientcmd"
 
+	"github.com/portswigger-tim/nats-k8s-oidc-callout/internal/audit"
 	"github.com/portswigger-tim/nats-k8s-oidc-callout/internal/auth"
 	"github.com/portswigger-tim/nats-k8s-oidc-callout/internal/config"
 	"github.com/portswigger-tim/nats-k8s-oidc-callout/internal/httpserver"
@@ -51,10 +55,8 @@ func initJWTValidator(cfg *config.Config, logger *zap.Logger) (*jwt.Validator, e
 	return validator, nil
 }
 
-// initK8sClient initializes the Kubernetes client with config, clientset, and informer factory.
-func initK8sClient(cfg *config.Config, logger *zap.Logger) (*k8s.Client, informers.SharedInformerFactory, chan struct{}, error) {
-	logger.Info("initializing Kubernetes client")
-
+// newK8sClientset creates a Kubernetes clientset from in-cluster or KUBECONFIG configuration.
+func newK8sClientset(cfg *config.Config, logger *zap.Logger) (kubernetes.Interface, error) {
 	// Get Kubernetes config
 	var k8sConfig *rest.Config
 	var err error
@@ -62,7 +64,7 @@ func initK8sClient(cfg *config.Config, logger *zap.Logger) (*k8s.Client, informe
 		logger.Info("using in-cluster Kubernetes config")
 		k8sConfig, err = rest.InClusterConfig()
 		if err != nil {
-			return nil, nil, nil, fmt.Errorf("failed to get in-cluster config: %w", err)
+			return nil, fmt.Errorf("failed to get in-cluster config: %w", err)
 		}
 	} else {
 		logger.Info("using out-of-cluster Kubernetes config from KUBECONFIG")
@@ -71,26 +73,131 @@ func initK8sClient(cfg *config.Config, logger *zap.Logger) (*k8s.Client, informe
 		kubeConfig := clientcmd.NewNonInteractiveDeferredLoadingClientConfig(loadingRules, configOverrides)
 		k8sConfig, err = kubeConfig.ClientConfig()
 		if err != nil {
-			return nil, nil, nil, fmt.Errorf("failed to load kubeconfig: %w", err)
+			return nil, fmt.Errorf("failed to load kubeconfig: %w", err)
 		}
 	}
 
 	// Create clientset
 	clientset, err := kubernetes.NewForConfig(k8sConfig)
 	if err != nil {
-		return nil, nil, nil, fmt.Errorf("failed to create Kubernetes clientset: %w", err)
+		return nil, fmt.Errorf("failed to create Kubernetes clientset: %w", err)
 	}
 
+	return clientset, nil
+}
+
+// initK8sClient initializes the Kubernetes client with informer factory.
+func initK8sClient(cfg *config.Config, clientset kubernetes.Interface, logger *zap.Logger) (*k8s.Client, informers.SharedInformerFactory, chan struct{}) {
+	logger.Info("initializing Kubernetes client")
+
 	// Create informer factory
 	informerFactory := informers.NewSharedInformerFactory(clientset, 0)
 
 	// Create K8s client with ServiceAccount cache
-	k8sClient := k8s.NewClient(informerFactory, logger)
+	k8sClient := k8s.NewClientWithOptions(informerFactory, logger, k8s.Options{
+		PublishTemplates:   cfg.DefaultPubSubjects,
+		SubscribeTemplates: cfg.DefaultSubSubjects,
+		Cluster:            cfg.ClusterName,
+		StrictInbox:        cfg.StrictInbox,
+		DisableResponses:   !cfg.AllowResponses,
+		ResponseMaxMsgs:    cfg.ResponseMaxMsgs,
+		ResponseTTL:        cfg.ResponseTTL,
+		SourceCIDRs:        cfg.AllowedSourceCIDRs,
+		ConnectionTypes:    cfg.AllowedConnectionTypes,
+		JetStreamDomain:    cfg.JetStreamDomain,
+		PodPermissions:     cfg.PodPermissionsMode,
+		Shadow:             cfg.ShadowMode,
+		ShadowNamespaces:   cfg.ShadowNamespaces,
+	})
 
 	// Create stop channel for lifecycle management
 	stopCh := make(chan struct{})
 
-	return k8sClient, informerFactory, stopCh, nil
+	return k8sClient, informerFactory, stopCh
+}
+
+// initSubjectRegistry watches the subject registry ConfigMap when one is configured.
+// Returns a factory scoped to the ConfigMap's namespace, or nil if the registry is disabled.
+func initSubjectRegistry(cfg *config.Config, clientset kubernetes.Interface, k8sClient *k8s.Client, logger *zap.Logger) (informers.SharedInformerFactory, error) {
+	if cfg.SubjectRegistryConfigMap == "" {
+		return nil, nil
+	}
+
+	namespace, name, _ := strings.Cut(cfg.SubjectRegistryConfigMap, "/")
+	logger.Info("watching subject registry ConfigMap",
+		zap.String("namespace", namespace),
+		zap.String("name", name),
+		zap.String("key", cfg.SubjectRegistryKey))
+
+	factory := informers.NewSharedInformerFactoryWithOptions(clientset, 0, informers.WithNamespace(namespace))
+	if err := k8sClient.WatchSubjectRegistry(factory, name, cfg.SubjectRegistryKey); err != nil {
+		return nil, fmt.Errorf("failed to watch subject registry: %w", err)
+	}
+
+	return factory, nil
+}
+
+// initLockdown watches the lockdown ConfigMap when one is configured, applying every change to
+// the authorization handler. Returns a factory scoped to the ConfigMap's namespace, or nil if
+// lockdown is not ConfigMap-controlled.
+func initLockdown(cfg *config.Config, clientset kubernetes.Interface, k8sClient *k8s.Client, authHandler *auth.Handler, logger *zap.Logger) (informers.SharedInformerFactory, error) {
+	if cfg.LockdownConfigMap == "" {
+		return nil, nil
+	}
+
+	namespace, name, _ := strings.Cut(cfg.LockdownConfigMap, "/")
+	logger.Info("watching lockdown ConfigMap",
+		zap.String("namespace", namespace),
+		zap.String("name", name),
+		zap.String("key", cfg.LockdownKey))
+
+	factory := informers.NewSharedInformerFactoryWithOptions(clientset, 0, informers.WithNamespace(namespace))
+	if err := k8sClient.WatchLockdown(factory, name, cfg.LockdownKey, authHandler.SetLockdown); err != nil {
+		return nil, fmt.Errorf("failed to watch lockdown: %w", err)
+	}
+
+	return factory, nil
+}
+
+// initRevocationList watches the revocation list ConfigMap when one is configured, applying every
+// change to the authorization handler. Returns a factory scoped to the ConfigMap's namespace, or nil
+// if no revocation list is configured.
+func initRevocationList(cfg *config.Config, clientset kubernetes.Interface, k8sClient *k8s.Client, authHandler *auth.Handler, logger *zap.Logger) (informers.SharedInformerFactory, error) {
+	if cfg.RevocationConfigMap == "" {
+		return nil, nil
+	}
+
+	namespace, name, _ := strings.Cut(cfg.RevocationConfigMap, "/")
+	logger.Info("watching revocation list ConfigMap",
+		zap.String("namespace", namespace),
+		zap.String("name", name),
+		zap.String("key", cfg.RevocationKey))
+
+	// Resync periodically so entries are pruned as they expire or their pods are deleted
+	factory := informers.NewSharedInformerFactoryWithOptions(clientset, k8s.RevocationPruneInterval, informers.WithNamespace(namespace))
+	if err := k8sClient.WatchRevocationList(factory, name, cfg.RevocationKey, authHandler.SetRevocationList); err != nil {
+		return nil, fmt.Errorf("failed to watch revocation list: %w", err)
+	}
+
+	return factory, nil
+}
+
+// loadAdminToken reads the bearer token protecting the admin endpoints
+func loadAdminToken(path string) (string, error) {
+	if cleanPath := filepath.Clean(path); cleanPath != path {
+		return "", fmt.Errorf("invalid admin token file path: potential path traversal attempt")
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", fmt.Errorf("failed to read admin token file: %w", err)
+	}
+	token := strings.TrimSpace(string(data))
+	if token == "" {
+		return "", fmt.Errorf("admin token file %s is empty", path)
+	}
+
+	return token, nil
 }
 
 // startK8sInformers starts the informer factory and waits for caches to sync.
@@ -136,9 +243,46 @@ func initNATSClient(cfg *config.Config, authHandler *auth.Handler, logger *zap.L
 	return natsClient, nil
 }
 
+// initSystemConn connects to the NATS system account when credentials are configured.
+// Returns nil if no credentials are set.
+func initSystemConn(cfg *config.Config, logger *zap.Logger) (*natsclient.Conn, error) {
+	if cfg.NatsSystemCredsFile == "" {
+		logger.Info("no system account credentials; clients of disabled ServiceAccounts stay connected until their user JWT expires")
+		return nil, nil
+	}
+
+	logger.Info("connecting to NATS system account", zap.String("system_creds_file", cfg.NatsSystemCredsFile))
+	conn, err := nats.ConnectSystemAccount(cfg.NatsURL, cfg.NatsSystemCredsFile)
+	if err != nil {
+		return nil, err
+	}
+	return conn, nil
+}
+
+// initDisconnector registers a disconnector for clients of disabled ServiceAccounts, and of
+// ServiceAccounts that lose permissions or are deleted when enabled.
+func initDisconnector(cfg *config.Config, systemConn *natsclient.Conn, k8sClient *k8s.Client, tracker *nats.ConnectionTracker, logger *zap.Logger) {
+	disconnector := nats.NewDisconnector(systemConn, cfg.NatsAccount, logger)
+	disconnector.SetRateLimit(cfg.DisconnectRateLimit)
+
+	if !cfg.DisconnectOnPermissionChange {
+		k8sClient.OnServiceAccountRevoked(func(namespace, name, reason string) {
+			if reason == k8s.RevokeDisabled {
+				disconnector.HandleRevoke(namespace, name, reason)
+			}
+		})
+		return
+	}
+
+	logger.Info("disconnecting clients when ServiceAccount permissions are reduced or deleted",
+		zap.Int("rate_limit", cfg.DisconnectRateLimit))
+	disconnector.SetConnectionTracker(tracker)
+	k8sClient.OnServiceAccountRevoked(disconnector.HandleRevoke)
+}
+
 // waitForShutdown starts the HTTP server and waits for shutdown signal or server error.
 // Coordinates graceful shutdown of all services with timeout.
-func waitForShutdown(httpSrv *httpserver.Server, natsClient *nats.Client, logger *zap.Logger) error {
+func waitForShutdown(httpSrv *httpserver.Server, natsClient *nats.Client, auditPublisher *audit.Publisher, logger *zap.Logger) error {
 	// Start HTTP server in a goroutine
 	serverErrors := make(chan error, 1)
 	go func() {
@@ -161,7 +305,14 @@ func waitForShutdown(httpSrv *httpserver.Server, natsClient *nats.Client, logger
 		ctx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
 		defer cancel()
 
-		// Shutdown in reverse order (NATS first, then HTTP)
+		// Shutdown in reverse order (NATS first, then HTTP). The audit publisher drains its buffer
+		// after the last auth request is answered and before the connection it publishes on closes.
+		if auditPublisher != nil {
+			natsClient.StopService()
+			logger.Info("publishing buffered audit events")
+			auditPublisher.Stop()
+		}
+
 		logger.Info("shutting down NATS client")
 		if err := natsClient.Shutdown(ctx); err != nil {
 			logger.Error("failed to shutdown NATS client", zap.Error(err))
@@ -212,17 +363,74 @@ func run() error {
 	}
 
 	// Initialize Kubernetes client
-	k8sClient, informerFactory, stopCh, err := initK8sClient(cfg, logger)
+	clientset, err := newK8sClientset(cfg, logger)
 	if err != nil {
 		return err
 	}
+	k8sClient, informerFactory, stopCh := initK8sClient(cfg, clientset, logger)
 	defer close(stopCh)
 
+	// Watch pods for pod-level permissions; must be registered before the informers start
+	if cfg.PodPermissionsMode != k8s.PodPermissionsOff {
+		logger.Info("watching pods for pod-level permissions", zap.String("mode", cfg.PodPermissionsMode))
+		if err := k8sClient.WatchPods(informerFactory); err != nil {
+			return err
+		}
+	}
+
+	// Watch nodes for node selector checks; must be registered before the informers start
+	if cfg.WatchNodes {
+		logger.Info("watching nodes for node selector checks")
+		if err := k8sClient.WatchNodes(informerFactory); err != nil {
+			return err
+		}
+	}
+
 	// Start informers and wait for cache sync
 	startK8sInformers(informerFactory, stopCh, logger)
 
+	// Watch the subject registry after ServiceAccounts are synced so updates re-evaluate every SA
+	registryFactory, err := initSubjectRegistry(cfg, clientset, k8sClient, logger)
+	if err != nil {
+		return err
+	}
+	if registryFactory != nil {
+		startK8sInformers(registryFactory, stopCh, logger)
+	}
+
 	// Initialize authorization handler
-	authHandler := auth.NewHandler(jwtValidator, k8sClient)
+	authHandler := auth.NewHandler(jwtValidator, k8sClient, logger)
+	if cfg.RateLimitPerSource > 0 || cfg.RateLimitPerServiceAccount > 0 || cfg.BlockAfterFailures > 0 {
+		authHandler.SetRateLimiter(auth.NewRateLimiter(auth.RateLimits{
+			PerSource:          cfg.RateLimitPerSource,
+			PerServiceAccount:  cfg.RateLimitPerServiceAccount,
+			BlockAfterFailures: cfg.BlockAfterFailures,
+			BlockDuration:      cfg.BlockDuration,
+		}, logger))
+		logger.Info("rate limiting auth requests",
+			zap.Int("per_source", cfg.RateLimitPerSource),
+			zap.Int("per_serviceaccount", cfg.RateLimitPerServiceAccount),
+			zap.Int("block_after_failures", cfg.BlockAfterFailures),
+			zap.Duration("block_duration", cfg.BlockDuration))
+	}
+
+	// Apply the lockdown state from its ConfigMap before the callout starts serving
+	lockdownFactory, err := initLockdown(cfg, clientset, k8sClient, authHandler, logger)
+	if err != nil {
+		return err
+	}
+	if lockdownFactory != nil {
+		startK8sInformers(lockdownFactory, stopCh, logger)
+	}
+
+	// Load revoked tokens before the callout starts serving
+	revocationFactory, err := initRevocationList(cfg, clientset, k8sClient, authHandler, logger)
+	if err != nil {
+		return err
+	}
+	if revocationFactory != nil {
+		startK8sInformers(revocationFactory, stopCh, logger)
+	}
 
 	// Initialize NATS client with signing key
 	natsClient, err := initNATSClient(cfg, authHandler, logger)
@@ -230,19 +438,95 @@ func run() error {
 		return err
 	}
 
+	// Track authorized connections so they can be disconnected when permissions change
+	var tracker *nats.ConnectionTracker
+	if cfg.DisconnectOnPermissionChange {
+		tracker = nats.NewConnectionTracker()
+		natsClient.SetConnectionTracker(tracker)
+	}
+
+	// Publish an audit event for every authorization decision
+	var auditPublisher *audit.Publisher
+	if cfg.AuditSubject != "" {
+		auditPublisher = audit.NewPublisher(cfg.AuditSubject, cfg.AuditStream, cfg.AuditBufferSize, logger)
+		natsClient.AddAuditRecorder(auditPublisher)
+	}
+
+	// Write an audit log line for every authorization decision, separate from the operational log
+	if cfg.AuditLog != "" {
+		auditLogger, err := audit.NewLogger(cfg.AuditLog, int64(cfg.AuditLogMaxSizeMB)<<20, cfg.AuditLogMaxBackups, cfg.AuditBufferSize, logger)
+		if err != nil {
+			return fmt.Errorf("failed to open audit log: %w", err)
+		}
+		defer func() {
+			if err := auditLogger.Close(); err != nil {
+				logger.Error("failed to close audit log", zap.Error(err))
+			}
+		}()
+		natsClient.AddAuditRecorder(auditLogger)
+		logger.Info("writing audit log", zap.String("audit_log", cfg.AuditLog))
+	}
+
 	// Start NATS auth callout service
 	ctx := context.Background()
 	if err := natsClient.Start(ctx); err != nil {
 		return fmt.Errorf("failed to start NATS client: %w", err)
 	}
 
+	if auditPublisher != nil {
+		if err := auditPublisher.Start(natsClient.Conn()); err != nil {
+			return fmt.Errorf("failed to start audit publisher: %w", err)
+		}
+		logger.Info("publishing audit events",
+			zap.String("subject", cfg.AuditSubject),
+			zap.String("stream", cfg.AuditStream))
+	}
+
 	logger.Info("NATS auth callout service started successfully")
 
 	// Initialize HTTP server
 	httpSrv := httpserver.New(cfg.Port, logger)
 
+	// Admin endpoint to toggle lockdown mode at runtime
+	var adminToken string
+	if cfg.AdminTokenFile != "" {
+		adminToken, err = loadAdminToken(cfg.AdminTokenFile)
+		if err != nil {
+			return err
+		}
+		httpSrv.Handle("/admin/lockdown", auth.NewLockdownAPI(authHandler, adminToken, logger))
+		logger.Info("admin lockdown endpoint enabled", zap.String("path", "/admin/lockdown"))
+	}
+
+	systemConn, err := initSystemConn(cfg, logger)
+	if err != nil {
+		return err
+	}
+	if systemConn != nil {
+		defer systemConn.Close()
+
+		// Disconnect clients of ServiceAccounts that become disabled or lose permissions
+		initDisconnector(cfg, systemConn, k8sClient, tracker, logger)
+
+		// Keep an inventory of live connections from the system account's connection events
+		if cfg.ConnectionInventory {
+			inventory := nats.NewInventory(logger)
+			if err := inventory.Start(systemConn, cfg.NatsAccount); err != nil {
+				return fmt.Errorf("failed to start connection inventory: %w", err)
+			}
+			defer inventory.Stop()
+
+			// The inventory lists every client's identity and address, so it is only served to admins
+			if adminToken != "" {
+				httpSrv.Handle("/connections", auth.RequireAdminToken(adminToken, inventory, logger))
+			} else {
+				logger.Warn("connection inventory endpoint disabled: set ADMIN_TOKEN_FILE to serve /connections")
+			}
+		}
+	}
+
 	// Wait for shutdown signal and coordinate graceful shutdown
-	return waitForShutdown(httpSrv, natsClient, logger)
+	return waitForShutdown(httpSrv, natsClient, auditPublisher, logger)
 }
 
 // initLogger creates a zap logger based on the specified log level.
@@ -92,6 +92,29 @@ Get the name of the NATS signing key secret
 {{- end }}
 {{- end }}
 
+{{/*
+Whether the pod permissions mode needs the pod informer (merge or narrow)
+*/}}
+{{- define "nats-k8s-oidc-callout.watchPods" -}}
+{{- if has (toString .Values.podPermissions.mode) (list "merge" "narrow") }}true{{- end }}
+{{- end }}
+
+{{/*
+Namespaces of the ConfigMaps watched by the callout, keyed by feature, as YAML
+*/}}
+{{- define "nats-k8s-oidc-callout.configMapNamespaces" -}}
+{{- $watches := dict "registry" .Values.subjectRegistry.configMap "lockdown" .Values.lockdown.configMap "revocations" .Values.revocationList.configMap }}
+{{- range $feature, $configMap := $watches }}
+{{- if $configMap }}
+{{- $parts := splitList "/" $configMap }}
+{{- if or (ne (len $parts) 2) (not (first $parts)) (not (last $parts)) }}
+{{- fail (printf "%s ConfigMap must be in namespace/name form, got %q" $feature $configMap) }}
+{{- end }}
+{{ $feature }}: {{ first $parts | quote }}
+{{- end }}
+{{- end }}
+{{- end }}
+
 {{/*
 Get the key in the NATS signing key secret
 */}}
@@ -0,0 +1,34 @@
+// Package audit records authorization decisions outside the application logs.
+package audit
+
+import "time"
+
+// Results of an authorization decision
+const (
+	ResultAllowed = "allowed"
+	ResultDenied  = "denied"
+)
+
+// Event describes a single authorization decision. Identity fields are empty when the client's
+// token could not be validated.
+type Event struct {
+	Timestamp        time.Time `json:"timestamp"`
+	Namespace        string    `json:"namespace,omitempty"`
+	ServiceAccount   string    `json:"serviceaccount,omitempty"`
+	Pod              string    `json:"pod,omitempty"`
+	ClientIP         string    `json:"client_ip,omitempty"`
+	ConnectionType   string    `json:"connection_type,omitempty"`
+	ServerID         string    `json:"server_id,omitempty"`
+	Result           string    `json:"result"`
+	Reason           string    `json:"reason,omitempty"` // Denial reason
+	Detail           string    `json:"detail,omitempty"` // Denial context, e.g. the lockdown reason
+	PublishGranted   []string  `json:"publish_granted,omitempty"`
+	SubscribeGranted []string  `json:"subscribe_granted,omitempty"`
+	TokenID          string    `json:"jti,omitempty"`
+	Token            string    `json:"-"` // Presented token; sinks must redact it with logging.RedactJWT
+}
+
+// Recorder receives an event for every authorization decision. Implementations must not block.
+type Recorder interface {
+	Record(event Event)
+}
@@ -0,0 +1,109 @@
+package audit
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"go.uber.org/zap"
+
+	"github.com/portswigger-tim/nats-k8s-oidc-callout/internal/logging"
+)
+
+const (
+	// LogSchemaVersion is the version of the audit log line format, bumped on incompatible changes
+	LogSchemaVersion = 1
+
+	// SinkLog labels dropped events of the audit logger
+	SinkLog = "log"
+
+	// LogStdout writes the audit log to standard output instead of a file
+	LogStdout = "stdout"
+
+	// minPreviewTokenLength is the shortest token written as a logging.RedactJWT preview, which
+	// keeps 16 characters. Shorter tokens, which cannot be service account JWTs, are written as a digest.
+	minPreviewTokenLength = 64
+)
+
+// logEntry is a single audit log line
+type logEntry struct {
+	SchemaVersion int `json:"schema_version"`
+	Event
+	Token string `json:"token,omitempty"` // Always redacted, see redactToken
+}
+
+// Logger writes one JSON line per audit event to standard output or a size-rotated file. It is
+// independent of the operational logger, so audit lines can be shipped without application logs.
+// Events are written in the background from a bounded buffer like the Publisher.
+type Logger struct {
+	out   io.Writer
+	close func() error
+	queue *queue
+}
+
+// NewLogger creates an audit logger writing to path, or to standard output when path is
+// LogStdout. Files are rotated once they would exceed maxSize bytes, keeping maxBackups
+// previous files.
+func NewLogger(path string, maxSize int64, maxBackups, bufferSize int, logger *zap.Logger) (*Logger, error) {
+	l := &Logger{
+		out:   os.Stdout,
+		close: func() error { return nil },
+		queue: newQueue(SinkLog, DropWriteFailed, bufferSize, logger),
+	}
+
+	if path != LogStdout {
+		if cleanPath := filepath.Clean(path); cleanPath != path {
+			return nil, fmt.Errorf("invalid audit log path: potential path traversal attempt")
+		}
+		file, err := openRotatingFile(path, maxSize, maxBackups)
+		if err != nil {
+			return nil, err
+		}
+		l.out = file
+		l.close = file.Close
+	}
+
+	l.queue.start(l.write)
+	return l, nil
+}
+
+// Record queues an event without blocking, dropping it when the buffer is full
+func (l *Logger) Record(event Event) {
+	l.queue.record(event)
+}
+
+// write writes an event as a single JSON line with its token redacted
+func (l *Logger) write(event Event) error {
+	entry := logEntry{SchemaVersion: LogSchemaVersion, Event: event}
+	if event.Token != "" {
+		entry.Token = redactToken(event.Token)
+	}
+
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	_, err = l.out.Write(append(data, '\n'))
+	return err
+}
+
+// redactToken redacts a presented token for the audit log. Tokens too short for a preview to
+// hide most of them are replaced by a truncated SHA-256 digest, which still lets repeated
+// attempts with the same token be correlated.
+func redactToken(token string) string {
+	if len(token) < minPreviewTokenLength {
+		sum := sha256.Sum256([]byte(token))
+		return "sha256:" + hex.EncodeToString(sum[:8])
+	}
+	return logging.RedactJWT(token)
+}
+
+// Close writes the events already buffered and closes the log file
+func (l *Logger) Close() error {
+	l.queue.shutdown()
+	return l.close()
+}
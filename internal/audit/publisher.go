@@ -0,0 +1,83 @@
+package audit
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	natsclient "github.com/nats-io/nats.go"
+	"github.com/nats-io/nats.go/jetstream"
+	"go.uber.org/zap"
+)
+
+const (
+	// SinkNATS labels dropped events of the NATS publisher
+	SinkNATS = "nats"
+
+	// publishTimeout bounds waiting for a JetStream acknowledgement
+	publishTimeout = 2 * time.Second
+)
+
+// Publisher publishes audit events as JSON messages to a NATS subject, or to a JetStream stream
+// with acknowledgements. Events are queued in a bounded buffer and published in the background,
+// so a slow or unavailable destination never delays authorization; events that do not fit are
+// dropped and counted.
+type Publisher struct {
+	subject string
+	stream  string // Expected JetStream stream; empty publishes core NATS messages
+	queue   *queue
+}
+
+// NewPublisher creates a publisher for a subject. With a stream name, events are published
+// through JetStream and must be captured by that stream.
+func NewPublisher(subject, stream string, bufferSize int, logger *zap.Logger) *Publisher {
+	return &Publisher{
+		subject: subject,
+		stream:  stream,
+		queue:   newQueue(SinkNATS, DropPublishFailed, bufferSize, logger),
+	}
+}
+
+// Record queues an event without blocking, dropping it when the buffer is full
+func (p *Publisher) Record(event Event) {
+	p.queue.record(event)
+}
+
+// Start publishes queued events on a NATS connection until Stop is called
+func (p *Publisher) Start(conn *natsclient.Conn) error {
+	if p.stream == "" {
+		p.start(func(data []byte) error {
+			return conn.Publish(p.subject, data)
+		})
+		return nil
+	}
+
+	js, err := jetstream.New(conn)
+	if err != nil {
+		return fmt.Errorf("failed to create JetStream context: %w", err)
+	}
+	p.start(func(data []byte) error {
+		ctx, cancel := context.WithTimeout(context.Background(), publishTimeout)
+		defer cancel()
+		_, err := js.Publish(ctx, p.subject, data, jetstream.WithExpectStream(p.stream))
+		return err
+	})
+	return nil
+}
+
+// start publishes queued events with the given publish function
+func (p *Publisher) start(publish func(data []byte) error) {
+	p.queue.start(func(event Event) error {
+		data, err := json.Marshal(event)
+		if err != nil {
+			return err
+		}
+		return publish(data)
+	})
+}
+
+// Stop publishes the events already buffered and stops publishing
+func (p *Publisher) Stop() {
+	p.queue.shutdown()
+}
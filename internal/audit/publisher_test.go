@@ -0,0 +1,83 @@
+package audit
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// TestPublisher_Publish tests publishing queued events as JSON
+func TestPublisher_Publish(t *testing.T) {
+	published := make(chan []byte, 10)
+	p := NewPublisher("audit.nats.auth", "", 10, zap.NewNop())
+	p.start(func(data []byte) error {
+		published <- data
+		return nil
+	})
+	defer p.Stop()
+
+	p.Record(Event{
+		Timestamp:      time.Date(2026, 7, 1, 12, 0, 0, 0, time.UTC),
+		Namespace:      "shop",
+		ServiceAccount: "api",
+		Result:         ResultDenied,
+		Reason:         "sa_disabled",
+		TokenID:        "jti-1",
+	})
+
+	select {
+	case data := <-published:
+		var got map[string]any
+		if err := json.Unmarshal(data, &got); err != nil {
+			t.Fatalf("Invalid event JSON: %v", err)
+		}
+		want := map[string]any{
+			"timestamp":      "2026-07-01T12:00:00Z",
+			"namespace":      "shop",
+			"serviceaccount": "api",
+			"result":         "denied",
+			"reason":         "sa_disabled",
+			"jti":            "jti-1",
+		}
+		if len(got) != len(want) {
+			t.Errorf("Event = %v, want %v", got, want)
+		}
+		for key, value := range want {
+			if got[key] != value {
+				t.Errorf("Event[%q] = %v, want %v", key, got[key], value)
+			}
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Expected event to be published")
+	}
+}
+
+// TestPublisher_BufferFull tests that recording never blocks when the destination is stuck
+func TestPublisher_BufferFull(t *testing.T) {
+	release := make(chan struct{})
+	p := NewPublisher("audit.nats.auth", "", 2, zap.NewNop())
+	p.start(func(data []byte) error {
+		<-release
+		return errors.New("unavailable")
+	})
+
+	recorded := make(chan struct{})
+	go func() {
+		for range 10 {
+			p.Record(Event{Result: ResultAllowed})
+		}
+		close(recorded)
+	}()
+
+	select {
+	case <-recorded:
+	case <-time.After(time.Second):
+		t.Fatal("Record blocked on a full buffer")
+	}
+
+	close(release)
+	p.Stop()
+}
@@ -0,0 +1,93 @@
+package audit
+
+import (
+	"go.uber.org/zap"
+
+	httpmetrics "github.com/portswigger-tim/nats-k8s-oidc-callout/internal/httpserver"
+)
+
+const (
+	// DefaultBufferSize is the default number of events buffered before new events are dropped
+	DefaultBufferSize = 1024
+
+	// Reasons an event is dropped
+	DropBufferFull    = "buffer_full"
+	DropPublishFailed = "publish_failed"
+	DropWriteFailed   = "write_failed"
+)
+
+// queue buffers events and writes them from a background goroutine, so a slow sink never delays
+// the caller. Events that do not fit in the buffer are dropped and counted.
+type queue struct {
+	sink       string // Sink label for the dropped events metric
+	failReason string // Drop reason recorded when writing an event fails
+	events     chan Event
+	stop       chan struct{}
+	done       chan struct{}
+	write      func(event Event) error
+	logger     *zap.Logger
+}
+
+// newQueue creates a queue holding up to bufferSize events
+func newQueue(sink, failReason string, bufferSize int, logger *zap.Logger) *queue {
+	return &queue{
+		sink:       sink,
+		failReason: failReason,
+		events:     make(chan Event, bufferSize),
+		stop:       make(chan struct{}),
+		done:       make(chan struct{}),
+		logger:     logger,
+	}
+}
+
+// record queues an event without blocking, dropping it when the buffer is full
+func (q *queue) record(event Event) {
+	select {
+	case q.events <- event:
+	default:
+		httpmetrics.IncrementAuditEventsDropped(q.sink, DropBufferFull)
+	}
+}
+
+// start writes queued events with write until stopped
+func (q *queue) start(write func(event Event) error) {
+	q.write = write
+	go q.run()
+}
+
+// shutdown stops the queue once the events already buffered are written
+func (q *queue) shutdown() {
+	close(q.stop)
+	<-q.done
+}
+
+// run writes events until stopped, then drains the buffer
+func (q *queue) run() {
+	defer close(q.done)
+
+	for {
+		select {
+		case event := <-q.events:
+			q.writeEvent(event)
+		case <-q.stop:
+			for {
+				select {
+				case event := <-q.events:
+					q.writeEvent(event)
+				default:
+					return
+				}
+			}
+		}
+	}
+}
+
+// writeEvent writes a single event, counting it as dropped on failure
+func (q *queue) writeEvent(event Event) {
+	if err := q.write(event); err != nil {
+		httpmetrics.IncrementAuditEventsDropped(q.sink, q.failReason)
+		q.logger.Warn("failed to write audit event",
+			zap.String("sink", q.sink),
+			zap.Error(err))
+	}
+}
@@ -0,0 +1,110 @@
+package audit
+
+import (
+	"errors"
+	"fmt"
+	"os"
+)
+
+// rotatingFile is a file writer that rotates the file once it would exceed maxSize bytes,
+// keeping up to maxBackups previous files named <path>.1 (newest) to <path>.<maxBackups>.
+// Writes are not split across files, so each write should be a complete line.
+type rotatingFile struct {
+	path       string
+	maxSize    int64
+	maxBackups int
+	file       *os.File
+	size       int64
+}
+
+// openRotatingFile opens path for appending, creating it if needed
+func openRotatingFile(path string, maxSize int64, maxBackups int) (*rotatingFile, error) {
+	f := &rotatingFile{path: path, maxSize: maxSize, maxBackups: maxBackups}
+	if err := f.open(); err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+// open opens the current file and records its size
+func (f *rotatingFile) open() error {
+	file, err := os.OpenFile(f.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o640)
+	if err != nil {
+		return fmt.Errorf("failed to open audit log: %w", err)
+	}
+	info, err := file.Stat()
+	if err != nil {
+		_ = file.Close()
+		return fmt.Errorf("failed to stat audit log: %w", err)
+	}
+	f.file = file
+	f.size = info.Size()
+	return nil
+}
+
+// Write appends p, rotating first when the file is not empty and p would exceed the maximum size
+func (f *rotatingFile) Write(p []byte) (int, error) {
+	if f.size > 0 && f.size+int64(len(p)) > f.maxSize {
+		if err := f.rotate(); err != nil {
+			return 0, err
+		}
+	}
+
+	n, err := f.file.Write(p)
+	f.size += int64(n)
+	return n, err
+}
+
+// rotate shifts the backups, moves the current file to <path>.1 and opens a new file.
+// The oldest backup is removed once there are maxBackups. When rotation fails, the current
+// file is reopened so later writes can retry instead of failing on a closed file.
+func (f *rotatingFile) rotate() error {
+	err := f.file.Close()
+	if err != nil {
+		err = fmt.Errorf("failed to close audit log: %w", err)
+	} else {
+		err = f.shift()
+	}
+	if err != nil {
+		if openErr := f.open(); openErr != nil {
+			return errors.Join(err, openErr)
+		}
+		return err
+	}
+
+	return f.open()
+}
+
+// shift moves the closed current file and its backups along by one
+func (f *rotatingFile) shift() error {
+	if f.maxBackups == 0 {
+		if err := os.Remove(f.path); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("failed to remove audit log: %w", err)
+		}
+		return nil
+	}
+
+	if err := os.Remove(f.backupPath(f.maxBackups)); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to remove oldest audit log: %w", err)
+	}
+	for i := f.maxBackups - 1; i >= 1; i-- {
+		if err := os.Rename(f.backupPath(i), f.backupPath(i+1)); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("failed to rotate audit log: %w", err)
+		}
+	}
+	if err := os.Rename(f.path, f.backupPath(1)); err != nil {
+		return fmt.Errorf("failed to rotate audit log: %w", err)
+	}
+
+	return nil
+}
+
+// backupPath returns the path of the n-th most recent backup
+func (f *rotatingFile) backupPath(n int) string {
+	return fmt.Sprintf("%s.%d", f.path, n)
+}
+
+// Close closes the current file
+func (f *rotatingFile) Close() error {
+	return f.file.Close()
+}
@@ -0,0 +1,32 @@
+package auth
+
+import (
+	"crypto/subtle"
+	"net/http"
+	"strings"
+
+	"go.uber.org/zap"
+)
+
+// RequireAdminToken wraps an admin endpoint so only requests carrying the admin token as a bearer
+// token reach it. Other requests are rejected with 401.
+func RequireAdminToken(token string, next http.Handler, logger *zap.Logger) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !validAdminToken(r, []byte(token)) {
+			logger.Warn("rejected unauthenticated admin request",
+				zap.String("path", r.URL.Path),
+				zap.String("method", r.Method),
+				zap.String("remote_addr", r.RemoteAddr))
+			w.Header().Set("WWW-Authenticate", "Bearer")
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// validAdminToken checks the request's bearer token against the admin token in constant time
+func validAdminToken(r *http.Request, token []byte) bool {
+	presented, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	return ok && len(token) > 0 && subtle.ConstantTimeCompare([]byte(presented), token) == 1
+}
@@ -0,0 +1,47 @@
+package auth
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"go.uber.org/zap"
+)
+
+// TestRequireAdminToken tests that admin endpoints only serve requests with the admin token
+func TestRequireAdminToken(t *testing.T) {
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+
+	tests := []struct {
+		name          string
+		token         string
+		authorization string
+		wantStatus    int
+	}{
+		{name: "Valid token", token: "s3cret", authorization: "Bearer s3cret", wantStatus: http.StatusOK},
+		{name: "Missing token", token: "s3cret", authorization: "", wantStatus: http.StatusUnauthorized},
+		{name: "Wrong token", token: "s3cret", authorization: "Bearer wrong", wantStatus: http.StatusUnauthorized},
+		{name: "Not a bearer token", token: "s3cret", authorization: "Basic s3cret", wantStatus: http.StatusUnauthorized},
+		{name: "Empty admin token", token: "", authorization: "Bearer ", wantStatus: http.StatusUnauthorized},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/connections", nil)
+			if tt.authorization != "" {
+				req.Header.Set("Authorization", tt.authorization)
+			}
+			rec := httptest.NewRecorder()
+			RequireAdminToken(tt.token, next, zap.NewNop()).ServeHTTP(rec, req)
+
+			if rec.Code != tt.wantStatus {
+				t.Errorf("status = %d, want %d", rec.Code, tt.wantStatus)
+			}
+			if tt.wantStatus == http.StatusUnauthorized && rec.Header().Get("WWW-Authenticate") != "Bearer" {
+				t.Error("Expected WWW-Authenticate: Bearer on rejected requests")
+			}
+		})
+	}
+}
@@ -2,7 +2,31 @@
 package auth
 
 import (
+	"slices"
+	"sync/atomic"
+	"time"
+
+	"go.uber.org/zap"
+
+	httpmetrics "github.com/portswigger-tim/nats-k8s-oidc-callout/internal/httpserver"
 	"github.com/portswigger-tim/nats-k8s-oidc-callout/internal/jwt"
+	"github.com/portswigger-tim/nats-k8s-oidc-callout/internal/k8s"
+)
+
+// Denial reasons reported in AuthResponse.Reason, logs and metrics.
+// Clients only ever see the generic "authorization failed" error.
+const (
+	ReasonMissingToken             = "missing_token"
+	ReasonInvalidToken             = "invalid_token"
+	ReasonUnknownServiceAccount    = "unknown_serviceaccount"
+	ReasonServiceAccountDisabled   = "sa_disabled"
+	ReasonSourceNotAllowed         = "source_not_allowed"
+	ReasonConnectionTypeNotAllowed = "connection_type_not_allowed"
+	ReasonOutsideAllowedTimes      = "outside_allowed_times"
+	ReasonNodeNotAllowed           = "node_not_allowed"
+	ReasonLockdown                 = "lockdown"
+	ReasonTokenRevoked             = "token_revoked"
+	ReasonRateLimited              = "rate_limited"
 )
 
 // JWTValidator defines the interface for JWT validation
@@ -12,69 +36,306 @@ type JWTValidator interface {
 
 // PermissionsProvider defines the interface for retrieving ServiceAccount permissions
 type PermissionsProvider interface {
-	GetPermissions(namespace, name string) (pubPerms []string, subPerms []string, found bool)
+	LookupPermissions(namespace, name string) (perms *k8s.Permissions, found bool)
+}
+
+// PodPermissionsProvider is implemented by permission providers that refine ServiceAccount
+// permissions for the pod bound to the token
+type PodPermissionsProvider interface {
+	LookupPodPermissions(namespace, serviceAccount, pod, podUID string) (perms *k8s.Permissions, found bool)
+}
+
+// NodeLabelsProvider is implemented by permission providers that watch nodes, so node selectors
+// can be enforced
+type NodeLabelsProvider interface {
+	NodeLabels(name string) (labels map[string]string, found bool)
 }
 
 // AuthRequest represents an authorization request
 type AuthRequest struct {
 	Token string
+	// ClientHost is the client's IP address as reported by the NATS server
+	ClientHost string
+	// ConnectionType is the NATS connection type (e.g. STANDARD, WEBSOCKET, LEAFNODE)
+	ConnectionType string
 }
 
 // AuthResponse represents the authorization response
 type AuthResponse struct {
 	Allowed              bool
+	Namespace            string // ServiceAccount the client authenticated as
+	ServiceAccount       string
+	Pod                  string // Pod bound to the token; empty when the token is not bound to a pod
+	TokenID              string // jti of the presented token, for audit records
 	PublishPermissions   []string
 	SubscribePermissions []string
+	PublishDeny          []string                // Subjects removed from the publish permissions
+	SubscribeDeny        []string                // Subjects removed from the subscribe permissions
+	ResponsePermission   *k8s.ResponsePermission // nil disables response permissions
+	SourceCIDRs          []string                // Allowed client networks; empty allows any source
+	ConnectionTypes      []string                // Allowed connection types; empty allows any type
+	AllowedTimes         *k8s.AllowedTimes       // Allowed connection windows; nil allows any time
+	Limits               *k8s.Limits             // Per-connection limits; nil leaves connections unlimited
 	Error                string
+	Reason               string // Denial reason for logs and metrics; never sent to the client
+	Detail               string // Context for denial logs, e.g. the lockdown reason or revocation kind; never sent to the client
 }
 
 // Handler handles authorization requests
 type Handler struct {
 	jwtValidator JWTValidator
 	permProvider PermissionsProvider
+	now          func() time.Time
+	logger       *zap.Logger
+	lockdown     atomic.Pointer[k8s.Lockdown]       // nil when no lockdown is configured
+	revocations  atomic.Pointer[k8s.RevocationList] // nil when no tokens are revoked
+	limiter      *RateLimiter                       // nil disables rate limiting
 }
 
 // NewHandler creates a new authorization handler
-func NewHandler(jwtValidator JWTValidator, permProvider PermissionsProvider) *Handler {
+func NewHandler(jwtValidator JWTValidator, permProvider PermissionsProvider, logger *zap.Logger) *Handler {
 	return &Handler{
 		jwtValidator: jwtValidator,
 		permProvider: permProvider,
+		now:          time.Now,
+		logger:       logger,
 	}
 }
 
+// SetRateLimiter enables rate limiting and blocking of clients with repeated token failures
+func (h *Handler) SetRateLimiter(limiter *RateLimiter) {
+	h.limiter = limiter
+}
+
 // Authorize processes an authorization request and returns the response
 func (h *Handler) Authorize(req *AuthRequest) *AuthResponse {
+	if h.limiter == nil {
+		return h.authorize(req)
+	}
+
+	// Cheaply reject blocked or flooding clients before spending time on token validation
+	now := h.now()
+	if limit := h.limiter.allowSource(req.ClientHost, now); limit != "" {
+		return rateLimited(limit)
+	}
+
+	resp := h.authorize(req)
+	h.limiter.recordResult(req.ClientHost, tokenFailure(resp.Reason), now)
+	return resp
+}
+
+// authorize validates the token and builds the response for a request within its rate limits
+func (h *Handler) authorize(req *AuthRequest) *AuthResponse {
 	// Validate input
 	if req.Token == "" {
-		return &AuthResponse{
-			Allowed: false,
-			Error:   "authorization failed",
-		}
+		return deny(ReasonMissingToken)
 	}
 
 	// Validate JWT and extract claims
 	claims, err := h.jwtValidator.Validate(req.Token)
 	if err != nil {
 		// Generic error message to client, detailed logging would happen elsewhere
-		return &AuthResponse{
-			Allowed: false,
-			Error:   "authorization failed",
-		}
+		return deny(ReasonInvalidToken)
+	}
+
+	// Reject ServiceAccounts exceeding their request rate, e.g. pods stuck in a reconnect loop
+	if h.limiter != nil && !h.limiter.allowServiceAccount(claims.Namespace, claims.ServiceAccount, h.now()) {
+		httpmetrics.IncrementRateLimited(LimitServiceAccount)
+		resp := denyClaims(ReasonRateLimited, claims)
+		resp.Detail = LimitServiceAccount
+		return resp
+	}
+
+	// Reject leaked tokens revoked by jti, pod UID or issue time
+	if kind := h.revocations.Load().Revoked(claims.Namespace, claims.ServiceAccount, claims.TokenID, claims.PodUID, claims.IssuedAt); kind != "" {
+		resp := denyClaims(ReasonTokenRevoked, claims)
+		resp.Detail = kind
+		return resp
+	}
+
+	// Reject everyone outside the allowlist during a lockdown
+	if lockdown := h.lockdown.Load(); !lockdown.Allows(claims.Namespace, claims.ServiceAccount) {
+		resp := denyClaims(ReasonLockdown, claims)
+		resp.Detail = lockdown.Reason
+		return resp
 	}
 
-	// Look up permissions from K8s ServiceAccount
-	pubPerms, subPerms, found := h.permProvider.GetPermissions(claims.Namespace, claims.ServiceAccount)
+	// Look up permissions from K8s ServiceAccount, refined for the pod when supported
+	perms, found := h.lookupPermissions(claims)
 	if !found {
-		return &AuthResponse{
-			Allowed: false,
-			Error:   "authorization failed",
-		}
+		return denyClaims(ReasonUnknownServiceAccount, claims)
+	}
+
+	// Reject ServiceAccounts cut off with nats.io/disabled or nats.io/disabled-until
+	if perms.DisabledAt(h.now()) {
+		return denyClaims(ReasonServiceAccountDisabled, claims)
+	}
+
+	// Reject clients connecting from outside the allowed source networks
+	if !perms.SourceAllowed(req.ClientHost) {
+		return denyClaims(ReasonSourceNotAllowed, claims)
+	}
+
+	// Reject connection types the ServiceAccount is not allowed to use (e.g. leafnodes)
+	if !perms.ConnectionTypeAllowed(req.ConnectionType) {
+		return denyClaims(ReasonConnectionTypeNotAllowed, claims)
+	}
+
+	// Reject connections outside the ServiceAccount's allowed time windows
+	if perms.AllowedTimes != nil && !perms.AllowedTimes.Contains(h.now()) {
+		return denyClaims(ReasonOutsideAllowedTimes, claims)
+	}
+
+	// Reject pods scheduled on nodes outside the ServiceAccount's node selector
+	if perms.NodeSelector != nil && !h.nodeAllowed(perms, claims.NodeName) {
+		return denyClaims(ReasonNodeNotAllowed, claims)
+	}
+
+	pubPerms, subPerms := grantedSubjects(perms, claims.PodName)
+
+	// Shadow mode: issue the current permissions, but report what the candidate policy would change
+	if perms.Candidate != nil {
+		h.compareCandidate(claims, pubPerms, subPerms, perms.Candidate)
+	}
+
+	// Track clients still relying on the shared inbox ahead of enforcing strict inbox mode
+	if slices.Contains(subPerms, k8s.SharedInboxSubject) {
+		httpmetrics.IncrementSharedInboxAuthorizations(claims.Namespace, claims.ServiceAccount)
 	}
 
 	// Success
 	return &AuthResponse{
 		Allowed:              true,
+		Namespace:            claims.Namespace,
+		ServiceAccount:       claims.ServiceAccount,
+		Pod:                  claims.PodName,
+		TokenID:              claims.TokenID,
 		PublishPermissions:   pubPerms,
 		SubscribePermissions: subPerms,
+		PublishDeny:          k8s.ExpandPodDenySubjects(perms.PublishDeny, claims.PodName),
+		SubscribeDeny:        k8s.ExpandPodDenySubjects(perms.SubscribeDeny, claims.PodName),
+		ResponsePermission:   perms.Response,
+		SourceCIDRs:          k8s.SourceCIDRStrings(perms.SourceCIDRs),
+		ConnectionTypes:      perms.ConnectionTypes,
+		AllowedTimes:         perms.AllowedTimes,
+		Limits:               perms.Limits,
+	}
+}
+
+// SetLockdown replaces the lockdown state; nil or a disabled lockdown lets everyone connect
+func (h *Handler) SetLockdown(lockdown *k8s.Lockdown) {
+	h.lockdown.Store(lockdown)
+	httpmetrics.SetLockdown(lockdown != nil && lockdown.Enabled)
+}
+
+// Lockdown returns the current lockdown state, or nil if none was set
+func (h *Handler) Lockdown() *k8s.Lockdown {
+	return h.lockdown.Load()
+}
+
+// SetRevocationList replaces the revocation list; nil revokes nothing
+func (h *Handler) SetRevocationList(list *k8s.RevocationList) {
+	h.revocations.Store(list)
+}
+
+// grantedSubjects returns the publish and subscribe subjects granted to a client, with {{.Pod}}
+// placeholders resolved for the pod bound to its token. Queue subscriptions are granted as
+// "subject queue" subscribe entries.
+func grantedSubjects(perms *k8s.Permissions, pod string) (pub, sub []string) {
+	pub = k8s.ExpandPodSubjects(perms.Publish, pod)
+	sub = k8s.ExpandPodSubjects(perms.Subscribe, pod)
+	sub = append(sub, k8s.ExpandPodSubjects(k8s.QueueSubscriptionStrings(perms.SubscribeQueues), pod)...)
+	return pub, sub
+}
+
+// compareCandidate logs and counts the subjects the candidate policy would remove from or add
+// to the permissions being issued
+func (h *Handler) compareCandidate(claims *jwt.Claims, pub, sub []string, candidate *k8s.Permissions) {
+	candidatePub, candidateSub := grantedSubjects(candidate, claims.PodName)
+	pubRemoved, pubAdded := subjectDifference(pub, candidatePub)
+	subRemoved, subAdded := subjectDifference(sub, candidateSub)
+	if len(pubRemoved)+len(pubAdded)+len(subRemoved)+len(subAdded) == 0 {
+		return
+	}
+
+	httpmetrics.AddShadowDifferences(claims.Namespace, claims.ServiceAccount, "publish", "removed", len(pubRemoved))
+	httpmetrics.AddShadowDifferences(claims.Namespace, claims.ServiceAccount, "publish", "added", len(pubAdded))
+	httpmetrics.AddShadowDifferences(claims.Namespace, claims.ServiceAccount, "subscribe", "removed", len(subRemoved))
+	httpmetrics.AddShadowDifferences(claims.Namespace, claims.ServiceAccount, "subscribe", "added", len(subAdded))
+
+	h.logger.Info("candidate policy would change permissions",
+		zap.String("namespace", claims.Namespace),
+		zap.String("serviceaccount", claims.ServiceAccount),
+		zap.String("pod", claims.PodName),
+		zap.Strings("publish_removed", pubRemoved),
+		zap.Strings("publish_added", pubAdded),
+		zap.Strings("subscribe_removed", subRemoved),
+		zap.Strings("subscribe_added", subAdded))
+}
+
+// subjectDifference returns the current subjects missing from the candidate list and the
+// candidate subjects missing from the current list
+func subjectDifference(current, candidate []string) (removed, added []string) {
+	for _, subject := range current {
+		if !slices.Contains(candidate, subject) {
+			removed = append(removed, subject)
+		}
 	}
+	for _, subject := range candidate {
+		if !slices.Contains(current, subject) {
+			added = append(added, subject)
+		}
+	}
+	return removed, added
+}
+
+// lookupPermissions returns pod-level permissions when the provider supports them and the token
+// is bound to a pod, and ServiceAccount permissions otherwise
+func (h *Handler) lookupPermissions(claims *jwt.Claims) (*k8s.Permissions, bool) {
+	if podProvider, ok := h.permProvider.(PodPermissionsProvider); ok && claims.PodName != "" {
+		return podProvider.LookupPodPermissions(claims.Namespace, claims.ServiceAccount, claims.PodName, claims.PodUID)
+	}
+	return h.permProvider.LookupPermissions(claims.Namespace, claims.ServiceAccount)
+}
+
+// nodeAllowed checks the token's node against the ServiceAccount's node selector.
+// Fails closed when the token has no node claim or the node's labels are unknown.
+func (h *Handler) nodeAllowed(perms *k8s.Permissions, nodeName string) bool {
+	nodeProvider, ok := h.permProvider.(NodeLabelsProvider)
+	if !ok || nodeName == "" {
+		return false
+	}
+	nodeLabels, found := nodeProvider.NodeLabels(nodeName)
+	if !found {
+		return false
+	}
+	return perms.NodeAllowed(nodeLabels)
+}
+
+// deny builds a denied response with a generic client error and records the reason
+func deny(reason string) *AuthResponse {
+	httpmetrics.IncrementAuthDenials(reason)
+	return &AuthResponse{
+		Allowed: false,
+		Error:   "authorization failed",
+		Reason:  reason,
+	}
+}
+
+// rateLimited builds a denied response for a request rejected by a rate limit
+func rateLimited(limit string) *AuthResponse {
+	httpmetrics.IncrementRateLimited(limit)
+	resp := deny(ReasonRateLimited)
+	resp.Detail = limit
+	return resp
+}
+
+// denyClaims builds a denied response identifying the client whose token was valid
+func denyClaims(reason string, claims *jwt.Claims) *AuthResponse {
+	resp := deny(reason)
+	resp.Namespace = claims.Namespace
+	resp.ServiceAccount = claims.ServiceAccount
+	resp.Pod = claims.PodName
+	resp.TokenID = claims.TokenID
+	return resp
 }
@@ -2,9 +2,16 @@ package auth
 
 import (
 	"errors"
+	"net/netip"
+	"strings"
 	"testing"
+	"time"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zaptest/observer"
 
 	"github.com/portswigger-tim/nats-k8s-oidc-callout/internal/jwt"
+	"github.com/portswigger-tim/nats-k8s-oidc-callout/internal/k8s"
 )
 
 // Mock JWT validator for testing
@@ -17,12 +24,32 @@ func (m *mockJWTValidator) Validate(token string) (*jwt.Claims, error) {
 }
 
 // Mock permissions provider for testing
+// getPermissionsFunc returns pub/sub subjects only; lookupFunc returns full permissions and takes precedence.
 type mockPermissionsProvider struct {
 	getPermissionsFunc func(namespace, name string) ([]string, []string, bool)
+	lookupFunc         func(namespace, name string) (*k8s.Permissions, bool)
+}
+
+func (m *mockPermissionsProvider) LookupPermissions(namespace, name string) (*k8s.Permissions, bool) {
+	if m.lookupFunc != nil {
+		return m.lookupFunc(namespace, name)
+	}
+
+	pub, sub, found := m.getPermissionsFunc(namespace, name)
+	if !found {
+		return nil, false
+	}
+	return &k8s.Permissions{Publish: pub, Subscribe: sub}, true
 }
 
-func (m *mockPermissionsProvider) GetPermissions(namespace, name string) ([]string, []string, bool) {
-	return m.getPermissionsFunc(namespace, name)
+// Mock provider that also refines permissions per pod
+type mockPodPermissionsProvider struct {
+	mockPermissionsProvider
+	lookupPodFunc func(namespace, serviceAccount, pod, podUID string) (*k8s.Permissions, bool)
+}
+
+func (m *mockPodPermissionsProvider) LookupPodPermissions(namespace, serviceAccount, pod, podUID string) (*k8s.Permissions, bool) {
+	return m.lookupPodFunc(namespace, serviceAccount, pod, podUID)
 }
 
 // TestHandler_Authorize_Success tests successful authorization flow
@@ -47,7 +74,7 @@ func TestHandler_Authorize_Success(t *testing.T) {
 		},
 	}
 
-	handler := NewHandler(jwtValidator, permProvider)
+	handler := NewHandler(jwtValidator, permProvider, zap.NewNop())
 
 	req := &AuthRequest{
 		Token: "valid.jwt.token",
@@ -126,7 +153,7 @@ func TestHandler_Authorize_InvalidJWT(t *testing.T) {
 				},
 			}
 
-			handler := NewHandler(jwtValidator, permProvider)
+			handler := NewHandler(jwtValidator, permProvider, zap.NewNop())
 
 			req := &AuthRequest{
 				Token: "invalid.jwt.token",
@@ -142,6 +169,10 @@ func TestHandler_Authorize_InvalidJWT(t *testing.T) {
 				t.Errorf("Error = %q, want %q", resp.Error, tt.expectedMsg)
 			}
 
+			if resp.Reason != ReasonInvalidToken {
+				t.Errorf("Reason = %q, want %q", resp.Reason, ReasonInvalidToken)
+			}
+
 			if resp.PublishPermissions != nil {
 				t.Error("Expected no PublishPermissions on failure")
 			}
@@ -161,6 +192,7 @@ func TestHandler_Authorize_ServiceAccountNotFound(t *testing.T) {
 			return &jwt.Claims{
 				Namespace:      "production",
 				ServiceAccount: "nonexistent-sa",
+				TokenID:        "3f2c9a1e",
 			}, nil
 		},
 	}
@@ -172,7 +204,7 @@ func TestHandler_Authorize_ServiceAccountNotFound(t *testing.T) {
 		},
 	}
 
-	handler := NewHandler(jwtValidator, permProvider)
+	handler := NewHandler(jwtValidator, permProvider, zap.NewNop())
 
 	req := &AuthRequest{
 		Token: "valid.jwt.token",
@@ -188,6 +220,16 @@ func TestHandler_Authorize_ServiceAccountNotFound(t *testing.T) {
 		t.Errorf("Error = %q, want %q", resp.Error, "authorization failed")
 	}
 
+	if resp.Reason != ReasonUnknownServiceAccount {
+		t.Errorf("Reason = %q, want %q", resp.Reason, ReasonUnknownServiceAccount)
+	}
+
+	// Denials after token validation identify the client for audit records
+	if resp.Namespace != "production" || resp.ServiceAccount != "nonexistent-sa" || resp.TokenID != "3f2c9a1e" {
+		t.Errorf("Identity = %s/%s (jti %q), want production/nonexistent-sa (jti %q)",
+			resp.Namespace, resp.ServiceAccount, resp.TokenID, "3f2c9a1e")
+	}
+
 	if resp.PublishPermissions != nil {
 		t.Error("Expected no PublishPermissions on failure")
 	}
@@ -214,7 +256,7 @@ func TestHandler_Authorize_EmptyToken(t *testing.T) {
 		},
 	}
 
-	handler := NewHandler(jwtValidator, permProvider)
+	handler := NewHandler(jwtValidator, permProvider, zap.NewNop())
 
 	req := &AuthRequest{
 		Token: "",
@@ -229,6 +271,460 @@ func TestHandler_Authorize_EmptyToken(t *testing.T) {
 	if resp.Error != "authorization failed" {
 		t.Errorf("Error = %q, want %q", resp.Error, "authorization failed")
 	}
+
+	if resp.Reason != ReasonMissingToken {
+		t.Errorf("Reason = %q, want %q", resp.Reason, ReasonMissingToken)
+	}
+}
+
+// TestHandler_Authorize_PodPlaceholder tests resolving {{.Pod}} from the token's pod claim
+func TestHandler_Authorize_PodPlaceholder(t *testing.T) {
+	permProvider := &mockPermissionsProvider{
+		getPermissionsFunc: func(namespace, name string) ([]string, []string, bool) {
+			return []string{"payments.>", "pods.{{.Pod}}.>"}, []string{"payments.>"}, true
+		},
+	}
+
+	tests := []struct {
+		name    string
+		podName string
+		wantPub []string
+	}{
+		{
+			name:    "Token bound to pod",
+			podName: "api-7f9c-abcde",
+			wantPub: []string{"payments.>", "pods.api-7f9c-abcde.>"},
+		},
+		{
+			name:    "Token without pod claim",
+			podName: "",
+			wantPub: []string{"payments.>"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			jwtValidator := &mockJWTValidator{
+				validateFunc: func(token string) (*jwt.Claims, error) {
+					return &jwt.Claims{
+						Namespace:      "payments",
+						ServiceAccount: "api",
+						PodName:        tt.podName,
+					}, nil
+				},
+			}
+
+			handler := NewHandler(jwtValidator, permProvider, zap.NewNop())
+			resp := handler.Authorize(&AuthRequest{Token: "valid.jwt.token"})
+
+			if !resp.Allowed {
+				t.Fatal("Expected authorization to be allowed")
+			}
+
+			if !equalStringSlices(resp.PublishPermissions, tt.wantPub) {
+				t.Errorf("PublishPermissions = %v, want %v", resp.PublishPermissions, tt.wantPub)
+			}
+		})
+	}
+}
+
+// TestHandler_Authorize_ResponsePermission tests carrying response permissions into the response
+func TestHandler_Authorize_ResponsePermission(t *testing.T) {
+	jwtValidator := &mockJWTValidator{
+		validateFunc: func(token string) (*jwt.Claims, error) {
+			return &jwt.Claims{Namespace: "payments", ServiceAccount: "api"}, nil
+		},
+	}
+
+	tests := []struct {
+		name     string
+		response *k8s.ResponsePermission
+	}{
+		{
+			name:     "Streaming responder",
+			response: &k8s.ResponsePermission{MaxMsgs: -1, Expires: 5 * time.Second},
+		},
+		{
+			name:     "Responses disabled",
+			response: nil,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			permProvider := &mockPermissionsProvider{
+				lookupFunc: func(namespace, name string) (*k8s.Permissions, bool) {
+					return &k8s.Permissions{
+						Publish:   []string{"payments.>"},
+						Subscribe: []string{"payments.>"},
+						Response:  tt.response,
+					}, true
+				},
+			}
+
+			handler := NewHandler(jwtValidator, permProvider, zap.NewNop())
+			resp := handler.Authorize(&AuthRequest{Token: "valid.jwt.token"})
+
+			if !resp.Allowed {
+				t.Fatal("Expected authorization to be allowed")
+			}
+
+			if resp.ResponsePermission != tt.response {
+				t.Errorf("ResponsePermission = %+v, want %+v", resp.ResponsePermission, tt.response)
+			}
+		})
+	}
+}
+
+// TestHandler_Authorize_SourceCIDRs tests rejecting clients outside the allowed source networks
+func TestHandler_Authorize_SourceCIDRs(t *testing.T) {
+	jwtValidator := &mockJWTValidator{
+		validateFunc: func(token string) (*jwt.Claims, error) {
+			return &jwt.Claims{Namespace: "payments", ServiceAccount: "api"}, nil
+		},
+	}
+
+	tests := []struct {
+		name        string
+		sourceCIDRs []netip.Prefix
+		clientHost  string
+		wantAllowed bool
+		wantReason  string
+		wantSrc     []string
+	}{
+		{
+			name:        "No restriction",
+			clientHost:  "203.0.113.7",
+			wantAllowed: true,
+		},
+		{
+			name:        "Host inside allowed network",
+			sourceCIDRs: []netip.Prefix{netip.MustParsePrefix("10.244.0.0/16")},
+			clientHost:  "10.244.3.17",
+			wantAllowed: true,
+			wantSrc:     []string{"10.244.0.0/16"},
+		},
+		{
+			name:        "IPv4-mapped host inside allowed network",
+			sourceCIDRs: []netip.Prefix{netip.MustParsePrefix("10.244.0.0/16")},
+			clientHost:  "::ffff:10.244.3.17",
+			wantAllowed: true,
+			wantSrc:     []string{"10.244.0.0/16"},
+		},
+		{
+			name:        "Host outside allowed network",
+			sourceCIDRs: []netip.Prefix{netip.MustParsePrefix("10.244.0.0/16")},
+			clientHost:  "203.0.113.7",
+			wantAllowed: false,
+			wantReason:  ReasonSourceNotAllowed,
+		},
+		{
+			name:        "Unknown host",
+			sourceCIDRs: []netip.Prefix{netip.MustParsePrefix("10.244.0.0/16")},
+			clientHost:  "",
+			wantAllowed: false,
+			wantReason:  ReasonSourceNotAllowed,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			permProvider := &mockPermissionsProvider{
+				lookupFunc: func(namespace, name string) (*k8s.Permissions, bool) {
+					return &k8s.Permissions{
+						Publish:     []string{"payments.>"},
+						Subscribe:   []string{"payments.>"},
+						SourceCIDRs: tt.sourceCIDRs,
+					}, true
+				},
+			}
+
+			handler := NewHandler(jwtValidator, permProvider, zap.NewNop())
+			resp := handler.Authorize(&AuthRequest{Token: "valid.jwt.token", ClientHost: tt.clientHost})
+
+			if resp.Allowed != tt.wantAllowed {
+				t.Fatalf("Allowed = %v, want %v", resp.Allowed, tt.wantAllowed)
+			}
+			if resp.Reason != tt.wantReason {
+				t.Errorf("Reason = %q, want %q", resp.Reason, tt.wantReason)
+			}
+			if !tt.wantAllowed && resp.Error != "authorization failed" {
+				t.Errorf("Error = %q, want generic error", resp.Error)
+			}
+			if !equalStringSlices(resp.SourceCIDRs, tt.wantSrc) {
+				t.Errorf("SourceCIDRs = %v, want %v", resp.SourceCIDRs, tt.wantSrc)
+			}
+		})
+	}
+}
+
+// TestHandler_Authorize_ConnectionTypes tests rejecting disallowed connection types
+func TestHandler_Authorize_ConnectionTypes(t *testing.T) {
+	jwtValidator := &mockJWTValidator{
+		validateFunc: func(token string) (*jwt.Claims, error) {
+			return &jwt.Claims{Namespace: "payments", ServiceAccount: "api"}, nil
+		},
+	}
+	permProvider := &mockPermissionsProvider{
+		lookupFunc: func(namespace, name string) (*k8s.Permissions, bool) {
+			return &k8s.Permissions{
+				Publish:         []string{"payments.>"},
+				Subscribe:       []string{"payments.>"},
+				ConnectionTypes: []string{"STANDARD"},
+			}, true
+		},
+	}
+	handler := NewHandler(jwtValidator, permProvider, zap.NewNop())
+
+	resp := handler.Authorize(&AuthRequest{Token: "valid.jwt.token", ConnectionType: "STANDARD"})
+	if !resp.Allowed {
+		t.Fatalf("Expected STANDARD connection to be allowed, got reason %q", resp.Reason)
+	}
+	if !equalStringSlices(resp.ConnectionTypes, []string{"STANDARD"}) {
+		t.Errorf("ConnectionTypes = %v, want [STANDARD]", resp.ConnectionTypes)
+	}
+
+	resp = handler.Authorize(&AuthRequest{Token: "valid.jwt.token", ConnectionType: "LEAFNODE"})
+	if resp.Allowed {
+		t.Fatal("Expected LEAFNODE connection to be denied")
+	}
+	if resp.Reason != ReasonConnectionTypeNotAllowed {
+		t.Errorf("Reason = %q, want %q", resp.Reason, ReasonConnectionTypeNotAllowed)
+	}
+}
+
+// TestHandler_Authorize_QueueSubscriptions tests granting queue subscriptions as "subject queue" entries
+func TestHandler_Authorize_QueueSubscriptions(t *testing.T) {
+	jwtValidator := &mockJWTValidator{
+		validateFunc: func(token string) (*jwt.Claims, error) {
+			return &jwt.Claims{Namespace: "orders", ServiceAccount: "worker", PodName: "worker-abc"}, nil
+		},
+	}
+	permProvider := &mockPermissionsProvider{
+		lookupFunc: func(namespace, name string) (*k8s.Permissions, bool) {
+			return &k8s.Permissions{
+				Publish:   []string{"orders.>"},
+				Subscribe: []string{"_INBOX.>"},
+				SubscribeQueues: []k8s.QueueSubscription{
+					{Subject: "jobs.>", Queue: "workers"},
+					{Subject: "pods.{{.Pod}}.jobs", Queue: "workers"},
+				},
+			}, true
+		},
+	}
+	handler := NewHandler(jwtValidator, permProvider, zap.NewNop())
+
+	resp := handler.Authorize(&AuthRequest{Token: "valid.jwt.token"})
+	if !resp.Allowed {
+		t.Fatalf("Expected authorization to succeed, got reason %q", resp.Reason)
+	}
+
+	want := []string{"_INBOX.>", "jobs.> workers", "pods.worker-abc.jobs workers"}
+	if !equalStringSlices(resp.SubscribePermissions, want) {
+		t.Errorf("SubscribePermissions = %v, want %v", resp.SubscribePermissions, want)
+	}
+}
+
+// TestHandler_Authorize_PodPermissions tests using pod-level permissions for tokens bound to a pod
+func TestHandler_Authorize_PodPermissions(t *testing.T) {
+	permProvider := &mockPodPermissionsProvider{
+		mockPermissionsProvider: mockPermissionsProvider{
+			lookupFunc: func(namespace, name string) (*k8s.Permissions, bool) {
+				return &k8s.Permissions{Publish: []string{"shop.>"}}, true
+			},
+		},
+		lookupPodFunc: func(namespace, serviceAccount, pod, podUID string) (*k8s.Permissions, bool) {
+			if podUID != "gateway-uid" {
+				return &k8s.Permissions{Publish: []string{"shop.>"}}, true
+			}
+			return &k8s.Permissions{Publish: []string{"shop.>", "ingress.>"}}, true
+		},
+	}
+
+	tests := []struct {
+		name    string
+		podName string
+		podUID  string
+		wantPub []string
+	}{
+		{name: "Token bound to a pod", podName: "gateway-1", podUID: "gateway-uid", wantPub: []string{"shop.>", "ingress.>"}},
+		{name: "Token bound to a pod with another UID", podName: "gateway-1", podUID: "other-uid", wantPub: []string{"shop.>"}},
+		{name: "Token not bound to a pod", podName: "", wantPub: []string{"shop.>"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			jwtValidator := &mockJWTValidator{
+				validateFunc: func(token string) (*jwt.Claims, error) {
+					return &jwt.Claims{Namespace: "shop", ServiceAccount: "api", PodName: tt.podName, PodUID: tt.podUID}, nil
+				},
+			}
+			handler := NewHandler(jwtValidator, permProvider, zap.NewNop())
+
+			resp := handler.Authorize(&AuthRequest{Token: "valid.jwt.token"})
+			if !resp.Allowed {
+				t.Fatalf("Expected authorization to succeed, got reason %q", resp.Reason)
+			}
+			if !equalStringSlices(resp.PublishPermissions, tt.wantPub) {
+				t.Errorf("PublishPermissions = %v, want %v", resp.PublishPermissions, tt.wantPub)
+			}
+		})
+	}
+}
+
+// mockNodePermissionsProvider is a permissions provider that also knows node labels
+type mockNodePermissionsProvider struct {
+	mockPermissionsProvider
+	nodes map[string]map[string]string
+}
+
+func (m *mockNodePermissionsProvider) NodeLabels(name string) (map[string]string, bool) {
+	nodeLabels, ok := m.nodes[name]
+	return nodeLabels, ok
+}
+
+// TestHandler_Authorize_NodeSelector tests restricting ServiceAccounts to pods on matching nodes
+func TestHandler_Authorize_NodeSelector(t *testing.T) {
+	selector, err := k8s.ParseNodeSelector("pci=true")
+	if err != nil {
+		t.Fatalf("Failed to parse node selector: %v", err)
+	}
+	lookup := func(namespace, name string) (*k8s.Permissions, bool) {
+		return &k8s.Permissions{Publish: []string{"payments.>"}, NodeSelector: selector}, true
+	}
+	nodeProvider := &mockNodePermissionsProvider{
+		mockPermissionsProvider: mockPermissionsProvider{lookupFunc: lookup},
+		nodes: map[string]map[string]string{
+			"pci-node":     {"pci": "true"},
+			"general-node": {"pci": "false"},
+		},
+	}
+
+	tests := []struct {
+		name        string
+		provider    PermissionsProvider
+		nodeName    string
+		wantAllowed bool
+	}{
+		{name: "Matching node", provider: nodeProvider, nodeName: "pci-node", wantAllowed: true},
+		{name: "Non-matching node", provider: nodeProvider, nodeName: "general-node", wantAllowed: false},
+		{name: "Unknown node", provider: nodeProvider, nodeName: "missing-node", wantAllowed: false},
+		{name: "Token without node claim", provider: nodeProvider, nodeName: "", wantAllowed: false},
+		{name: "Nodes not watched", provider: &mockPermissionsProvider{lookupFunc: lookup}, nodeName: "pci-node", wantAllowed: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			jwtValidator := &mockJWTValidator{
+				validateFunc: func(token string) (*jwt.Claims, error) {
+					return &jwt.Claims{Namespace: "payments", ServiceAccount: "processor", NodeName: tt.nodeName}, nil
+				},
+			}
+			handler := NewHandler(jwtValidator, tt.provider, zap.NewNop())
+
+			resp := handler.Authorize(&AuthRequest{Token: "valid.jwt.token"})
+			if resp.Allowed != tt.wantAllowed {
+				t.Fatalf("Allowed = %v, want %v", resp.Allowed, tt.wantAllowed)
+			}
+			if !tt.wantAllowed && resp.Reason != ReasonNodeNotAllowed {
+				t.Errorf("Reason = %q, want %q", resp.Reason, ReasonNodeNotAllowed)
+			}
+		})
+	}
+}
+
+// TestHandler_Authorize_Disabled tests denying disabled ServiceAccounts
+func TestHandler_Authorize_Disabled(t *testing.T) {
+	jwtValidator := &mockJWTValidator{
+		validateFunc: func(token string) (*jwt.Claims, error) {
+			return &jwt.Claims{Namespace: "shop", ServiceAccount: "api"}, nil
+		},
+	}
+	now := time.Date(2026, 7, 1, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name        string
+		perms       *k8s.Permissions
+		wantAllowed bool
+	}{
+		{name: "Enabled", perms: &k8s.Permissions{Publish: []string{"shop.>"}}, wantAllowed: true},
+		{name: "Disabled", perms: &k8s.Permissions{Publish: []string{"shop.>"}, Disabled: true}, wantAllowed: false},
+		{name: "Disabled until later", perms: &k8s.Permissions{Publish: []string{"shop.>"}, DisabledUntil: now.Add(time.Hour)}, wantAllowed: false},
+		{name: "Disabled until earlier", perms: &k8s.Permissions{Publish: []string{"shop.>"}, DisabledUntil: now.Add(-time.Hour)}, wantAllowed: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			permProvider := &mockPermissionsProvider{
+				lookupFunc: func(namespace, name string) (*k8s.Permissions, bool) {
+					return tt.perms, true
+				},
+			}
+			handler := NewHandler(jwtValidator, permProvider, zap.NewNop())
+			handler.now = func() time.Time { return now }
+
+			resp := handler.Authorize(&AuthRequest{Token: "valid.jwt.token"})
+			if resp.Allowed != tt.wantAllowed {
+				t.Fatalf("Allowed = %v, want %v", resp.Allowed, tt.wantAllowed)
+			}
+			if !tt.wantAllowed && resp.Reason != ReasonServiceAccountDisabled {
+				t.Errorf("Reason = %q, want %q", resp.Reason, ReasonServiceAccountDisabled)
+			}
+			if tt.wantAllowed && (resp.Namespace != "shop" || resp.ServiceAccount != "api") {
+				t.Errorf("Identity = %s/%s, want shop/api", resp.Namespace, resp.ServiceAccount)
+			}
+		})
+	}
+}
+
+// TestHandler_Authorize_AllowedTimes tests rejecting connections outside the allowed time windows
+func TestHandler_Authorize_AllowedTimes(t *testing.T) {
+	jwtValidator := &mockJWTValidator{
+		validateFunc: func(token string) (*jwt.Claims, error) {
+			return &jwt.Claims{Namespace: "reports", ServiceAccount: "batch"}, nil
+		},
+	}
+	allowedTimes, err := k8s.ParseAllowedTimes("Mon-Fri 08:00-18:00 UTC")
+	if err != nil {
+		t.Fatalf("Failed to parse allowed times: %v", err)
+	}
+	permProvider := &mockPermissionsProvider{
+		lookupFunc: func(namespace, name string) (*k8s.Permissions, bool) {
+			return &k8s.Permissions{
+				Publish:      []string{"reports.>"},
+				Subscribe:    []string{"reports.>"},
+				AllowedTimes: allowedTimes,
+			}, true
+		},
+	}
+
+	tests := []struct {
+		name        string
+		now         time.Time
+		wantAllowed bool
+	}{
+		{name: "Inside window", now: time.Date(2026, 7, 15, 9, 30, 0, 0, time.UTC), wantAllowed: true},
+		{name: "Outside window", now: time.Date(2026, 7, 15, 19, 0, 0, 0, time.UTC), wantAllowed: false},
+		{name: "Weekend", now: time.Date(2026, 7, 18, 9, 30, 0, 0, time.UTC), wantAllowed: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler := NewHandler(jwtValidator, permProvider, zap.NewNop())
+			handler.now = func() time.Time { return tt.now }
+
+			resp := handler.Authorize(&AuthRequest{Token: "valid.jwt.token"})
+			if resp.Allowed != tt.wantAllowed {
+				t.Fatalf("Allowed = %v, want %v", resp.Allowed, tt.wantAllowed)
+			}
+			if tt.wantAllowed && resp.AllowedTimes != allowedTimes {
+				t.Error("Expected AllowedTimes to be carried into the response")
+			}
+			if !tt.wantAllowed && resp.Reason != ReasonOutsideAllowedTimes {
+				t.Errorf("Reason = %q, want %q", resp.Reason, ReasonOutsideAllowedTimes)
+			}
+		})
+	}
 }
 
 // Helper function to compare string slices
@@ -243,3 +739,241 @@ func equalStringSlices(a, b []string) bool {
 	}
 	return true
 }
+
+// TestHandler_Authorize_ShadowMode tests issuing current permissions and logging candidate differences
+func TestHandler_Authorize_ShadowMode(t *testing.T) {
+	jwtValidator := &mockJWTValidator{
+		validateFunc: func(token string) (*jwt.Claims, error) {
+			return &jwt.Claims{Namespace: "shop", ServiceAccount: "api", PodName: "api-1"}, nil
+		},
+	}
+	permProvider := &mockPermissionsProvider{
+		lookupFunc: func(namespace, name string) (*k8s.Permissions, bool) {
+			return &k8s.Permissions{
+				Publish:   []string{"shop.>", "orders.created"},
+				Subscribe: []string{"_INBOX.>", "_INBOX_shop_api.>", "shop.>"},
+				Candidate: &k8s.Permissions{
+					Publish:   []string{"shop.>"},
+					Subscribe: []string{"_INBOX_shop_api.>", "shop.>", "shop.pods.{{.Pod}}"},
+				},
+			}, true
+		},
+	}
+
+	core, logs := observer.New(zap.InfoLevel)
+	handler := NewHandler(jwtValidator, permProvider, zap.New(core))
+
+	resp := handler.Authorize(&AuthRequest{Token: "valid.jwt.token"})
+	if !resp.Allowed {
+		t.Fatalf("Expected authorization to be allowed, got reason %q", resp.Reason)
+	}
+
+	// The current permissions are issued
+	if want := []string{"shop.>", "orders.created"}; !equalStringSlices(resp.PublishPermissions, want) {
+		t.Errorf("PublishPermissions = %v, want %v", resp.PublishPermissions, want)
+	}
+	if want := []string{"_INBOX.>", "_INBOX_shop_api.>", "shop.>"}; !equalStringSlices(resp.SubscribePermissions, want) {
+		t.Errorf("SubscribePermissions = %v, want %v", resp.SubscribePermissions, want)
+	}
+
+	entries := logs.FilterMessage("candidate policy would change permissions").All()
+	if len(entries) != 1 {
+		t.Fatalf("Logged %d differences, want 1", len(entries))
+	}
+	fields := entries[0].ContextMap()
+	wantFields := map[string][]string{
+		"publish_removed":   {"orders.created"},
+		"subscribe_removed": {"_INBOX.>"},
+		"subscribe_added":   {"shop.pods.api-1"},
+	}
+	for key, want := range wantFields {
+		got, _ := fields[key].([]interface{})
+		if len(got) != len(want) || got[0] != want[0] {
+			t.Errorf("%s = %v, want %v", key, fields[key], want)
+		}
+	}
+	if got, _ := fields["publish_added"].([]interface{}); len(got) != 0 {
+		t.Errorf("publish_added = %v, want none", got)
+	}
+}
+
+// TestHandler_Authorize_ShadowModeNoDifference tests that matching candidate permissions are not logged
+func TestHandler_Authorize_ShadowModeNoDifference(t *testing.T) {
+	jwtValidator := &mockJWTValidator{
+		validateFunc: func(token string) (*jwt.Claims, error) {
+			return &jwt.Claims{Namespace: "shop", ServiceAccount: "api"}, nil
+		},
+	}
+	permProvider := &mockPermissionsProvider{
+		lookupFunc: func(namespace, name string) (*k8s.Permissions, bool) {
+			return &k8s.Permissions{
+				Publish:   []string{"shop.>"},
+				Subscribe: []string{"shop.>"},
+				Candidate: &k8s.Permissions{Publish: []string{"shop.>"}, Subscribe: []string{"shop.>"}},
+			}, true
+		},
+	}
+
+	core, logs := observer.New(zap.InfoLevel)
+	handler := NewHandler(jwtValidator, permProvider, zap.New(core))
+	handler.Authorize(&AuthRequest{Token: "valid.jwt.token"})
+
+	if logs.Len() != 0 {
+		t.Errorf("Logged %d entries, want none", logs.Len())
+	}
+}
+
+func TestHandler_Authorize_Lockdown(t *testing.T) {
+	jwtValidator := &mockJWTValidator{
+		validateFunc: func(token string) (*jwt.Claims, error) {
+			namespace, name, _ := strings.Cut(token, "/")
+			return &jwt.Claims{Namespace: namespace, ServiceAccount: name}, nil
+		},
+	}
+	permProvider := &mockPermissionsProvider{
+		lookupFunc: func(namespace, name string) (*k8s.Permissions, bool) {
+			return &k8s.Permissions{Publish: []string{namespace + ".>"}}, true
+		},
+	}
+	handler := NewHandler(jwtValidator, permProvider, zap.NewNop())
+
+	if resp := handler.Authorize(&AuthRequest{Token: "shop/api"}); !resp.Allowed {
+		t.Fatalf("Expected authorization without lockdown, got reason %q", resp.Reason)
+	}
+
+	handler.SetLockdown(&k8s.Lockdown{
+		Enabled: true,
+		Reason:  "INC-1234",
+		Allow:   []string{"nats-system", "platform/operator"},
+	})
+
+	tests := []struct {
+		token   string
+		allowed bool
+	}{
+		{"shop/api", false},
+		{"platform/other", false},
+		{"platform/operator", true},
+		{"nats-system/anything", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.token, func(t *testing.T) {
+			resp := handler.Authorize(&AuthRequest{Token: tt.token})
+			if resp.Allowed != tt.allowed {
+				t.Fatalf("Allowed = %v, want %v", resp.Allowed, tt.allowed)
+			}
+			if tt.allowed {
+				return
+			}
+			if resp.Reason != ReasonLockdown {
+				t.Errorf("Reason = %q, want %q", resp.Reason, ReasonLockdown)
+			}
+			if resp.Detail != "INC-1234" {
+				t.Errorf("Detail = %q, want INC-1234", resp.Detail)
+			}
+			if resp.Error != "authorization failed" {
+				t.Errorf("Error = %q, want generic error", resp.Error)
+			}
+		})
+	}
+
+	handler.SetLockdown(&k8s.Lockdown{Enabled: false, Allow: []string{"nats-system"}})
+	if resp := handler.Authorize(&AuthRequest{Token: "shop/api"}); !resp.Allowed {
+		t.Errorf("Expected authorization with a disabled lockdown, got reason %q", resp.Reason)
+	}
+}
+
+func TestHandler_Authorize_Revoked(t *testing.T) {
+	now := time.Date(2026, 7, 1, 12, 0, 0, 0, time.UTC)
+	claims := jwt.Claims{
+		Namespace:      "shop",
+		ServiceAccount: "api",
+		PodName:        "api-1",
+		PodUID:         "pod-uid-1",
+		TokenID:        "jti-1",
+		IssuedAt:       now.Add(-time.Hour),
+		ExpiresAt:      now.Add(time.Hour),
+	}
+	permProvider := &mockPermissionsProvider{
+		lookupFunc: func(namespace, name string) (*k8s.Permissions, bool) {
+			return &k8s.Permissions{Publish: []string{"shop.>"}}, true
+		},
+	}
+
+	tests := []struct {
+		name       string
+		list       *k8s.RevocationList
+		wantDetail string
+	}{
+		{
+			name: "no revocations",
+			list: nil,
+		},
+		{
+			name:       "revoked token ID",
+			list:       &k8s.RevocationList{Tokens: []k8s.TokenRevocation{{TokenID: "jti-1", Expires: now.Add(time.Hour)}}},
+			wantDetail: k8s.RevokedTokenID,
+		},
+		{
+			name:       "revoked pod",
+			list:       &k8s.RevocationList{Pods: []k8s.PodRevocation{{UID: "pod-uid-1", Expires: now.Add(time.Hour)}}},
+			wantDetail: k8s.RevokedPod,
+		},
+		{
+			name: "tokens issued before cutoff",
+			list: &k8s.RevocationList{ServiceAccounts: []k8s.ServiceAccountRevocation{
+				{ServiceAccount: "shop/api", IssuedBefore: now.Add(-time.Minute), Expires: now.Add(time.Hour)},
+			}},
+			wantDetail: k8s.RevokedIssuedBefore,
+		},
+		{
+			name: "token issued after cutoff",
+			list: &k8s.RevocationList{ServiceAccounts: []k8s.ServiceAccountRevocation{
+				{ServiceAccount: "shop/api", IssuedBefore: now.Add(-2 * time.Hour), Expires: now.Add(time.Hour)},
+			}},
+		},
+		{
+			name:       "entry past an early expires hint",
+			list:       &k8s.RevocationList{Tokens: []k8s.TokenRevocation{{TokenID: "jti-1", Expires: now.Add(-time.Minute)}}},
+			wantDetail: k8s.RevokedTokenID,
+		},
+		{
+			name: "other token",
+			list: &k8s.RevocationList{Tokens: []k8s.TokenRevocation{{TokenID: "jti-2", Expires: now.Add(time.Hour)}}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			jwtValidator := &mockJWTValidator{
+				validateFunc: func(token string) (*jwt.Claims, error) {
+					c := claims
+					return &c, nil
+				},
+			}
+			handler := NewHandler(jwtValidator, permProvider, zap.NewNop())
+			handler.now = func() time.Time { return now }
+			handler.SetRevocationList(tt.list)
+
+			resp := handler.Authorize(&AuthRequest{Token: "valid.jwt.token"})
+			if tt.wantDetail == "" {
+				if !resp.Allowed {
+					t.Errorf("Expected authorization to be allowed, got reason %q", resp.Reason)
+				}
+				return
+			}
+			if resp.Allowed {
+				t.Fatal("Expected revoked token to be denied")
+			}
+			if resp.Reason != ReasonTokenRevoked {
+				t.Errorf("Reason = %q, want %q", resp.Reason, ReasonTokenRevoked)
+			}
+			if resp.Detail != tt.wantDetail {
+				t.Errorf("Detail = %q, want %q", resp.Detail, tt.wantDetail)
+			}
+			if resp.TokenID != "jti-1" {
+				t.Errorf("TokenID = %q, want jti-1", resp.TokenID)
+			}
+		})
+	}
+}
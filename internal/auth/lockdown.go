@@ -0,0 +1,82 @@
+package auth
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"go.uber.org/zap"
+
+	"github.com/portswigger-tim/nats-k8s-oidc-callout/internal/k8s"
+)
+
+// maxLockdownBody bounds the size of a lockdown document sent to the admin endpoint
+const maxLockdownBody = 64 << 10
+
+// LockdownAPI is the admin endpoint for the lockdown state. GET returns it, PUT replaces it with a
+// JSON lockdown document and DELETE lifts it. Every request must carry the admin token as a
+// bearer token.
+type LockdownAPI struct {
+	handler *Handler
+	token   []byte
+	logger  *zap.Logger
+}
+
+// NewLockdownAPI creates the admin endpoint controlling the handler's lockdown state
+func NewLockdownAPI(handler *Handler, token string, logger *zap.Logger) *LockdownAPI {
+	return &LockdownAPI{
+		handler: handler,
+		token:   []byte(token),
+		logger:  logger,
+	}
+}
+
+// ServeHTTP serves the lockdown state to authenticated callers
+func (a *LockdownAPI) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if !validAdminToken(r, a.token) {
+		a.logger.Warn("rejected unauthenticated lockdown request",
+			zap.String("method", r.Method),
+			zap.String("remote_addr", r.RemoteAddr))
+		w.Header().Set("WWW-Authenticate", "Bearer")
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+	case http.MethodPut:
+		lockdown := &k8s.Lockdown{}
+		decoder := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxLockdownBody))
+		decoder.DisallowUnknownFields()
+		if err := decoder.Decode(lockdown); err != nil {
+			http.Error(w, "invalid lockdown document: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		if err := lockdown.Validate(); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		a.handler.SetLockdown(lockdown)
+		a.logger.Warn("lockdown updated through admin endpoint",
+			zap.Bool("enabled", lockdown.Enabled),
+			zap.String("reason", lockdown.Reason),
+			zap.Strings("allow", lockdown.Allow),
+			zap.String("remote_addr", r.RemoteAddr))
+	case http.MethodDelete:
+		a.handler.SetLockdown(nil)
+		a.logger.Warn("lockdown lifted through admin endpoint",
+			zap.String("remote_addr", r.RemoteAddr))
+	default:
+		w.Header().Set("Allow", "GET, PUT, DELETE")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	lockdown := a.handler.Lockdown()
+	if lockdown == nil {
+		lockdown = &k8s.Lockdown{}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(lockdown); err != nil {
+		a.logger.Error("failed to encode lockdown state", zap.Error(err))
+	}
+}
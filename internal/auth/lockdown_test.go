@@ -0,0 +1,87 @@
+package auth
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"go.uber.org/zap"
+
+	"github.com/portswigger-tim/nats-k8s-oidc-callout/internal/k8s"
+)
+
+func TestLockdownAPI(t *testing.T) {
+	handler := NewHandler(&mockJWTValidator{}, &mockPermissionsProvider{}, zap.NewNop())
+	api := NewLockdownAPI(handler, "s3cret", zap.NewNop())
+
+	serve := func(method, token, body string) (*httptest.ResponseRecorder, *k8s.Lockdown) {
+		req := httptest.NewRequest(method, "/admin/lockdown", strings.NewReader(body))
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		rec := httptest.NewRecorder()
+		api.ServeHTTP(rec, req)
+
+		var state *k8s.Lockdown
+		if rec.Code == http.StatusOK {
+			state = &k8s.Lockdown{}
+			if err := json.Unmarshal(rec.Body.Bytes(), state); err != nil {
+				t.Fatalf("Failed to decode response: %v", err)
+			}
+		}
+		return rec, state
+	}
+
+	t.Run("rejects missing and wrong tokens", func(t *testing.T) {
+		for _, token := range []string{"", "wrong"} {
+			rec, _ := serve(http.MethodPut, token, `{"enabled":true}`)
+			if rec.Code != http.StatusUnauthorized {
+				t.Errorf("token %q: status = %d, want 401", token, rec.Code)
+			}
+		}
+		if handler.Lockdown() != nil {
+			t.Errorf("Lockdown() = %+v, want unchanged", handler.Lockdown())
+		}
+	})
+
+	t.Run("rejects invalid documents", func(t *testing.T) {
+		for _, body := range []string{`{"enabled":true,"unknown":1}`, `{"allow":["a/b/c"]}`, `not json`} {
+			rec, _ := serve(http.MethodPut, "s3cret", body)
+			if rec.Code != http.StatusBadRequest {
+				t.Errorf("body %q: status = %d, want 400", body, rec.Code)
+			}
+		}
+	})
+
+	t.Run("rejects other methods", func(t *testing.T) {
+		rec, _ := serve(http.MethodPost, "s3cret", `{"enabled":true}`)
+		if rec.Code != http.StatusMethodNotAllowed {
+			t.Errorf("status = %d, want 405", rec.Code)
+		}
+	})
+
+	t.Run("enables, reports and lifts the lockdown", func(t *testing.T) {
+		_, state := serve(http.MethodGet, "s3cret", "")
+		if state == nil || state.Enabled {
+			t.Fatalf("initial state = %+v, want disabled", state)
+		}
+
+		_, state = serve(http.MethodPut, "s3cret", `{"enabled":true,"reason":"INC-1234","allow":["nats-system"]}`)
+		if state == nil || !state.Enabled || state.Reason != "INC-1234" {
+			t.Fatalf("state after PUT = %+v", state)
+		}
+		if lockdown := handler.Lockdown(); lockdown == nil || lockdown.Allows("shop", "api") {
+			t.Errorf("handler lockdown = %+v, want shop/api denied", lockdown)
+		}
+
+		_, state = serve(http.MethodDelete, "s3cret", "")
+		if state == nil || state.Enabled {
+			t.Errorf("state after DELETE = %+v, want disabled", state)
+		}
+		if handler.Lockdown() != nil {
+			t.Errorf("handler lockdown = %+v, want nil", handler.Lockdown())
+		}
+	})
+}
@@ -0,0 +1,190 @@
+package auth
+
+import (
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+	"golang.org/x/time/rate"
+
+	httpmetrics "github.com/portswigger-tim/nats-k8s-oidc-callout/internal/httpserver"
+)
+
+// Rate limits reported in AuthResponse.Detail and metrics for ReasonRateLimited denials
+const (
+	LimitSource         = "source"
+	LimitServiceAccount = "serviceaccount"
+	LimitBlocked        = "blocked"
+)
+
+// rateLimitIdleTimeout is how long a client IP or ServiceAccount can go without requests before
+// its limiter state is forgotten
+const rateLimitIdleTimeout = 10 * time.Minute
+
+// RateLimits configures the rate limiter. Zero values disable the corresponding check.
+type RateLimits struct {
+	PerSource          int           // Requests per second per client IP, checked before token validation
+	PerServiceAccount  int           // Requests per second per namespace/serviceaccount, checked after validation
+	BlockAfterFailures int           // Consecutive token failures before a client IP is blocked
+	BlockDuration      time.Duration // How long a client IP stays blocked
+}
+
+// RateLimiter applies token-bucket limits per client IP and per ServiceAccount, and temporarily
+// blocks client IPs that keep presenting missing, invalid or revoked tokens
+type RateLimiter struct {
+	limits          RateLimits
+	mu              sync.Mutex
+	sources         map[string]*sourceState
+	serviceAccounts map[string]*bucketState
+	lastSweep       time.Time
+	logger          *zap.Logger
+}
+
+// sourceState tracks a client IP's request bucket and failure streak
+type sourceState struct {
+	bucketState
+	failures     int
+	blockedUntil time.Time
+}
+
+// bucketState is a token bucket with the time it was last used
+type bucketState struct {
+	limiter  *rate.Limiter // nil when the rate is not limited
+	lastSeen time.Time
+}
+
+// NewRateLimiter creates a rate limiter
+func NewRateLimiter(limits RateLimits, logger *zap.Logger) *RateLimiter {
+	return &RateLimiter{
+		limits:          limits,
+		sources:         make(map[string]*sourceState),
+		serviceAccounts: make(map[string]*bucketState),
+		logger:          logger,
+	}
+}
+
+// allowSource returns the limit a client IP exceeded, or an empty string if its request may be
+// validated. Requests without a client IP are not limited.
+func (l *RateLimiter) allowSource(clientHost string, now time.Time) string {
+	if clientHost == "" || (l.limits.PerSource <= 0 && l.limits.BlockAfterFailures <= 0) {
+		return ""
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.sweep(now)
+
+	state, ok := l.sources[clientHost]
+	if !ok {
+		state = &sourceState{bucketState: bucketState{limiter: newBucket(l.limits.PerSource)}}
+		l.sources[clientHost] = state
+	}
+	state.lastSeen = now
+
+	if now.Before(state.blockedUntil) {
+		return LimitBlocked
+	}
+	if state.limiter != nil && !state.limiter.AllowN(now, 1) {
+		return LimitSource
+	}
+	return ""
+}
+
+// allowServiceAccount reports whether a validated ServiceAccount is within its request rate
+func (l *RateLimiter) allowServiceAccount(namespace, serviceAccount string, now time.Time) bool {
+	if l.limits.PerServiceAccount <= 0 {
+		return true
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	key := namespace + "/" + serviceAccount
+	state, ok := l.serviceAccounts[key]
+	if !ok {
+		state = &bucketState{limiter: newBucket(l.limits.PerServiceAccount)}
+		l.serviceAccounts[key] = state
+	}
+	state.lastSeen = now
+	return state.limiter.AllowN(now, 1)
+}
+
+// recordResult tracks a client IP's consecutive token failures, blocking it once they reach
+// BlockAfterFailures. Any other outcome ends the streak.
+func (l *RateLimiter) recordResult(clientHost string, tokenFailure bool, now time.Time) {
+	if clientHost == "" || l.limits.BlockAfterFailures <= 0 {
+		return
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	state, ok := l.sources[clientHost]
+	if !ok {
+		return
+	}
+	if !tokenFailure {
+		state.failures = 0
+		return
+	}
+
+	state.failures++
+	if state.failures < l.limits.BlockAfterFailures {
+		return
+	}
+
+	state.failures = 0
+	state.blockedUntil = now.Add(l.limits.BlockDuration)
+	httpmetrics.IncrementSourceBlocks()
+	httpmetrics.SetBlockedSources(l.blockedCount(now))
+	l.logger.Warn("blocking client after consecutive token failures",
+		zap.String("client_ip", clientHost),
+		zap.Int("failures", l.limits.BlockAfterFailures),
+		zap.Time("blocked_until", state.blockedUntil))
+}
+
+// sweep forgets idle client IPs and ServiceAccounts whose blocks have ended, at most once per
+// minute. Must be called with the lock held.
+func (l *RateLimiter) sweep(now time.Time) {
+	if now.Sub(l.lastSweep) < time.Minute {
+		return
+	}
+	l.lastSweep = now
+
+	for host, state := range l.sources {
+		if now.Sub(state.lastSeen) > rateLimitIdleTimeout && !now.Before(state.blockedUntil) {
+			delete(l.sources, host)
+		}
+	}
+	for key, state := range l.serviceAccounts {
+		if now.Sub(state.lastSeen) > rateLimitIdleTimeout {
+			delete(l.serviceAccounts, key)
+		}
+	}
+	httpmetrics.SetBlockedSources(l.blockedCount(now))
+}
+
+// blockedCount returns the number of client IPs currently blocked. Must be called with the lock held.
+func (l *RateLimiter) blockedCount(now time.Time) int {
+	count := 0
+	for _, state := range l.sources {
+		if now.Before(state.blockedUntil) {
+			count++
+		}
+	}
+	return count
+}
+
+// newBucket returns a token bucket allowing perSecond requests with an equal burst, or nil when
+// perSecond is not positive
+func newBucket(perSecond int) *rate.Limiter {
+	if perSecond <= 0 {
+		return nil
+	}
+	return rate.NewLimiter(rate.Limit(perSecond), perSecond)
+}
+
+// tokenFailure reports whether a denial counts towards blocking the client IP
+func tokenFailure(reason string) bool {
+	return reason == ReasonMissingToken || reason == ReasonInvalidToken || reason == ReasonTokenRevoked
+}
@@ -0,0 +1,145 @@
+package auth
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/portswigger-tim/nats-k8s-oidc-callout/internal/jwt"
+	"github.com/portswigger-tim/nats-k8s-oidc-callout/internal/k8s"
+)
+
+// newRateLimitedHandler returns a handler accepting the token "valid" as shop/api, a pointer to
+// the number of tokens validated, and a function advancing the handler's clock
+func newRateLimitedHandler(limits RateLimits) (*Handler, *int, func(time.Duration)) {
+	validations := 0
+	jwtValidator := &mockJWTValidator{
+		validateFunc: func(token string) (*jwt.Claims, error) {
+			validations++
+			if token != "valid" {
+				return nil, errors.New("invalid token")
+			}
+			return &jwt.Claims{Namespace: "shop", ServiceAccount: "api"}, nil
+		},
+	}
+	permProvider := &mockPermissionsProvider{
+		lookupFunc: func(namespace, name string) (*k8s.Permissions, bool) {
+			return &k8s.Permissions{Publish: []string{"shop.>"}}, true
+		},
+	}
+
+	handler := NewHandler(jwtValidator, permProvider, zap.NewNop())
+	handler.SetRateLimiter(NewRateLimiter(limits, zap.NewNop()))
+	now := time.Date(2026, 7, 1, 12, 0, 0, 0, time.UTC)
+	handler.now = func() time.Time { return now }
+	return handler, &validations, func(d time.Duration) { now = now.Add(d) }
+}
+
+func TestHandler_Authorize_RateLimitPerSource(t *testing.T) {
+	handler, validations, advance := newRateLimitedHandler(RateLimits{PerSource: 2})
+
+	for i := 0; i < 2; i++ {
+		if resp := handler.Authorize(&AuthRequest{Token: "valid", ClientHost: "10.0.0.1"}); !resp.Allowed {
+			t.Fatalf("request %d: expected allowed, got reason %q", i, resp.Reason)
+		}
+	}
+
+	resp := handler.Authorize(&AuthRequest{Token: "valid", ClientHost: "10.0.0.1"})
+	if resp.Allowed || resp.Reason != ReasonRateLimited || resp.Detail != LimitSource {
+		t.Fatalf("Expected rate_limited/source denial, got allowed=%v reason=%q detail=%q", resp.Allowed, resp.Reason, resp.Detail)
+	}
+	if *validations != 2 {
+		t.Errorf("Validated %d tokens, want 2 (rate limited requests must not be validated)", *validations)
+	}
+
+	// Other clients have their own bucket
+	if resp := handler.Authorize(&AuthRequest{Token: "valid", ClientHost: "10.0.0.2"}); !resp.Allowed {
+		t.Errorf("Expected another client to be allowed, got reason %q", resp.Reason)
+	}
+
+	// The bucket refills over time
+	advance(time.Second)
+	if resp := handler.Authorize(&AuthRequest{Token: "valid", ClientHost: "10.0.0.1"}); !resp.Allowed {
+		t.Errorf("Expected allowed after refill, got reason %q", resp.Reason)
+	}
+}
+
+func TestHandler_Authorize_RateLimitPerServiceAccount(t *testing.T) {
+	handler, _, advance := newRateLimitedHandler(RateLimits{PerServiceAccount: 1})
+
+	if resp := handler.Authorize(&AuthRequest{Token: "valid", ClientHost: "10.0.0.1"}); !resp.Allowed {
+		t.Fatalf("Expected first request to be allowed, got reason %q", resp.Reason)
+	}
+
+	resp := handler.Authorize(&AuthRequest{Token: "valid", ClientHost: "10.0.0.2"})
+	if resp.Allowed || resp.Reason != ReasonRateLimited || resp.Detail != LimitServiceAccount {
+		t.Fatalf("Expected rate_limited/serviceaccount denial, got allowed=%v reason=%q detail=%q", resp.Allowed, resp.Reason, resp.Detail)
+	}
+	if resp.Namespace != "shop" || resp.ServiceAccount != "api" {
+		t.Errorf("Denial identity = %s/%s, want shop/api", resp.Namespace, resp.ServiceAccount)
+	}
+
+	advance(time.Second)
+	if resp := handler.Authorize(&AuthRequest{Token: "valid", ClientHost: "10.0.0.2"}); !resp.Allowed {
+		t.Errorf("Expected allowed after refill, got reason %q", resp.Reason)
+	}
+}
+
+func TestHandler_Authorize_BlockAfterFailures(t *testing.T) {
+	handler, validations, advance := newRateLimitedHandler(RateLimits{BlockAfterFailures: 3, BlockDuration: time.Minute})
+
+	// A success ends the failure streak
+	handler.Authorize(&AuthRequest{Token: "garbage", ClientHost: "10.0.0.1"})
+	handler.Authorize(&AuthRequest{Token: "", ClientHost: "10.0.0.1"})
+	handler.Authorize(&AuthRequest{Token: "valid", ClientHost: "10.0.0.1"})
+	handler.Authorize(&AuthRequest{Token: "garbage", ClientHost: "10.0.0.1"})
+	handler.Authorize(&AuthRequest{Token: "garbage", ClientHost: "10.0.0.1"})
+	if resp := handler.Authorize(&AuthRequest{Token: "valid", ClientHost: "10.0.0.1"}); !resp.Allowed {
+		t.Fatalf("Expected allowed after interrupted failure streak, got reason %q", resp.Reason)
+	}
+
+	for i := 0; i < 3; i++ {
+		handler.Authorize(&AuthRequest{Token: "garbage", ClientHost: "10.0.0.1"})
+	}
+	validated := *validations
+
+	resp := handler.Authorize(&AuthRequest{Token: "valid", ClientHost: "10.0.0.1"})
+	if resp.Allowed || resp.Reason != ReasonRateLimited || resp.Detail != LimitBlocked {
+		t.Fatalf("Expected rate_limited/blocked denial, got allowed=%v reason=%q detail=%q", resp.Allowed, resp.Reason, resp.Detail)
+	}
+	if *validations != validated {
+		t.Errorf("Blocked request was validated")
+	}
+
+	// Other clients are not affected
+	if resp := handler.Authorize(&AuthRequest{Token: "valid", ClientHost: "10.0.0.2"}); !resp.Allowed {
+		t.Errorf("Expected another client to be allowed, got reason %q", resp.Reason)
+	}
+
+	advance(time.Minute)
+	if resp := handler.Authorize(&AuthRequest{Token: "valid", ClientHost: "10.0.0.1"}); !resp.Allowed {
+		t.Errorf("Expected allowed after block expired, got reason %q", resp.Reason)
+	}
+}
+
+func TestRateLimiter_Sweep(t *testing.T) {
+	limiter := NewRateLimiter(RateLimits{PerSource: 1, PerServiceAccount: 1}, zap.NewNop())
+	now := time.Date(2026, 7, 1, 12, 0, 0, 0, time.UTC)
+
+	limiter.allowSource("10.0.0.1", now)
+	limiter.allowServiceAccount("shop", "api", now)
+
+	// Idle state is forgotten on the next sweep
+	limiter.allowSource("10.0.0.2", now.Add(rateLimitIdleTimeout+time.Minute))
+	if _, ok := limiter.sources["10.0.0.1"]; ok {
+		t.Error("Idle client IP was not swept")
+	}
+	if _, ok := limiter.serviceAccounts["shop/api"]; ok {
+		t.Error("Idle ServiceAccount was not swept")
+	}
+	if _, ok := limiter.sources["10.0.0.2"]; !ok {
+		t.Error("Active client IP was swept")
+	}
+}
@@ -3,9 +3,15 @@ package config
 
 import (
 	"fmt"
+	"net/netip"
 	"os"
 	"strconv"
+	"strings"
 	"time"
+
+	"github.com/nats-io/jwt/v2"
+
+	"github.com/portswigger-tim/nats-k8s-oidc-callout/internal/k8s"
 )
 
 // Config holds all application configuration loaded from environment variables.
@@ -27,6 +33,27 @@ type Config struct {
 	// This must be an account private key (starts with SA...)
 	NatsSigningKeyFile string
 
+	// NATS System Account (optional)
+	// Credentials for the system account, used to disconnect clients of disabled ServiceAccounts.
+	// Without them, existing connections stay open until their user JWT expires.
+	NatsSystemCredsFile string
+	// Disconnect clients whose ServiceAccount loses permissions or is deleted, not just when it is
+	// disabled. Requires NatsSystemCredsFile.
+	DisconnectOnPermissionChange bool
+	DisconnectRateLimit          int // Maximum client disconnects per second
+	// Keep an inventory of live connections from the system account's connection events, served
+	// at /connections. Requires NatsSystemCredsFile.
+	ConnectionInventory bool
+
+	// Audit events
+	AuditSubject    string // Subject each authorization decision is published to; empty disables publishing
+	AuditStream     string // JetStream stream capturing AuditSubject; empty publishes core NATS messages
+	AuditBufferSize int    // Events buffered before new events are dropped
+	// Audit log written separately from the operational log: "stdout" or a file path; empty disables it
+	AuditLog           string
+	AuditLogMaxSizeMB  int // Size at which the audit log file is rotated
+	AuditLogMaxBackups int // Rotated audit log files kept
+
 	// Kubernetes JWT Validation
 	JWKSUrl     string // JWKS URL (mutually exclusive with JWKSPath)
 	JWKSPath    string // JWKS file path (mutually exclusive with JWKSUrl)
@@ -36,6 +63,72 @@ type Config struct {
 	// ServiceAccount Annotation Settings
 	SAAnnotationPrefix string
 
+	// Default Permission Templates
+	// Subjects may contain {{.Namespace}}, {{.ServiceAccount}}, {{.Pod}} and {{.Cluster}} placeholders.
+	// Empty lists fall back to the built-in defaults.
+	DefaultPubSubjects []string
+	DefaultSubSubjects []string
+	ClusterName        string
+
+	// Strict Inbox Mode
+	// Omit the shared _INBOX.> subscription and grant only _INBOX_<namespace>_<serviceaccount>.>
+	StrictInbox bool
+
+	// Shadow Mode
+	// Evaluate the subject registry and strict inbox mode without enforcing them, everywhere or only
+	// in the listed namespaces, and report what they would change
+	ShadowMode       bool
+	ShadowNamespaces []string
+
+	// Response Permissions (defaults, overridable per ServiceAccount)
+	AllowResponses  bool          // Grant allow_responses to clients
+	ResponseMaxMsgs int           // Responses allowed per request (-1 = unlimited)
+	ResponseTTL     time.Duration // Time allowed to respond (0 = NATS server default)
+
+	// Source Network Restrictions (optional)
+	// CIDRs or addresses every client must connect from, e.g. the cluster's pod CIDR ranges
+	AllowedSourceCIDRs []netip.Prefix
+
+	// Connection Types (default, overridable per ServiceAccount)
+	// NATS connection types clients may use, e.g. STANDARD, WEBSOCKET, MQTT, LEAFNODE
+	AllowedConnectionTypes []string
+
+	// JetStream
+	// Domain used in $JS.<domain>.API subjects granted by the nats.io/jetstream-* annotations
+	JetStreamDomain string
+
+	// Pod Permissions (optional)
+	// How subject annotations on pods change their ServiceAccount's permissions: off, merge or narrow.
+	// Any mode other than off watches pods, which also enables nats.io/pod-rules.
+	PodPermissionsMode string
+
+	// Node Selectors (optional)
+	// Watch nodes so nats.io/allowed-node-selector can be enforced; requires node read access
+	WatchNodes bool
+
+	// Subject Prefix Registry (optional)
+	// ConfigMap in "namespace/name" form holding prefix ownership and wildcard rules
+	SubjectRegistryConfigMap string
+	SubjectRegistryKey       string
+
+	// Lockdown (optional)
+	// ConfigMap in "namespace/name" form whose key turns lockdown mode on and off
+	LockdownConfigMap string
+	LockdownKey       string
+	// File holding the bearer token for the /admin/lockdown and /connections endpoints; empty disables them
+	AdminTokenFile string
+
+	// Token revocation (optional)
+	// ConfigMap in "namespace/name" form whose key holds the revocation list
+	RevocationConfigMap string
+	RevocationKey       string
+
+	// Rate Limiting (optional, 0 disables each limit)
+	RateLimitPerSource         int           // Auth requests per second per client IP, checked before token validation
+	RateLimitPerServiceAccount int           // Auth requests per second per namespace/serviceaccount
+	BlockAfterFailures         int           // Consecutive token failures before a client IP is blocked
+	BlockDuration              time.Duration // How long a client IP stays blocked
+
 	// Cache & Cleanup
 	CacheCleanupInterval time.Duration
 
@@ -58,6 +151,41 @@ func Load() (*Config, error) {
 		LogLevel:             getEnv("LOG_LEVEL", "info"),
 		SAAnnotationPrefix:   getEnv("SA_ANNOTATION_PREFIX", "nats.io/"),
 		CacheCleanupInterval: getEnvDuration("CACHE_CLEANUP_INTERVAL", 15*time.Minute),
+		DefaultPubSubjects:   getEnvList("DEFAULT_PUB_SUBJECTS"),
+		DefaultSubSubjects:   getEnvList("DEFAULT_SUB_SUBJECTS"),
+		ClusterName:          getEnv("CLUSTER_NAME", ""),
+		SubjectRegistryKey:   getEnv("SUBJECT_REGISTRY_KEY", "registry.yaml"),
+		LockdownKey:          getEnv("LOCKDOWN_KEY", "lockdown.yaml"),
+		AdminTokenFile:       getEnv("ADMIN_TOKEN_FILE", ""),
+		RevocationKey:        getEnv("REVOCATION_KEY", "revocations.yaml"),
+		StrictInbox:          getEnvBool("STRICT_INBOX", false),
+		ShadowMode:           getEnvBool("SHADOW_MODE", false),
+		ShadowNamespaces:     getEnvList("SHADOW_NAMESPACES"),
+		AllowResponses:       getEnvBool("ALLOW_RESPONSES", true),
+		ResponseMaxMsgs:      getEnvInt("RESPONSE_MAX_MSGS", 1),
+		ResponseTTL:          getEnvDuration("RESPONSE_TTL", 0),
+		JetStreamDomain:      getEnv("JETSTREAM_DOMAIN", ""),
+		PodPermissionsMode:   strings.ToLower(getEnv("POD_PERMISSIONS_MODE", "off")),
+		WatchNodes:           getEnvBool("WATCH_NODES", false),
+
+		DisconnectOnPermissionChange: getEnvBool("DISCONNECT_ON_PERMISSION_CHANGE", false),
+		DisconnectRateLimit:          getEnvInt("DISCONNECT_RATE_LIMIT", 10),
+		ConnectionInventory:          getEnvBool("CONNECTION_INVENTORY", false),
+		AuditSubject:                 getEnv("AUDIT_SUBJECT", ""),
+		AuditStream:                  getEnv("AUDIT_STREAM", ""),
+		AuditBufferSize:              getEnvInt("AUDIT_BUFFER_SIZE", 1024),
+		AuditLog:                     getEnv("AUDIT_LOG", ""),
+		AuditLogMaxSizeMB:            getEnvInt("AUDIT_LOG_MAX_SIZE_MB", 100),
+		AuditLogMaxBackups:           getEnvInt("AUDIT_LOG_MAX_BACKUPS", 5),
+		RateLimitPerSource:           getEnvInt("RATE_LIMIT_PER_SOURCE", 0),
+		RateLimitPerServiceAccount:   getEnvInt("RATE_LIMIT_PER_SERVICEACCOUNT", 0),
+		BlockAfterFailures:           getEnvInt("BLOCK_AFTER_FAILURES", 0),
+		BlockDuration:                getEnvDuration("BLOCK_DURATION", 5*time.Minute),
+	}
+
+	cfg.AllowedConnectionTypes = getEnvList("ALLOWED_CONNECTION_TYPES")
+	if cfg.AllowedConnectionTypes == nil {
+		cfg.AllowedConnectionTypes = []string{jwt.ConnectionTypeStandard}
 	}
 
 	// NATS configuration with default URL
@@ -66,6 +194,7 @@ func Load() (*Config, error) {
 	// NATS authentication options (all optional - can use URL-embedded credentials)
 	cfg.NatsUserCredsFile = os.Getenv("NATS_USER_CREDS_FILE")
 	cfg.NatsToken = os.Getenv("NATS_TOKEN")
+	cfg.NatsSystemCredsFile = os.Getenv("NATS_SYSTEM_CREDS_FILE")
 
 	// Kubernetes JWT validation with conditional defaults for in-cluster deployments
 	cfg.JWKSPath = os.Getenv("JWKS_PATH")
@@ -78,6 +207,64 @@ func Load() (*Config, error) {
 	}
 	cfg.JWTAudience = getEnv("JWT_AUDIENCE", "nats")
 
+	if cfg.ResponseMaxMsgs == 0 || cfg.ResponseMaxMsgs < -1 {
+		return nil, fmt.Errorf("RESPONSE_MAX_MSGS must be a positive integer or -1 for unlimited, got %d", cfg.ResponseMaxMsgs)
+	}
+	if cfg.ResponseTTL < 0 {
+		return nil, fmt.Errorf("RESPONSE_TTL must not be negative, got %s", cfg.ResponseTTL)
+	}
+
+	sourceCIDRs, err := k8s.ParseSourceCIDRs(getEnvList("ALLOWED_SOURCE_CIDRS"))
+	if err != nil {
+		return nil, fmt.Errorf("ALLOWED_SOURCE_CIDRS: %w", err)
+	}
+	if len(sourceCIDRs) > 0 {
+		cfg.AllowedSourceCIDRs = sourceCIDRs
+	}
+
+	connectionTypes, err := k8s.ParseConnectionTypes(cfg.AllowedConnectionTypes)
+	if err != nil {
+		return nil, fmt.Errorf("ALLOWED_CONNECTION_TYPES: %w", err)
+	}
+	cfg.AllowedConnectionTypes = connectionTypes
+
+	if strings.ContainsAny(cfg.JetStreamDomain, ".*> \t") {
+		return nil, fmt.Errorf("JETSTREAM_DOMAIN must be a single subject token, got %q", cfg.JetStreamDomain)
+	}
+
+	switch cfg.PodPermissionsMode {
+	case "off", "merge", "narrow":
+	default:
+		return nil, fmt.Errorf("POD_PERMISSIONS_MODE must be off, merge or narrow, got %q", cfg.PodPermissionsMode)
+	}
+
+	// Subject registry ConfigMap must be namespace-qualified
+	cfg.SubjectRegistryConfigMap = os.Getenv("SUBJECT_REGISTRY_CONFIGMAP")
+	if cfg.SubjectRegistryConfigMap != "" {
+		namespace, name, ok := strings.Cut(cfg.SubjectRegistryConfigMap, "/")
+		if !ok || namespace == "" || name == "" {
+			return nil, fmt.Errorf("SUBJECT_REGISTRY_CONFIGMAP must be in namespace/name form, got %q", cfg.SubjectRegistryConfigMap)
+		}
+	}
+
+	// Lockdown ConfigMap must be namespace-qualified
+	cfg.LockdownConfigMap = os.Getenv("LOCKDOWN_CONFIGMAP")
+	if cfg.LockdownConfigMap != "" {
+		namespace, name, ok := strings.Cut(cfg.LockdownConfigMap, "/")
+		if !ok || namespace == "" || name == "" {
+			return nil, fmt.Errorf("LOCKDOWN_CONFIGMAP must be in namespace/name form, got %q", cfg.LockdownConfigMap)
+		}
+	}
+
+	// Revocation ConfigMap must be namespace-qualified
+	cfg.RevocationConfigMap = os.Getenv("REVOCATION_CONFIGMAP")
+	if cfg.RevocationConfigMap != "" {
+		namespace, name, ok := strings.Cut(cfg.RevocationConfigMap, "/")
+		if !ok || namespace == "" || name == "" {
+			return nil, fmt.Errorf("REVOCATION_CONFIGMAP must be in namespace/name form, got %q", cfg.RevocationConfigMap)
+		}
+	}
+
 	// Required variables (no reasonable defaults)
 	var missing []string
 
@@ -116,6 +303,40 @@ func Load() (*Config, error) {
 		return nil, fmt.Errorf("NATS_USER_CREDS_FILE and NATS_TOKEN are mutually exclusive; provide at most one")
 	}
 
+	if cfg.DisconnectOnPermissionChange && cfg.NatsSystemCredsFile == "" {
+		return nil, fmt.Errorf("DISCONNECT_ON_PERMISSION_CHANGE requires NATS_SYSTEM_CREDS_FILE")
+	}
+	if cfg.ConnectionInventory && cfg.NatsSystemCredsFile == "" {
+		return nil, fmt.Errorf("CONNECTION_INVENTORY requires NATS_SYSTEM_CREDS_FILE")
+	}
+	if cfg.DisconnectRateLimit <= 0 {
+		return nil, fmt.Errorf("DISCONNECT_RATE_LIMIT must be a positive integer, got %d", cfg.DisconnectRateLimit)
+	}
+	if cfg.AuditStream != "" && cfg.AuditSubject == "" {
+		return nil, fmt.Errorf("AUDIT_STREAM requires AUDIT_SUBJECT")
+	}
+	if cfg.AuditBufferSize <= 0 {
+		return nil, fmt.Errorf("AUDIT_BUFFER_SIZE must be a positive integer, got %d", cfg.AuditBufferSize)
+	}
+	if cfg.AuditLogMaxSizeMB <= 0 {
+		return nil, fmt.Errorf("AUDIT_LOG_MAX_SIZE_MB must be a positive integer, got %d", cfg.AuditLogMaxSizeMB)
+	}
+	if cfg.AuditLogMaxBackups < 0 {
+		return nil, fmt.Errorf("AUDIT_LOG_MAX_BACKUPS must not be negative, got %d", cfg.AuditLogMaxBackups)
+	}
+	if cfg.RateLimitPerSource < 0 {
+		return nil, fmt.Errorf("RATE_LIMIT_PER_SOURCE must not be negative, got %d", cfg.RateLimitPerSource)
+	}
+	if cfg.RateLimitPerServiceAccount < 0 {
+		return nil, fmt.Errorf("RATE_LIMIT_PER_SERVICEACCOUNT must not be negative, got %d", cfg.RateLimitPerServiceAccount)
+	}
+	if cfg.BlockAfterFailures < 0 {
+		return nil, fmt.Errorf("BLOCK_AFTER_FAILURES must not be negative, got %d", cfg.BlockAfterFailures)
+	}
+	if cfg.BlockAfterFailures > 0 && cfg.BlockDuration <= 0 {
+		return nil, fmt.Errorf("BLOCK_DURATION must be positive when BLOCK_AFTER_FAILURES is set, got %s", cfg.BlockDuration)
+	}
+
 	if len(missing) > 0 {
 		return nil, fmt.Errorf("missing required environment variables: %v", missing)
 	}
@@ -160,3 +381,20 @@ func getEnvDuration(key string, defaultValue time.Duration) time.Duration {
 	}
 	return defaultValue
 }
+
+// getEnvList returns the comma-separated values of an environment variable, or nil if unset.
+// Empty entries are skipped and surrounding whitespace is trimmed.
+func getEnvList(key string) []string {
+	value := os.Getenv(key)
+	if value == "" {
+		return nil
+	}
+
+	var list []string
+	for _, part := range strings.Split(value, ",") {
+		if trimmed := strings.TrimSpace(part); trimmed != "" {
+			list = append(list, trimmed)
+		}
+	}
+	return list
+}
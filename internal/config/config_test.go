@@ -1,7 +1,9 @@
 package config
 
 import (
+	"net/netip"
 	"os"
+	"slices"
 	"testing"
 	"time"
 )
@@ -23,18 +25,30 @@ func TestLoad(t *testing.T) {
 				// NATS_URL, JWKS_URL, JWT_ISSUER should use defaults
 			},
 			want: &Config{
-				Port:                 8080,
-				NatsURL:              "nats://nats:4222",
-				NatsSigningKeyFile:   "/etc/nats/auth.creds",
-				NatsAccount:          "TestAccount",
-				JWKSUrl:              "https://kubernetes.default.svc/openid/v1/jwks",
-				JWTIssuer:            "https://kubernetes.default.svc",
-				JWTAudience:          "nats",
-				SAAnnotationPrefix:   "nats.io/",
-				CacheCleanupInterval: 15 * time.Minute,
-				K8sInCluster:         true,
-				K8sNamespace:         "",
-				LogLevel:             "info",
+				Port:                   8080,
+				NatsURL:                "nats://nats:4222",
+				NatsSigningKeyFile:     "/etc/nats/auth.creds",
+				NatsAccount:            "TestAccount",
+				JWKSUrl:                "https://kubernetes.default.svc/openid/v1/jwks",
+				JWTIssuer:              "https://kubernetes.default.svc",
+				JWTAudience:            "nats",
+				SAAnnotationPrefix:     "nats.io/",
+				BlockDuration:          5 * time.Minute,
+				RevocationKey:          "revocations.yaml",
+				LockdownKey:            "lockdown.yaml",
+				AuditLogMaxBackups:     5,
+				AuditLogMaxSizeMB:      100,
+				AuditBufferSize:        1024,
+				DisconnectRateLimit:    10,
+				PodPermissionsMode:     "off",
+				AllowedConnectionTypes: []string{"STANDARD"},
+				ResponseMaxMsgs:        1,
+				AllowResponses:         true,
+				SubjectRegistryKey:     "registry.yaml",
+				CacheCleanupInterval:   15 * time.Minute,
+				K8sInCluster:           true,
+				K8sNamespace:           "",
+				LogLevel:               "info",
 			},
 			wantErr: false,
 		},
@@ -55,18 +69,30 @@ func TestLoad(t *testing.T) {
 				"CACHE_CLEANUP_INTERVAL": "30m",
 			},
 			want: &Config{
-				Port:                 9090,
-				NatsURL:              "nats://custom:4222",
-				NatsSigningKeyFile:   "/custom/creds",
-				NatsAccount:          "CustomAccount",
-				JWKSUrl:              "https://custom.example.com/jwks",
-				JWTIssuer:            "https://custom.example.com",
-				JWTAudience:          "custom-aud",
-				SAAnnotationPrefix:   "custom.io/",
-				CacheCleanupInterval: 30 * time.Minute,
-				K8sInCluster:         true,
-				K8sNamespace:         "test-ns",
-				LogLevel:             "debug",
+				Port:                   9090,
+				NatsURL:                "nats://custom:4222",
+				NatsSigningKeyFile:     "/custom/creds",
+				NatsAccount:            "CustomAccount",
+				JWKSUrl:                "https://custom.example.com/jwks",
+				JWTIssuer:              "https://custom.example.com",
+				JWTAudience:            "custom-aud",
+				SAAnnotationPrefix:     "custom.io/",
+				BlockDuration:          5 * time.Minute,
+				RevocationKey:          "revocations.yaml",
+				LockdownKey:            "lockdown.yaml",
+				AuditLogMaxBackups:     5,
+				AuditLogMaxSizeMB:      100,
+				AuditBufferSize:        1024,
+				DisconnectRateLimit:    10,
+				PodPermissionsMode:     "off",
+				AllowedConnectionTypes: []string{"STANDARD"},
+				ResponseMaxMsgs:        1,
+				AllowResponses:         true,
+				SubjectRegistryKey:     "registry.yaml",
+				CacheCleanupInterval:   30 * time.Minute,
+				K8sInCluster:           true,
+				K8sNamespace:           "test-ns",
+				LogLevel:               "debug",
 			},
 			wantErr: false,
 		},
@@ -80,18 +106,30 @@ func TestLoad(t *testing.T) {
 				"JWT_ISSUER":            "https://external.example.com",
 			},
 			want: &Config{
-				Port:                 8080,
-				NatsURL:              "nats://nats:4222",
-				NatsSigningKeyFile:   "/etc/nats/auth.creds",
-				NatsAccount:          "TestAccount",
-				JWKSUrl:              "https://external.example.com/jwks",
-				JWTIssuer:            "https://external.example.com",
-				JWTAudience:          "nats",
-				SAAnnotationPrefix:   "nats.io/",
-				CacheCleanupInterval: 15 * time.Minute,
-				K8sInCluster:         false,
-				K8sNamespace:         "",
-				LogLevel:             "info",
+				Port:                   8080,
+				NatsURL:                "nats://nats:4222",
+				NatsSigningKeyFile:     "/etc/nats/auth.creds",
+				NatsAccount:            "TestAccount",
+				JWKSUrl:                "https://external.example.com/jwks",
+				JWTIssuer:              "https://external.example.com",
+				JWTAudience:            "nats",
+				SAAnnotationPrefix:     "nats.io/",
+				BlockDuration:          5 * time.Minute,
+				RevocationKey:          "revocations.yaml",
+				LockdownKey:            "lockdown.yaml",
+				AuditLogMaxBackups:     5,
+				AuditLogMaxSizeMB:      100,
+				AuditBufferSize:        1024,
+				DisconnectRateLimit:    10,
+				PodPermissionsMode:     "off",
+				AllowedConnectionTypes: []string{"STANDARD"},
+				ResponseMaxMsgs:        1,
+				AllowResponses:         true,
+				SubjectRegistryKey:     "registry.yaml",
+				CacheCleanupInterval:   15 * time.Minute,
+				K8sInCluster:           false,
+				K8sNamespace:           "",
+				LogLevel:               "info",
 			},
 			wantErr: false,
 		},
@@ -153,18 +191,30 @@ func TestLoad(t *testing.T) {
 				"PORT":                  "invalid",
 			},
 			want: &Config{
-				Port:                 8080, // Falls back to default
-				NatsURL:              "nats://nats:4222",
-				NatsSigningKeyFile:   "/etc/nats/auth.creds",
-				NatsAccount:          "TestAccount",
-				JWKSUrl:              "https://kubernetes.default.svc/openid/v1/jwks",
-				JWTIssuer:            "https://kubernetes.default.svc",
-				JWTAudience:          "nats",
-				SAAnnotationPrefix:   "nats.io/",
-				CacheCleanupInterval: 15 * time.Minute,
-				K8sInCluster:         true,
-				K8sNamespace:         "",
-				LogLevel:             "info",
+				Port:                   8080, // Falls back to default
+				NatsURL:                "nats://nats:4222",
+				NatsSigningKeyFile:     "/etc/nats/auth.creds",
+				NatsAccount:            "TestAccount",
+				JWKSUrl:                "https://kubernetes.default.svc/openid/v1/jwks",
+				JWTIssuer:              "https://kubernetes.default.svc",
+				JWTAudience:            "nats",
+				SAAnnotationPrefix:     "nats.io/",
+				BlockDuration:          5 * time.Minute,
+				RevocationKey:          "revocations.yaml",
+				LockdownKey:            "lockdown.yaml",
+				AuditLogMaxBackups:     5,
+				AuditLogMaxSizeMB:      100,
+				AuditBufferSize:        1024,
+				DisconnectRateLimit:    10,
+				PodPermissionsMode:     "off",
+				AllowedConnectionTypes: []string{"STANDARD"},
+				ResponseMaxMsgs:        1,
+				AllowResponses:         true,
+				SubjectRegistryKey:     "registry.yaml",
+				CacheCleanupInterval:   15 * time.Minute,
+				K8sInCluster:           true,
+				K8sNamespace:           "",
+				LogLevel:               "info",
 			},
 			wantErr: false,
 		},
@@ -176,18 +226,30 @@ func TestLoad(t *testing.T) {
 				"K8S_IN_CLUSTER":        "invalid",
 			},
 			want: &Config{
-				Port:                 8080,
-				NatsURL:              "nats://nats:4222",
-				NatsSigningKeyFile:   "/etc/nats/auth.creds",
-				NatsAccount:          "TestAccount",
-				JWKSUrl:              "https://kubernetes.default.svc/openid/v1/jwks",
-				JWTIssuer:            "https://kubernetes.default.svc",
-				JWTAudience:          "nats",
-				SAAnnotationPrefix:   "nats.io/",
-				CacheCleanupInterval: 15 * time.Minute,
-				K8sInCluster:         true, // Falls back to default
-				K8sNamespace:         "",
-				LogLevel:             "info",
+				Port:                   8080,
+				NatsURL:                "nats://nats:4222",
+				NatsSigningKeyFile:     "/etc/nats/auth.creds",
+				NatsAccount:            "TestAccount",
+				JWKSUrl:                "https://kubernetes.default.svc/openid/v1/jwks",
+				JWTIssuer:              "https://kubernetes.default.svc",
+				JWTAudience:            "nats",
+				SAAnnotationPrefix:     "nats.io/",
+				BlockDuration:          5 * time.Minute,
+				RevocationKey:          "revocations.yaml",
+				LockdownKey:            "lockdown.yaml",
+				AuditLogMaxBackups:     5,
+				AuditLogMaxSizeMB:      100,
+				AuditBufferSize:        1024,
+				DisconnectRateLimit:    10,
+				PodPermissionsMode:     "off",
+				AllowedConnectionTypes: []string{"STANDARD"},
+				ResponseMaxMsgs:        1,
+				AllowResponses:         true,
+				SubjectRegistryKey:     "registry.yaml",
+				CacheCleanupInterval:   15 * time.Minute,
+				K8sInCluster:           true, // Falls back to default
+				K8sNamespace:           "",
+				LogLevel:               "info",
 			},
 			wantErr: false,
 		},
@@ -199,21 +261,776 @@ func TestLoad(t *testing.T) {
 				"CACHE_CLEANUP_INTERVAL": "invalid",
 			},
 			want: &Config{
-				Port:                 8080,
-				NatsURL:              "nats://nats:4222",
-				NatsSigningKeyFile:   "/etc/nats/auth.creds",
-				NatsAccount:          "TestAccount",
-				JWKSUrl:              "https://kubernetes.default.svc/openid/v1/jwks",
-				JWTIssuer:            "https://kubernetes.default.svc",
-				JWTAudience:          "nats",
-				SAAnnotationPrefix:   "nats.io/",
-				CacheCleanupInterval: 15 * time.Minute, // Falls back to default
+				Port:                   8080,
+				NatsURL:                "nats://nats:4222",
+				NatsSigningKeyFile:     "/etc/nats/auth.creds",
+				NatsAccount:            "TestAccount",
+				JWKSUrl:                "https://kubernetes.default.svc/openid/v1/jwks",
+				JWTIssuer:              "https://kubernetes.default.svc",
+				JWTAudience:            "nats",
+				SAAnnotationPrefix:     "nats.io/",
+				BlockDuration:          5 * time.Minute,
+				RevocationKey:          "revocations.yaml",
+				LockdownKey:            "lockdown.yaml",
+				AuditLogMaxBackups:     5,
+				AuditLogMaxSizeMB:      100,
+				AuditBufferSize:        1024,
+				DisconnectRateLimit:    10,
+				PodPermissionsMode:     "off",
+				AllowedConnectionTypes: []string{"STANDARD"},
+				ResponseMaxMsgs:        1,
+				AllowResponses:         true,
+				SubjectRegistryKey:     "registry.yaml",
+				CacheCleanupInterval:   15 * time.Minute, // Falls back to default
+				K8sInCluster:           true,
+				K8sNamespace:           "",
+				LogLevel:               "info",
+			},
+			wantErr: false,
+		},
+		{
+			name: "default permission templates",
+			envVars: map[string]string{
+				"NATS_SIGNING_KEY_FILE": "/etc/nats/auth.creds",
+				"NATS_ACCOUNT":          "TestAccount",
+				"DEFAULT_PUB_SUBJECTS":  "tenant.{{.Namespace}}.{{.ServiceAccount}}.>",
+				"DEFAULT_SUB_SUBJECTS":  " _INBOX_{{.Namespace}}_{{.ServiceAccount}}.> , tenant.{{.Namespace}}.>, ",
+				"CLUSTER_NAME":          "eu-west-1",
+			},
+			want: &Config{
+				Port:                   8080,
+				NatsURL:                "nats://nats:4222",
+				NatsSigningKeyFile:     "/etc/nats/auth.creds",
+				NatsAccount:            "TestAccount",
+				JWKSUrl:                "https://kubernetes.default.svc/openid/v1/jwks",
+				JWTIssuer:              "https://kubernetes.default.svc",
+				JWTAudience:            "nats",
+				SAAnnotationPrefix:     "nats.io/",
+				BlockDuration:          5 * time.Minute,
+				RevocationKey:          "revocations.yaml",
+				LockdownKey:            "lockdown.yaml",
+				AuditLogMaxBackups:     5,
+				AuditLogMaxSizeMB:      100,
+				AuditBufferSize:        1024,
+				DisconnectRateLimit:    10,
+				PodPermissionsMode:     "off",
+				AllowedConnectionTypes: []string{"STANDARD"},
+				ResponseMaxMsgs:        1,
+				AllowResponses:         true,
+				SubjectRegistryKey:     "registry.yaml",
+				DefaultPubSubjects:     []string{"tenant.{{.Namespace}}.{{.ServiceAccount}}.>"},
+				DefaultSubSubjects:     []string{"_INBOX_{{.Namespace}}_{{.ServiceAccount}}.>", "tenant.{{.Namespace}}.>"},
+				ClusterName:            "eu-west-1",
+				CacheCleanupInterval:   15 * time.Minute,
+				K8sInCluster:           true,
+				K8sNamespace:           "",
+				LogLevel:               "info",
+			},
+			wantErr: false,
+		},
+		{
+			name: "subject registry ConfigMap",
+			envVars: map[string]string{
+				"NATS_SIGNING_KEY_FILE":      "/etc/nats/auth.creds",
+				"NATS_ACCOUNT":               "TestAccount",
+				"SUBJECT_REGISTRY_CONFIGMAP": "nats-system/subject-registry",
+			},
+			want: &Config{
+				Port:                     8080,
+				NatsURL:                  "nats://nats:4222",
+				NatsSigningKeyFile:       "/etc/nats/auth.creds",
+				NatsAccount:              "TestAccount",
+				JWKSUrl:                  "https://kubernetes.default.svc/openid/v1/jwks",
+				JWTIssuer:                "https://kubernetes.default.svc",
+				JWTAudience:              "nats",
+				SAAnnotationPrefix:       "nats.io/",
+				BlockDuration:            5 * time.Minute,
+				RevocationKey:            "revocations.yaml",
+				LockdownKey:              "lockdown.yaml",
+				AuditLogMaxBackups:       5,
+				AuditLogMaxSizeMB:        100,
+				AuditBufferSize:          1024,
+				DisconnectRateLimit:      10,
+				PodPermissionsMode:       "off",
+				AllowedConnectionTypes:   []string{"STANDARD"},
+				ResponseMaxMsgs:          1,
+				AllowResponses:           true,
+				SubjectRegistryConfigMap: "nats-system/subject-registry",
+				SubjectRegistryKey:       "registry.yaml",
+				CacheCleanupInterval:     15 * time.Minute,
+				K8sInCluster:             true,
+				K8sNamespace:             "",
+				LogLevel:                 "info",
+			},
+			wantErr: false,
+		},
+		{
+			name: "subject registry ConfigMap without namespace",
+			envVars: map[string]string{
+				"NATS_SIGNING_KEY_FILE":      "/etc/nats/auth.creds",
+				"NATS_ACCOUNT":               "TestAccount",
+				"SUBJECT_REGISTRY_CONFIGMAP": "subject-registry",
+			},
+			wantErr: true,
+			errMsg:  "SUBJECT_REGISTRY_CONFIGMAP",
+		},
+		{
+			name: "lockdown",
+			envVars: map[string]string{
+				"NATS_SIGNING_KEY_FILE": "/etc/nats/auth.creds",
+				"NATS_ACCOUNT":          "TestAccount",
+				"LOCKDOWN_CONFIGMAP":    "nats-system/lockdown",
+				"LOCKDOWN_KEY":          "state.yaml",
+				"ADMIN_TOKEN_FILE":      "/etc/nats-auth/admin-token",
+			},
+			want: &Config{
+				Port:                   8080,
+				NatsURL:                "nats://nats:4222",
+				NatsSigningKeyFile:     "/etc/nats/auth.creds",
+				NatsAccount:            "TestAccount",
+				JWKSUrl:                "https://kubernetes.default.svc/openid/v1/jwks",
+				JWTIssuer:              "https://kubernetes.default.svc",
+				JWTAudience:            "nats",
+				SAAnnotationPrefix:     "nats.io/",
+				BlockDuration:          5 * time.Minute,
+				RevocationKey:          "revocations.yaml",
+				LockdownConfigMap:      "nats-system/lockdown",
+				LockdownKey:            "state.yaml",
+				AdminTokenFile:         "/etc/nats-auth/admin-token",
+				AuditLogMaxBackups:     5,
+				AuditLogMaxSizeMB:      100,
+				AuditBufferSize:        1024,
+				DisconnectRateLimit:    10,
+				PodPermissionsMode:     "off",
+				AllowedConnectionTypes: []string{"STANDARD"},
+				ResponseMaxMsgs:        1,
+				AllowResponses:         true,
+				SubjectRegistryKey:     "registry.yaml",
+				CacheCleanupInterval:   15 * time.Minute,
+				K8sInCluster:           true,
+				K8sNamespace:           "",
+				LogLevel:               "info",
+			},
+			wantErr: false,
+		},
+		{
+			name: "lockdown ConfigMap without namespace",
+			envVars: map[string]string{
+				"NATS_SIGNING_KEY_FILE": "/etc/nats/auth.creds",
+				"NATS_ACCOUNT":          "TestAccount",
+				"LOCKDOWN_CONFIGMAP":    "lockdown",
+			},
+			wantErr: true,
+			errMsg:  "LOCKDOWN_CONFIGMAP",
+		},
+		{
+			name: "revocation list",
+			envVars: map[string]string{
+				"NATS_SIGNING_KEY_FILE": "/etc/nats/auth.creds",
+				"NATS_ACCOUNT":          "TestAccount",
+				"REVOCATION_CONFIGMAP":  "nats-system/revocations",
+				"REVOCATION_KEY":        "list.yaml",
+			},
+			want: &Config{
+				Port:                   8080,
+				NatsURL:                "nats://nats:4222",
+				NatsSigningKeyFile:     "/etc/nats/auth.creds",
+				NatsAccount:            "TestAccount",
+				JWKSUrl:                "https://kubernetes.default.svc/openid/v1/jwks",
+				JWTIssuer:              "https://kubernetes.default.svc",
+				JWTAudience:            "nats",
+				SAAnnotationPrefix:     "nats.io/",
+				BlockDuration:          5 * time.Minute,
+				RevocationConfigMap:    "nats-system/revocations",
+				RevocationKey:          "list.yaml",
+				LockdownKey:            "lockdown.yaml",
+				AuditLogMaxBackups:     5,
+				AuditLogMaxSizeMB:      100,
+				AuditBufferSize:        1024,
+				DisconnectRateLimit:    10,
+				PodPermissionsMode:     "off",
+				AllowedConnectionTypes: []string{"STANDARD"},
+				ResponseMaxMsgs:        1,
+				AllowResponses:         true,
+				SubjectRegistryKey:     "registry.yaml",
+				CacheCleanupInterval:   15 * time.Minute,
+				K8sInCluster:           true,
+				K8sNamespace:           "",
+				LogLevel:               "info",
+			},
+			wantErr: false,
+		},
+		{
+			name: "revocation ConfigMap without namespace",
+			envVars: map[string]string{
+				"NATS_SIGNING_KEY_FILE": "/etc/nats/auth.creds",
+				"NATS_ACCOUNT":          "TestAccount",
+				"REVOCATION_CONFIGMAP":  "revocations",
+			},
+			wantErr: true,
+			errMsg:  "REVOCATION_CONFIGMAP",
+		},
+		{
+			name: "strict inbox mode",
+			envVars: map[string]string{
+				"NATS_SIGNING_KEY_FILE": "/etc/nats/auth.creds",
+				"NATS_ACCOUNT":          "TestAccount",
+				"STRICT_INBOX":          "true",
+			},
+			want: &Config{
+				Port:                   8080,
+				NatsURL:                "nats://nats:4222",
+				NatsSigningKeyFile:     "/etc/nats/auth.creds",
+				NatsAccount:            "TestAccount",
+				JWKSUrl:                "https://kubernetes.default.svc/openid/v1/jwks",
+				JWTIssuer:              "https://kubernetes.default.svc",
+				JWTAudience:            "nats",
+				SAAnnotationPrefix:     "nats.io/",
+				BlockDuration:          5 * time.Minute,
+				RevocationKey:          "revocations.yaml",
+				LockdownKey:            "lockdown.yaml",
+				AuditLogMaxBackups:     5,
+				AuditLogMaxSizeMB:      100,
+				AuditBufferSize:        1024,
+				DisconnectRateLimit:    10,
+				PodPermissionsMode:     "off",
+				AllowedConnectionTypes: []string{"STANDARD"},
+				ResponseMaxMsgs:        1,
+				AllowResponses:         true,
+				SubjectRegistryKey:     "registry.yaml",
+				StrictInbox:            true,
+				CacheCleanupInterval:   15 * time.Minute,
+				K8sInCluster:           true,
+				K8sNamespace:           "",
+				LogLevel:               "info",
+			},
+			wantErr: false,
+		},
+		{
+			name: "shadow mode",
+			envVars: map[string]string{
+				"NATS_SIGNING_KEY_FILE": "/etc/nats/auth.creds",
+				"NATS_ACCOUNT":          "TestAccount",
+				"STRICT_INBOX":          "true",
+				"SHADOW_MODE":           "true",
+				"SHADOW_NAMESPACES":     "payments, shop",
+			},
+			want: &Config{
+				Port:                   8080,
+				NatsURL:                "nats://nats:4222",
+				NatsSigningKeyFile:     "/etc/nats/auth.creds",
+				NatsAccount:            "TestAccount",
+				JWKSUrl:                "https://kubernetes.default.svc/openid/v1/jwks",
+				JWTIssuer:              "https://kubernetes.default.svc",
+				JWTAudience:            "nats",
+				SAAnnotationPrefix:     "nats.io/",
+				BlockDuration:          5 * time.Minute,
+				RevocationKey:          "revocations.yaml",
+				LockdownKey:            "lockdown.yaml",
+				AuditLogMaxBackups:     5,
+				AuditLogMaxSizeMB:      100,
+				AuditBufferSize:        1024,
+				DisconnectRateLimit:    10,
+				PodPermissionsMode:     "off",
+				AllowedConnectionTypes: []string{"STANDARD"},
+				ResponseMaxMsgs:        1,
+				AllowResponses:         true,
+				SubjectRegistryKey:     "registry.yaml",
+				StrictInbox:            true,
+				ShadowMode:             true,
+				ShadowNamespaces:       []string{"payments", "shop"},
+				CacheCleanupInterval:   15 * time.Minute,
+				K8sInCluster:           true,
+				K8sNamespace:           "",
+				LogLevel:               "info",
+			},
+			wantErr: false,
+		},
+		{
+			name: "response permission defaults",
+			envVars: map[string]string{
+				"NATS_SIGNING_KEY_FILE": "/etc/nats/auth.creds",
+				"NATS_ACCOUNT":          "TestAccount",
+				"RESPONSE_MAX_MSGS":     "-1",
+				"RESPONSE_TTL":          "5s",
+			},
+			want: &Config{
+				Port:                   8080,
+				NatsURL:                "nats://nats:4222",
+				NatsSigningKeyFile:     "/etc/nats/auth.creds",
+				NatsAccount:            "TestAccount",
+				JWKSUrl:                "https://kubernetes.default.svc/openid/v1/jwks",
+				JWTIssuer:              "https://kubernetes.default.svc",
+				JWTAudience:            "nats",
+				SAAnnotationPrefix:     "nats.io/",
+				BlockDuration:          5 * time.Minute,
+				RevocationKey:          "revocations.yaml",
+				LockdownKey:            "lockdown.yaml",
+				AuditLogMaxBackups:     5,
+				AuditLogMaxSizeMB:      100,
+				AuditBufferSize:        1024,
+				DisconnectRateLimit:    10,
+				PodPermissionsMode:     "off",
+				AllowedConnectionTypes: []string{"STANDARD"},
+				SubjectRegistryKey:     "registry.yaml",
+				AllowResponses:         true,
+				ResponseMaxMsgs:        -1,
+				ResponseTTL:            5 * time.Second,
+				CacheCleanupInterval:   15 * time.Minute,
+				K8sInCluster:           true,
+				K8sNamespace:           "",
+				LogLevel:               "info",
+			},
+			wantErr: false,
+		},
+		{
+			name: "invalid RESPONSE_MAX_MSGS",
+			envVars: map[string]string{
+				"NATS_SIGNING_KEY_FILE": "/etc/nats/auth.creds",
+				"NATS_ACCOUNT":          "TestAccount",
+				"RESPONSE_MAX_MSGS":     "-5",
+			},
+			wantErr: true,
+			errMsg:  "RESPONSE_MAX_MSGS",
+		},
+		{
+			name: "allowed source CIDRs",
+			envVars: map[string]string{
+				"NATS_SIGNING_KEY_FILE": "/etc/nats/auth.creds",
+				"NATS_ACCOUNT":          "TestAccount",
+				"ALLOWED_SOURCE_CIDRS":  "10.244.0.0/16, fd00::/64, 192.168.1.10",
+			},
+			want: &Config{
+				Port:                   8080,
+				NatsURL:                "nats://nats:4222",
+				NatsSigningKeyFile:     "/etc/nats/auth.creds",
+				NatsAccount:            "TestAccount",
+				JWKSUrl:                "https://kubernetes.default.svc/openid/v1/jwks",
+				JWTIssuer:              "https://kubernetes.default.svc",
+				JWTAudience:            "nats",
+				SAAnnotationPrefix:     "nats.io/",
+				BlockDuration:          5 * time.Minute,
+				RevocationKey:          "revocations.yaml",
+				LockdownKey:            "lockdown.yaml",
+				AuditLogMaxBackups:     5,
+				AuditLogMaxSizeMB:      100,
+				AuditBufferSize:        1024,
+				DisconnectRateLimit:    10,
+				PodPermissionsMode:     "off",
+				AllowedConnectionTypes: []string{"STANDARD"},
+				SubjectRegistryKey:     "registry.yaml",
+				AllowResponses:         true,
+				ResponseMaxMsgs:        1,
+				AllowedSourceCIDRs: []netip.Prefix{
+					netip.MustParsePrefix("10.244.0.0/16"),
+					netip.MustParsePrefix("fd00::/64"),
+					netip.MustParsePrefix("192.168.1.10/32"),
+				},
+				CacheCleanupInterval: 15 * time.Minute,
 				K8sInCluster:         true,
 				K8sNamespace:         "",
 				LogLevel:             "info",
 			},
 			wantErr: false,
 		},
+		{
+			name: "invalid ALLOWED_SOURCE_CIDRS",
+			envVars: map[string]string{
+				"NATS_SIGNING_KEY_FILE": "/etc/nats/auth.creds",
+				"NATS_ACCOUNT":          "TestAccount",
+				"ALLOWED_SOURCE_CIDRS":  "10.244.0.0/33",
+			},
+			wantErr: true,
+			errMsg:  "ALLOWED_SOURCE_CIDRS",
+		},
+		{
+			name: "allowed connection types",
+			envVars: map[string]string{
+				"NATS_SIGNING_KEY_FILE":    "/etc/nats/auth.creds",
+				"NATS_ACCOUNT":             "TestAccount",
+				"ALLOWED_CONNECTION_TYPES": "standard, WEBSOCKET",
+			},
+			want: &Config{
+				Port:                   8080,
+				NatsURL:                "nats://nats:4222",
+				NatsSigningKeyFile:     "/etc/nats/auth.creds",
+				NatsAccount:            "TestAccount",
+				JWKSUrl:                "https://kubernetes.default.svc/openid/v1/jwks",
+				JWTIssuer:              "https://kubernetes.default.svc",
+				JWTAudience:            "nats",
+				SAAnnotationPrefix:     "nats.io/",
+				BlockDuration:          5 * time.Minute,
+				RevocationKey:          "revocations.yaml",
+				LockdownKey:            "lockdown.yaml",
+				AuditLogMaxBackups:     5,
+				AuditLogMaxSizeMB:      100,
+				AuditBufferSize:        1024,
+				DisconnectRateLimit:    10,
+				PodPermissionsMode:     "off",
+				SubjectRegistryKey:     "registry.yaml",
+				AllowResponses:         true,
+				ResponseMaxMsgs:        1,
+				AllowedConnectionTypes: []string{"STANDARD", "WEBSOCKET"},
+				CacheCleanupInterval:   15 * time.Minute,
+				K8sInCluster:           true,
+				K8sNamespace:           "",
+				LogLevel:               "info",
+			},
+			wantErr: false,
+		},
+		{
+			name: "invalid ALLOWED_CONNECTION_TYPES",
+			envVars: map[string]string{
+				"NATS_SIGNING_KEY_FILE":    "/etc/nats/auth.creds",
+				"NATS_ACCOUNT":             "TestAccount",
+				"ALLOWED_CONNECTION_TYPES": "STANDARD,GATEWAY",
+			},
+			wantErr: true,
+			errMsg:  "ALLOWED_CONNECTION_TYPES",
+		},
+		{
+			name: "JetStream domain",
+			envVars: map[string]string{
+				"NATS_SIGNING_KEY_FILE": "/etc/nats/auth.creds",
+				"NATS_ACCOUNT":          "TestAccount",
+				"JETSTREAM_DOMAIN":      "hub",
+			},
+			want: &Config{
+				Port:                   8080,
+				NatsURL:                "nats://nats:4222",
+				NatsSigningKeyFile:     "/etc/nats/auth.creds",
+				NatsAccount:            "TestAccount",
+				JWKSUrl:                "https://kubernetes.default.svc/openid/v1/jwks",
+				JWTIssuer:              "https://kubernetes.default.svc",
+				JWTAudience:            "nats",
+				SAAnnotationPrefix:     "nats.io/",
+				BlockDuration:          5 * time.Minute,
+				RevocationKey:          "revocations.yaml",
+				LockdownKey:            "lockdown.yaml",
+				AuditLogMaxBackups:     5,
+				AuditLogMaxSizeMB:      100,
+				AuditBufferSize:        1024,
+				DisconnectRateLimit:    10,
+				PodPermissionsMode:     "off",
+				SubjectRegistryKey:     "registry.yaml",
+				AllowResponses:         true,
+				ResponseMaxMsgs:        1,
+				AllowedConnectionTypes: []string{"STANDARD"},
+				JetStreamDomain:        "hub",
+				CacheCleanupInterval:   15 * time.Minute,
+				K8sInCluster:           true,
+				K8sNamespace:           "",
+				LogLevel:               "info",
+			},
+			wantErr: false,
+		},
+		{
+			name: "invalid JETSTREAM_DOMAIN",
+			envVars: map[string]string{
+				"NATS_SIGNING_KEY_FILE": "/etc/nats/auth.creds",
+				"NATS_ACCOUNT":          "TestAccount",
+				"JETSTREAM_DOMAIN":      "hub.eu",
+			},
+			wantErr: true,
+			errMsg:  "JETSTREAM_DOMAIN",
+		},
+		{
+			name: "pod permissions mode",
+			envVars: map[string]string{
+				"NATS_SIGNING_KEY_FILE": "/etc/nats/auth.creds",
+				"NATS_ACCOUNT":          "TestAccount",
+				"POD_PERMISSIONS_MODE":  "Narrow",
+			},
+			want: &Config{
+				Port:                   8080,
+				NatsURL:                "nats://nats:4222",
+				NatsSigningKeyFile:     "/etc/nats/auth.creds",
+				NatsAccount:            "TestAccount",
+				JWKSUrl:                "https://kubernetes.default.svc/openid/v1/jwks",
+				JWTIssuer:              "https://kubernetes.default.svc",
+				JWTAudience:            "nats",
+				SAAnnotationPrefix:     "nats.io/",
+				BlockDuration:          5 * time.Minute,
+				RevocationKey:          "revocations.yaml",
+				LockdownKey:            "lockdown.yaml",
+				AuditLogMaxBackups:     5,
+				AuditLogMaxSizeMB:      100,
+				AuditBufferSize:        1024,
+				DisconnectRateLimit:    10,
+				PodPermissionsMode:     "narrow",
+				SubjectRegistryKey:     "registry.yaml",
+				AllowResponses:         true,
+				ResponseMaxMsgs:        1,
+				AllowedConnectionTypes: []string{"STANDARD"},
+				CacheCleanupInterval:   15 * time.Minute,
+				K8sInCluster:           true,
+				K8sNamespace:           "",
+				LogLevel:               "info",
+			},
+			wantErr: false,
+		},
+		{
+			name: "invalid POD_PERMISSIONS_MODE",
+			envVars: map[string]string{
+				"NATS_SIGNING_KEY_FILE": "/etc/nats/auth.creds",
+				"NATS_ACCOUNT":          "TestAccount",
+				"POD_PERMISSIONS_MODE":  "replace",
+			},
+			wantErr: true,
+			errMsg:  "POD_PERMISSIONS_MODE",
+		},
+		{
+			name: "watch nodes",
+			envVars: map[string]string{
+				"NATS_SIGNING_KEY_FILE": "/etc/nats/auth.creds",
+				"NATS_ACCOUNT":          "TestAccount",
+				"WATCH_NODES":           "true",
+			},
+			want: &Config{
+				Port:                   8080,
+				NatsURL:                "nats://nats:4222",
+				NatsSigningKeyFile:     "/etc/nats/auth.creds",
+				NatsAccount:            "TestAccount",
+				JWKSUrl:                "https://kubernetes.default.svc/openid/v1/jwks",
+				JWTIssuer:              "https://kubernetes.default.svc",
+				JWTAudience:            "nats",
+				SAAnnotationPrefix:     "nats.io/",
+				BlockDuration:          5 * time.Minute,
+				RevocationKey:          "revocations.yaml",
+				LockdownKey:            "lockdown.yaml",
+				AuditLogMaxBackups:     5,
+				AuditLogMaxSizeMB:      100,
+				AuditBufferSize:        1024,
+				DisconnectRateLimit:    10,
+				PodPermissionsMode:     "off",
+				WatchNodes:             true,
+				SubjectRegistryKey:     "registry.yaml",
+				AllowResponses:         true,
+				ResponseMaxMsgs:        1,
+				AllowedConnectionTypes: []string{"STANDARD"},
+				CacheCleanupInterval:   15 * time.Minute,
+				K8sInCluster:           true,
+				K8sNamespace:           "",
+				LogLevel:               "info",
+			},
+			wantErr: false,
+		},
+		{
+			name: "system account credentials",
+			envVars: map[string]string{
+				"NATS_SIGNING_KEY_FILE":           "/etc/nats/auth.creds",
+				"NATS_ACCOUNT":                    "TestAccount",
+				"NATS_SYSTEM_CREDS_FILE":          "/etc/nats/system.creds",
+				"DISCONNECT_ON_PERMISSION_CHANGE": "true",
+				"DISCONNECT_RATE_LIMIT":           "25",
+				"CONNECTION_INVENTORY":            "true",
+			},
+			want: &Config{
+				Port:                         8080,
+				NatsURL:                      "nats://nats:4222",
+				NatsSigningKeyFile:           "/etc/nats/auth.creds",
+				NatsSystemCredsFile:          "/etc/nats/system.creds",
+				DisconnectOnPermissionChange: true,
+				DisconnectRateLimit:          25,
+				ConnectionInventory:          true,
+				NatsAccount:                  "TestAccount",
+				JWKSUrl:                      "https://kubernetes.default.svc/openid/v1/jwks",
+				JWTIssuer:                    "https://kubernetes.default.svc",
+				JWTAudience:                  "nats",
+				SAAnnotationPrefix:           "nats.io/",
+				BlockDuration:                5 * time.Minute,
+				RevocationKey:                "revocations.yaml",
+				LockdownKey:                  "lockdown.yaml",
+				AuditLogMaxBackups:           5,
+				AuditLogMaxSizeMB:            100,
+				AuditBufferSize:              1024,
+				PodPermissionsMode:           "off",
+				SubjectRegistryKey:           "registry.yaml",
+				AllowResponses:               true,
+				ResponseMaxMsgs:              1,
+				AllowedConnectionTypes:       []string{"STANDARD"},
+				CacheCleanupInterval:         15 * time.Minute,
+				K8sInCluster:                 true,
+				K8sNamespace:                 "",
+				LogLevel:                     "info",
+			},
+			wantErr: false,
+		},
+		{
+			name: "DISCONNECT_ON_PERMISSION_CHANGE without system credentials",
+			envVars: map[string]string{
+				"NATS_SIGNING_KEY_FILE":           "/etc/nats/auth.creds",
+				"NATS_ACCOUNT":                    "TestAccount",
+				"DISCONNECT_ON_PERMISSION_CHANGE": "true",
+			},
+			wantErr: true,
+			errMsg:  "NATS_SYSTEM_CREDS_FILE",
+		},
+		{
+			name: "CONNECTION_INVENTORY without system credentials",
+			envVars: map[string]string{
+				"NATS_SIGNING_KEY_FILE": "/etc/nats/auth.creds",
+				"NATS_ACCOUNT":          "TestAccount",
+				"CONNECTION_INVENTORY":  "true",
+			},
+			wantErr: true,
+			errMsg:  "NATS_SYSTEM_CREDS_FILE",
+		},
+		{
+			name: "invalid DISCONNECT_RATE_LIMIT",
+			envVars: map[string]string{
+				"NATS_SIGNING_KEY_FILE": "/etc/nats/auth.creds",
+				"NATS_ACCOUNT":          "TestAccount",
+				"DISCONNECT_RATE_LIMIT": "0",
+			},
+			wantErr: true,
+			errMsg:  "DISCONNECT_RATE_LIMIT",
+		},
+		{
+			name: "audit stream",
+			envVars: map[string]string{
+				"NATS_SIGNING_KEY_FILE": "/etc/nats/auth.creds",
+				"NATS_ACCOUNT":          "TestAccount",
+				"AUDIT_SUBJECT":         "audit.nats.auth",
+				"AUDIT_STREAM":          "AUDIT",
+				"AUDIT_BUFFER_SIZE":     "4096",
+				"AUDIT_LOG":             "/var/log/nats-auth/audit.log",
+				"AUDIT_LOG_MAX_SIZE_MB": "10",
+				"AUDIT_LOG_MAX_BACKUPS": "0",
+			},
+			want: &Config{
+				Port:                   8080,
+				NatsURL:                "nats://nats:4222",
+				NatsSigningKeyFile:     "/etc/nats/auth.creds",
+				NatsAccount:            "TestAccount",
+				AuditSubject:           "audit.nats.auth",
+				AuditStream:            "AUDIT",
+				AuditBufferSize:        4096,
+				AuditLog:               "/var/log/nats-auth/audit.log",
+				AuditLogMaxSizeMB:      10,
+				AuditLogMaxBackups:     0,
+				JWKSUrl:                "https://kubernetes.default.svc/openid/v1/jwks",
+				JWTIssuer:              "https://kubernetes.default.svc",
+				JWTAudience:            "nats",
+				SAAnnotationPrefix:     "nats.io/",
+				BlockDuration:          5 * time.Minute,
+				RevocationKey:          "revocations.yaml",
+				LockdownKey:            "lockdown.yaml",
+				DisconnectRateLimit:    10,
+				PodPermissionsMode:     "off",
+				SubjectRegistryKey:     "registry.yaml",
+				AllowResponses:         true,
+				ResponseMaxMsgs:        1,
+				AllowedConnectionTypes: []string{"STANDARD"},
+				CacheCleanupInterval:   15 * time.Minute,
+				K8sInCluster:           true,
+				K8sNamespace:           "",
+				LogLevel:               "info",
+			},
+			wantErr: false,
+		},
+		{
+			name: "AUDIT_STREAM without AUDIT_SUBJECT",
+			envVars: map[string]string{
+				"NATS_SIGNING_KEY_FILE": "/etc/nats/auth.creds",
+				"NATS_ACCOUNT":          "TestAccount",
+				"AUDIT_STREAM":          "AUDIT",
+			},
+			wantErr: true,
+			errMsg:  "AUDIT_SUBJECT",
+		},
+		{
+			name: "invalid AUDIT_BUFFER_SIZE",
+			envVars: map[string]string{
+				"NATS_SIGNING_KEY_FILE": "/etc/nats/auth.creds",
+				"NATS_ACCOUNT":          "TestAccount",
+				"AUDIT_BUFFER_SIZE":     "-1",
+			},
+			wantErr: true,
+			errMsg:  "AUDIT_BUFFER_SIZE",
+		},
+		{
+			name: "invalid AUDIT_LOG_MAX_SIZE_MB",
+			envVars: map[string]string{
+				"NATS_SIGNING_KEY_FILE": "/etc/nats/auth.creds",
+				"NATS_ACCOUNT":          "TestAccount",
+				"AUDIT_LOG_MAX_SIZE_MB": "0",
+			},
+			wantErr: true,
+			errMsg:  "AUDIT_LOG_MAX_SIZE_MB",
+		},
+		{
+			name: "negative AUDIT_LOG_MAX_BACKUPS",
+			envVars: map[string]string{
+				"NATS_SIGNING_KEY_FILE": "/etc/nats/auth.creds",
+				"NATS_ACCOUNT":          "TestAccount",
+				"AUDIT_LOG_MAX_BACKUPS": "-1",
+			},
+			wantErr: true,
+			errMsg:  "AUDIT_LOG_MAX_BACKUPS",
+		},
+		{
+			name: "rate limits",
+			envVars: map[string]string{
+				"NATS_SIGNING_KEY_FILE":         "/etc/nats/auth.creds",
+				"NATS_ACCOUNT":                  "TestAccount",
+				"RATE_LIMIT_PER_SOURCE":         "20",
+				"RATE_LIMIT_PER_SERVICEACCOUNT": "50",
+				"BLOCK_AFTER_FAILURES":          "10",
+				"BLOCK_DURATION":                "15m",
+			},
+			want: &Config{
+				Port:                       8080,
+				NatsURL:                    "nats://nats:4222",
+				NatsSigningKeyFile:         "/etc/nats/auth.creds",
+				NatsAccount:                "TestAccount",
+				RateLimitPerSource:         20,
+				RateLimitPerServiceAccount: 50,
+				BlockAfterFailures:         10,
+				BlockDuration:              15 * time.Minute,
+				JWKSUrl:                    "https://kubernetes.default.svc/openid/v1/jwks",
+				JWTIssuer:                  "https://kubernetes.default.svc",
+				JWTAudience:                "nats",
+				SAAnnotationPrefix:         "nats.io/",
+				RevocationKey:              "revocations.yaml",
+				LockdownKey:                "lockdown.yaml",
+				AuditLogMaxBackups:         5,
+				AuditLogMaxSizeMB:          100,
+				AuditBufferSize:            1024,
+				DisconnectRateLimit:        10,
+				PodPermissionsMode:         "off",
+				SubjectRegistryKey:         "registry.yaml",
+				AllowResponses:             true,
+				ResponseMaxMsgs:            1,
+				AllowedConnectionTypes:     []string{"STANDARD"},
+				CacheCleanupInterval:       15 * time.Minute,
+				K8sInCluster:               true,
+				K8sNamespace:               "",
+				LogLevel:                   "info",
+			},
+			wantErr: false,
+		},
+		{
+			name: "negative RATE_LIMIT_PER_SOURCE",
+			envVars: map[string]string{
+				"NATS_SIGNING_KEY_FILE": "/etc/nats/auth.creds",
+				"NATS_ACCOUNT":          "TestAccount",
+				"RATE_LIMIT_PER_SOURCE": "-1",
+			},
+			wantErr: true,
+			errMsg:  "RATE_LIMIT_PER_SOURCE",
+		},
+		{
+			name: "BLOCK_AFTER_FAILURES without BLOCK_DURATION",
+			envVars: map[string]string{
+				"NATS_SIGNING_KEY_FILE": "/etc/nats/auth.creds",
+				"NATS_ACCOUNT":          "TestAccount",
+				"BLOCK_AFTER_FAILURES":  "5",
+				"BLOCK_DURATION":        "0s",
+			},
+			wantErr: true,
+			errMsg:  "BLOCK_DURATION",
+		},
 	}
 
 	for _, tt := range tests {
@@ -275,6 +1092,41 @@ func clearEnv() {
 		"K8S_IN_CLUSTER",
 		"K8S_NAMESPACE",
 		"LOG_LEVEL",
+		"DEFAULT_PUB_SUBJECTS",
+		"DEFAULT_SUB_SUBJECTS",
+		"CLUSTER_NAME",
+		"SUBJECT_REGISTRY_CONFIGMAP",
+		"SUBJECT_REGISTRY_KEY",
+		"STRICT_INBOX",
+		"ALLOW_RESPONSES",
+		"RESPONSE_MAX_MSGS",
+		"RESPONSE_TTL",
+		"ALLOWED_SOURCE_CIDRS",
+		"ALLOWED_CONNECTION_TYPES",
+		"JETSTREAM_DOMAIN",
+		"POD_PERMISSIONS_MODE",
+		"WATCH_NODES",
+		"NATS_SYSTEM_CREDS_FILE",
+		"DISCONNECT_ON_PERMISSION_CHANGE",
+		"DISCONNECT_RATE_LIMIT",
+		"CONNECTION_INVENTORY",
+		"AUDIT_SUBJECT",
+		"AUDIT_STREAM",
+		"AUDIT_BUFFER_SIZE",
+		"AUDIT_LOG",
+		"AUDIT_LOG_MAX_SIZE_MB",
+		"AUDIT_LOG_MAX_BACKUPS",
+		"SHADOW_MODE",
+		"SHADOW_NAMESPACES",
+		"LOCKDOWN_CONFIGMAP",
+		"LOCKDOWN_KEY",
+		"ADMIN_TOKEN_FILE",
+		"REVOCATION_CONFIGMAP",
+		"REVOCATION_KEY",
+		"RATE_LIMIT_PER_SOURCE",
+		"RATE_LIMIT_PER_SERVICEACCOUNT",
+		"BLOCK_AFTER_FAILURES",
+		"BLOCK_DURATION",
 	}
 	for _, v := range envVars {
 		os.Unsetenv(v)
@@ -321,6 +1173,124 @@ func compareConfig(t *testing.T, got, want *Config) {
 	if got.LogLevel != want.LogLevel {
 		t.Errorf("LogLevel = %v, want %v", got.LogLevel, want.LogLevel)
 	}
+	if !equalStringSlices(got.DefaultPubSubjects, want.DefaultPubSubjects) {
+		t.Errorf("DefaultPubSubjects = %v, want %v", got.DefaultPubSubjects, want.DefaultPubSubjects)
+	}
+	if !equalStringSlices(got.DefaultSubSubjects, want.DefaultSubSubjects) {
+		t.Errorf("DefaultSubSubjects = %v, want %v", got.DefaultSubSubjects, want.DefaultSubSubjects)
+	}
+	if got.ClusterName != want.ClusterName {
+		t.Errorf("ClusterName = %v, want %v", got.ClusterName, want.ClusterName)
+	}
+	if got.ShadowMode != want.ShadowMode {
+		t.Errorf("ShadowMode = %v, want %v", got.ShadowMode, want.ShadowMode)
+	}
+	if !equalStringSlices(got.ShadowNamespaces, want.ShadowNamespaces) {
+		t.Errorf("ShadowNamespaces = %v, want %v", got.ShadowNamespaces, want.ShadowNamespaces)
+	}
+	if got.StrictInbox != want.StrictInbox {
+		t.Errorf("StrictInbox = %v, want %v", got.StrictInbox, want.StrictInbox)
+	}
+	if got.AllowResponses != want.AllowResponses {
+		t.Errorf("AllowResponses = %v, want %v", got.AllowResponses, want.AllowResponses)
+	}
+	if got.ResponseMaxMsgs != want.ResponseMaxMsgs {
+		t.Errorf("ResponseMaxMsgs = %v, want %v", got.ResponseMaxMsgs, want.ResponseMaxMsgs)
+	}
+	if got.ResponseTTL != want.ResponseTTL {
+		t.Errorf("ResponseTTL = %v, want %v", got.ResponseTTL, want.ResponseTTL)
+	}
+	if !slices.Equal(got.AllowedSourceCIDRs, want.AllowedSourceCIDRs) {
+		t.Errorf("AllowedSourceCIDRs = %v, want %v", got.AllowedSourceCIDRs, want.AllowedSourceCIDRs)
+	}
+	if !equalStringSlices(got.AllowedConnectionTypes, want.AllowedConnectionTypes) {
+		t.Errorf("AllowedConnectionTypes = %v, want %v", got.AllowedConnectionTypes, want.AllowedConnectionTypes)
+	}
+	if got.JetStreamDomain != want.JetStreamDomain {
+		t.Errorf("JetStreamDomain = %v, want %v", got.JetStreamDomain, want.JetStreamDomain)
+	}
+	if got.PodPermissionsMode != want.PodPermissionsMode {
+		t.Errorf("PodPermissionsMode = %v, want %v", got.PodPermissionsMode, want.PodPermissionsMode)
+	}
+	if got.NatsSystemCredsFile != want.NatsSystemCredsFile {
+		t.Errorf("NatsSystemCredsFile = %v, want %v", got.NatsSystemCredsFile, want.NatsSystemCredsFile)
+	}
+	if got.DisconnectOnPermissionChange != want.DisconnectOnPermissionChange {
+		t.Errorf("DisconnectOnPermissionChange = %v, want %v", got.DisconnectOnPermissionChange, want.DisconnectOnPermissionChange)
+	}
+	if got.DisconnectRateLimit != want.DisconnectRateLimit {
+		t.Errorf("DisconnectRateLimit = %v, want %v", got.DisconnectRateLimit, want.DisconnectRateLimit)
+	}
+	if got.AuditSubject != want.AuditSubject {
+		t.Errorf("AuditSubject = %q, want %q", got.AuditSubject, want.AuditSubject)
+	}
+	if got.AuditStream != want.AuditStream {
+		t.Errorf("AuditStream = %q, want %q", got.AuditStream, want.AuditStream)
+	}
+	if got.AuditBufferSize != want.AuditBufferSize {
+		t.Errorf("AuditBufferSize = %d, want %d", got.AuditBufferSize, want.AuditBufferSize)
+	}
+	if got.AuditLog != want.AuditLog {
+		t.Errorf("AuditLog = %q, want %q", got.AuditLog, want.AuditLog)
+	}
+	if got.AuditLogMaxSizeMB != want.AuditLogMaxSizeMB {
+		t.Errorf("AuditLogMaxSizeMB = %d, want %d", got.AuditLogMaxSizeMB, want.AuditLogMaxSizeMB)
+	}
+	if got.AuditLogMaxBackups != want.AuditLogMaxBackups {
+		t.Errorf("AuditLogMaxBackups = %d, want %d", got.AuditLogMaxBackups, want.AuditLogMaxBackups)
+	}
+	if got.ConnectionInventory != want.ConnectionInventory {
+		t.Errorf("ConnectionInventory = %v, want %v", got.ConnectionInventory, want.ConnectionInventory)
+	}
+	if got.WatchNodes != want.WatchNodes {
+		t.Errorf("WatchNodes = %v, want %v", got.WatchNodes, want.WatchNodes)
+	}
+	if got.LockdownConfigMap != want.LockdownConfigMap {
+		t.Errorf("LockdownConfigMap = %q, want %q", got.LockdownConfigMap, want.LockdownConfigMap)
+	}
+	if got.LockdownKey != want.LockdownKey {
+		t.Errorf("LockdownKey = %q, want %q", got.LockdownKey, want.LockdownKey)
+	}
+	if got.AdminTokenFile != want.AdminTokenFile {
+		t.Errorf("AdminTokenFile = %q, want %q", got.AdminTokenFile, want.AdminTokenFile)
+	}
+	if got.RateLimitPerSource != want.RateLimitPerSource {
+		t.Errorf("RateLimitPerSource = %d, want %d", got.RateLimitPerSource, want.RateLimitPerSource)
+	}
+	if got.RateLimitPerServiceAccount != want.RateLimitPerServiceAccount {
+		t.Errorf("RateLimitPerServiceAccount = %d, want %d", got.RateLimitPerServiceAccount, want.RateLimitPerServiceAccount)
+	}
+	if got.BlockAfterFailures != want.BlockAfterFailures {
+		t.Errorf("BlockAfterFailures = %d, want %d", got.BlockAfterFailures, want.BlockAfterFailures)
+	}
+	if got.BlockDuration != want.BlockDuration {
+		t.Errorf("BlockDuration = %v, want %v", got.BlockDuration, want.BlockDuration)
+	}
+	if got.RevocationConfigMap != want.RevocationConfigMap {
+		t.Errorf("RevocationConfigMap = %q, want %q", got.RevocationConfigMap, want.RevocationConfigMap)
+	}
+	if got.RevocationKey != want.RevocationKey {
+		t.Errorf("RevocationKey = %q, want %q", got.RevocationKey, want.RevocationKey)
+	}
+	if got.SubjectRegistryConfigMap != want.SubjectRegistryConfigMap {
+		t.Errorf("SubjectRegistryConfigMap = %v, want %v", got.SubjectRegistryConfigMap, want.SubjectRegistryConfigMap)
+	}
+	if got.SubjectRegistryKey != want.SubjectRegistryKey {
+		t.Errorf("SubjectRegistryKey = %v, want %v", got.SubjectRegistryKey, want.SubjectRegistryKey)
+	}
+}
+
+// equalStringSlices compares two string slices element by element
+func equalStringSlices(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
 }
 
 // contains checks if a string contains a substring
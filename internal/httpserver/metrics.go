@@ -17,6 +17,129 @@ var (
 		},
 		[]string{"namespace", "serviceaccount", "annotation", "pattern"},
 	)
+
+	// invalidSubjectsTotal counts syntactically invalid NATS subjects rejected from permissions
+	invalidSubjectsTotal = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "nats_auth_invalid_subjects_total",
+			Help: "Total number of invalid NATS subjects rejected from ServiceAccount permissions",
+		},
+		[]string{"namespace", "serviceaccount", "source"},
+	)
+
+	// registryDeniedSubjectsTotal counts annotation subjects denied by the subject prefix registry
+	registryDeniedSubjectsTotal = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "nats_auth_registry_denied_subjects_total",
+			Help: "Total number of ServiceAccount annotation subjects denied by the subject prefix registry",
+		},
+		[]string{"namespace", "serviceaccount", "annotation", "reason"},
+	)
+
+	// sharedInboxAuthorizationsTotal counts clients authorized with the shared _INBOX.> subscription
+	sharedInboxAuthorizationsTotal = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "nats_auth_shared_inbox_authorizations_total",
+			Help: "Total number of clients authorized with the shared _INBOX.> subscription (not in strict inbox mode)",
+		},
+		[]string{"namespace", "serviceaccount"},
+	)
+
+	// permissionFormat reports the permission format each ServiceAccount uses
+	permissionFormat = promauto.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "nats_auth_permission_format",
+			Help: "Permission format used by each ServiceAccount (1 for the format in use: document, legacy or defaults)",
+		},
+		[]string{"namespace", "serviceaccount", "format"},
+	)
+
+	// permissionDocumentErrorsTotal counts permissions documents rejected by schema validation
+	permissionDocumentErrorsTotal = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "nats_auth_permission_document_errors_total",
+			Help: "Total number of invalid nats.io/permissions documents rejected from ServiceAccounts",
+		},
+		[]string{"namespace", "serviceaccount"},
+	)
+
+	// authDenialsTotal counts denied authorization requests by reason
+	authDenialsTotal = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "nats_auth_denials_total",
+			Help: "Total number of denied authorization requests by reason",
+		},
+		[]string{"reason"},
+	)
+
+	// disconnectedClientsTotal counts existing client connections disconnected through the system account
+	disconnectedClientsTotal = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "nats_auth_disconnected_clients_total",
+			Help: "Total number of existing client connections disconnected by reason",
+		},
+		[]string{"reason"},
+	)
+
+	// serviceAccountConnections tracks live connections per ServiceAccount from the connection inventory
+	serviceAccountConnections = promauto.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "nats_auth_connections",
+			Help: "Current number of client connections authorized for each ServiceAccount",
+		},
+		[]string{"namespace", "serviceaccount"},
+	)
+
+	// shadowDifferencesTotal counts subjects the candidate policy would change in shadow mode
+	shadowDifferencesTotal = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "nats_auth_shadow_differences_total",
+			Help: "Total number of subjects the candidate policy would remove or add when authorizing in shadow mode",
+		},
+		[]string{"namespace", "serviceaccount", "permission", "change"},
+	)
+
+	// lockdownActive reports whether the emergency lockdown is denying new connections
+	lockdownActive = promauto.NewGauge(
+		prometheus.GaugeOpts{
+			Name: "nats_auth_lockdown",
+			Help: "Whether lockdown mode is denying new connections outside the allowlist (1) or not (0)",
+		},
+	)
+
+	// rateLimitedTotal counts requests denied by the rate limiter before or instead of full validation
+	rateLimitedTotal = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "nats_auth_rate_limited_total",
+			Help: "Total number of auth requests denied by rate limits, by limit (source, serviceaccount or blocked)",
+		},
+		[]string{"limit"},
+	)
+
+	// sourceBlocksTotal counts client IPs blocked after consecutive token failures
+	sourceBlocksTotal = promauto.NewCounter(
+		prometheus.CounterOpts{
+			Name: "nats_auth_source_blocks_total",
+			Help: "Total number of client IPs temporarily blocked after consecutive token failures",
+		},
+	)
+
+	// blockedSources reports the client IPs currently blocked
+	blockedSources = promauto.NewGauge(
+		prometheus.GaugeOpts{
+			Name: "nats_auth_blocked_sources",
+			Help: "Number of client IPs currently blocked after consecutive token failures",
+		},
+	)
+
+	// auditEventsDroppedTotal counts audit events lost to a full buffer or a failed write
+	auditEventsDroppedTotal = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "nats_auth_audit_events_dropped_total",
+			Help: "Total number of audit events dropped by sink and reason",
+		},
+		[]string{"sink", "reason"},
+	)
 )
 
 // IncrementFilteredSubjects increments the counter for a filtered internal subject
@@ -33,3 +156,93 @@ func IncrementFilteredSubjects(namespace, serviceaccount, annotation, subject st
 		pattern,
 	).Inc()
 }
+
+// IncrementInvalidSubjects increments the counter for a rejected invalid subject.
+// source is the annotation the subject came from, or "defaults" for configured templates.
+func IncrementInvalidSubjects(namespace, serviceaccount, source string) {
+	invalidSubjectsTotal.WithLabelValues(namespace, serviceaccount, source).Inc()
+}
+
+// IncrementRegistryDeniedSubjects increments the counter for an annotation subject denied by the subject registry
+func IncrementRegistryDeniedSubjects(namespace, serviceaccount, annotation, reason string) {
+	registryDeniedSubjectsTotal.WithLabelValues(namespace, serviceaccount, annotation, reason).Inc()
+}
+
+// IncrementSharedInboxAuthorizations increments the counter for a client authorized with the shared inbox
+func IncrementSharedInboxAuthorizations(namespace, serviceaccount string) {
+	sharedInboxAuthorizationsTotal.WithLabelValues(namespace, serviceaccount).Inc()
+}
+
+// IncrementAuthDenials increments the counter for a denied authorization request
+func IncrementAuthDenials(reason string) {
+	authDenialsTotal.WithLabelValues(reason).Inc()
+}
+
+// AddDisconnectedClients adds to the counter of client connections disconnected for a reason
+func AddDisconnectedClients(reason string, count int) {
+	disconnectedClientsTotal.WithLabelValues(reason).Add(float64(count))
+}
+
+// SetServiceAccountConnections records the number of live connections for a ServiceAccount
+func SetServiceAccountConnections(namespace, serviceaccount string, count int) {
+	serviceAccountConnections.WithLabelValues(namespace, serviceaccount).Set(float64(count))
+}
+
+// DeleteServiceAccountConnections removes the connection gauge for a ServiceAccount with no connections
+func DeleteServiceAccountConnections(namespace, serviceaccount string) {
+	serviceAccountConnections.DeleteLabelValues(namespace, serviceaccount)
+}
+
+// AddShadowDifferences adds to the counter of subjects the candidate policy would change.
+// Zero counts are skipped so unchanged permission lists do not create series.
+func AddShadowDifferences(namespace, serviceaccount, permission, change string, count int) {
+	if count == 0 {
+		return
+	}
+	shadowDifferencesTotal.WithLabelValues(namespace, serviceaccount, permission, change).Add(float64(count))
+}
+
+// SetLockdown records whether lockdown mode is active
+func SetLockdown(active bool) {
+	if active {
+		lockdownActive.Set(1)
+		return
+	}
+	lockdownActive.Set(0)
+}
+
+// IncrementRateLimited increments the counter of requests denied by a rate limit
+func IncrementRateLimited(limit string) {
+	rateLimitedTotal.WithLabelValues(limit).Inc()
+}
+
+// IncrementSourceBlocks increments the counter of client IPs blocked after consecutive failures
+func IncrementSourceBlocks() {
+	sourceBlocksTotal.Inc()
+}
+
+// SetBlockedSources records the number of client IPs currently blocked
+func SetBlockedSources(count int) {
+	blockedSources.Set(float64(count))
+}
+
+// IncrementAuditEventsDropped increments the counter of audit events dropped by a sink
+func IncrementAuditEventsDropped(sink, reason string) {
+	auditEventsDroppedTotal.WithLabelValues(sink, reason).Inc()
+}
+
+// SetPermissionFormat records the permission format a ServiceAccount uses, replacing any previous format
+func SetPermissionFormat(namespace, serviceaccount, format string) {
+	permissionFormat.DeletePartialMatch(prometheus.Labels{"namespace": namespace, "serviceaccount": serviceaccount})
+	permissionFormat.WithLabelValues(namespace, serviceaccount, format).Set(1)
+}
+
+// DeletePermissionFormat removes the permission format series for a deleted ServiceAccount
+func DeletePermissionFormat(namespace, serviceaccount string) {
+	permissionFormat.DeletePartialMatch(prometheus.Labels{"namespace": namespace, "serviceaccount": serviceaccount})
+}
+
+// IncrementPermissionDocumentErrors increments the counter for a rejected permissions document
+func IncrementPermissionDocumentErrors(namespace, serviceaccount string) {
+	permissionDocumentErrorsTotal.WithLabelValues(namespace, serviceaccount).Inc()
+}
@@ -14,6 +14,7 @@ import (
 // Server provides HTTP endpoints for health checks and metrics.
 type Server struct {
 	httpServer *http.Server
+	mux        *http.ServeMux
 	logger     *zap.Logger
 }
 
@@ -34,6 +35,7 @@ func New(port int, logger *zap.Logger) *Server {
 			WriteTimeout: 10 * time.Second,
 			IdleTimeout:  120 * time.Second,
 		},
+		mux:    mux,
 		logger: logger,
 	}
 
@@ -44,6 +46,11 @@ func New(port int, logger *zap.Logger) *Server {
 	return s
 }
 
+// Handle registers an additional endpoint. Must be called before Start.
+func (s *Server) Handle(pattern string, handler http.Handler) {
+	s.mux.Handle(pattern, handler)
+}
+
 // Start begins listening for HTTP requests.
 // This is a blocking call that returns when the server shuts down.
 func (s *Server) Start() error {
@@ -24,6 +24,11 @@ type Validator struct {
 type Claims struct {
 	Namespace      string
 	ServiceAccount string
+	PodName        string // Empty when the token is not bound to a pod
+	PodUID         string
+	NodeName       string // Node the pod is scheduled on; empty when the token has no node claim
+	NodeUID        string
+	TokenID        string // jti claim; empty when the token has none
 	Issuer         string
 	Audience       []string
 	ExpiresAt      time.Time
@@ -276,6 +281,38 @@ func extractServiceAccountName(k8sMap map[string]interface{}) (string, error) {
 	return saName, nil
 }
 
+// extractBoundObjectName extracts the name of an optional bound object (e.g. pod) from kubernetes.io map.
+// Returns an empty string when the token is not bound to an object of that kind.
+func extractBoundObjectName(k8sMap map[string]interface{}, kind string) string {
+	objMap, ok := k8sMap[kind].(map[string]interface{})
+	if !ok {
+		return ""
+	}
+
+	name, ok := objMap["name"].(string)
+	if !ok {
+		return ""
+	}
+
+	return name
+}
+
+// extractBoundObjectUID extracts the UID of an optional bound object (e.g. node) from kubernetes.io map.
+// Returns an empty string when the token is not bound to an object of that kind.
+func extractBoundObjectUID(k8sMap map[string]interface{}, kind string) string {
+	objMap, ok := k8sMap[kind].(map[string]interface{})
+	if !ok {
+		return ""
+	}
+
+	uid, ok := objMap["uid"].(string)
+	if !ok {
+		return ""
+	}
+
+	return uid
+}
+
 // extractAudienceList extracts the audience claim and converts it to a string slice.
 func extractAudienceList(claims jwt.MapClaims) []string {
 	aud, ok := claims["aud"]
@@ -329,9 +366,16 @@ func (v *Validator) extractK8sClaims(claims jwt.MapClaims) (*Claims, error) {
 	result := &Claims{
 		Namespace:      namespace,
 		ServiceAccount: saName,
+		PodName:        extractBoundObjectName(k8sMap, "pod"),
+		PodUID:         extractBoundObjectUID(k8sMap, "pod"),
+		NodeName:       extractBoundObjectName(k8sMap, "node"),
+		NodeUID:        extractBoundObjectUID(k8sMap, "node"),
 		Issuer:         issuer,
 		Audience:       extractAudienceList(claims),
 	}
+	if jti, ok := claims["jti"].(string); ok {
+		result.TokenID = jti
+	}
 
 	// Extract time claims
 	if exp, ok := claims["exp"].(float64); ok {
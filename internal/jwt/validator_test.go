@@ -89,6 +89,26 @@ func TestValidateToken_ValidToken(t *testing.T) {
 	if claims.ServiceAccount != "hakawai-litellm-proxy" {
 		t.Errorf("expected service account 'hakawai-litellm-proxy', got %q", claims.ServiceAccount)
 	}
+
+	if claims.PodName != "hakawai-litellm-proxy-57456bb9cb-bwzxh" {
+		t.Errorf("expected pod name 'hakawai-litellm-proxy-57456bb9cb-bwzxh', got %q", claims.PodName)
+	}
+
+	if claims.PodUID != "989f1a6e-8af7-4740-93d8-206f8daf9a84" {
+		t.Errorf("expected pod uid '989f1a6e-8af7-4740-93d8-206f8daf9a84', got %q", claims.PodUID)
+	}
+
+	if claims.NodeName != "ip-10-15-179-190.eu-west-1.compute.internal" {
+		t.Errorf("expected node name 'ip-10-15-179-190.eu-west-1.compute.internal', got %q", claims.NodeName)
+	}
+
+	if claims.NodeUID != "ceb6b98b-f46f-448d-8a2d-4e036c36a243" {
+		t.Errorf("expected node uid 'ceb6b98b-f46f-448d-8a2d-4e036c36a243', got %q", claims.NodeUID)
+	}
+
+	if claims.TokenID != "1b20f55e-e39a-4010-96e3-5bba8e300ae7" {
+		t.Errorf("expected token id '1b20f55e-e39a-4010-96e3-5bba8e300ae7', got %q", claims.TokenID)
+	}
 }
 
 func TestValidateToken_ExpiredToken(t *testing.T) {
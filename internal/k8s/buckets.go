@@ -0,0 +1,127 @@
+package k8s
+
+import (
+	"fmt"
+	"strings"
+
+	"go.uber.org/zap"
+	corev1 "k8s.io/api/core/v1"
+)
+
+const (
+	// AnnotationKVBuckets grants access to Key-Value buckets as "bucket:mode" entries,
+	// where mode is ro or rw (e.g. "config:ro,sessions:rw").
+	AnnotationKVBuckets = "nats.io/kv-buckets"
+	// AnnotationObjectBuckets grants access to Object Store buckets as "bucket:mode" entries.
+	AnnotationObjectBuckets = "nats.io/object-buckets"
+
+	// Bucket access modes
+	bucketModeReadOnly  = "ro"
+	bucketModeReadWrite = "rw"
+)
+
+// validateBucketName checks a KV or Object Store bucket name (letters, digits, '-' and '_').
+func validateBucketName(name string) error {
+	if name == "" {
+		return fmt.Errorf("bucket name is empty")
+	}
+	for _, r := range name {
+		if !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '-' || r == '_') {
+			return fmt.Errorf("bucket name %q contains an invalid character", name)
+		}
+	}
+	return nil
+}
+
+// bucketAccess is the expansion of a single bucket annotation entry.
+type bucketAccess struct {
+	// stream is the bucket's backing stream, checked against the registry's stream ownership
+	stream string
+	// api holds the JetStream API and flow control subjects
+	api []string
+	// data holds the bucket subjects written to, checked against the registry's prefix ownership
+	data []string
+}
+
+// kvBucketSubjects returns the publish subjects for a Key-Value bucket, backed by stream KV_<bucket>.
+//   - ro: bucket info, gets (direct and API), and the named ephemeral ordered consumers created for
+//     watch, keys and history
+//   - rw: ro plus put, delete and purge markers on $KV.<bucket>.>
+func kvBucketSubjects(api, bucket, mode string) (bucketAccess, error) {
+	if mode != bucketModeReadOnly && mode != bucketModeReadWrite {
+		return bucketAccess{}, fmt.Errorf("unknown bucket access mode %q (expected ro or rw)", mode)
+	}
+
+	stream := "KV_" + bucket
+	access := bucketAccess{
+		stream: stream,
+		api: []string{
+			api + ".STREAM.INFO." + stream,
+			api + ".STREAM.MSG.GET." + stream,
+			api + ".DIRECT.GET." + stream,
+			api + ".DIRECT.GET." + stream + ".$KV." + bucket + ".>",
+			api + ".CONSUMER.CREATE." + stream,
+			api + ".CONSUMER.CREATE." + stream + ".>",
+			"$JS.FC." + stream + ".>",
+		},
+	}
+	if mode == bucketModeReadWrite {
+		access.data = []string{"$KV." + bucket + ".>"}
+	}
+
+	return access, nil
+}
+
+// objectBucketSubjects returns the publish subjects for an Object Store bucket, backed by stream OBJ_<bucket>.
+//   - ro: bucket info, metadata gets, and ephemeral ordered consumers for reading chunks, watch and list
+//   - rw: ro plus publishing chunks and metadata, and purging replaced or deleted chunks
+func objectBucketSubjects(api, bucket, mode string) (bucketAccess, error) {
+	if mode != bucketModeReadOnly && mode != bucketModeReadWrite {
+		return bucketAccess{}, fmt.Errorf("unknown bucket access mode %q (expected ro or rw)", mode)
+	}
+
+	stream := "OBJ_" + bucket
+	access := bucketAccess{
+		stream: stream,
+		api: []string{
+			api + ".STREAM.INFO." + stream,
+			api + ".STREAM.MSG.GET." + stream,
+			api + ".DIRECT.GET." + stream,
+			api + ".DIRECT.GET." + stream + ".$O." + bucket + ".M.>",
+			api + ".CONSUMER.CREATE." + stream,
+			api + ".CONSUMER.CREATE." + stream + ".>",
+			"$JS.FC." + stream + ".>",
+		},
+	}
+	if mode == bucketModeReadWrite {
+		access.api = append(access.api, api+".STREAM.PURGE."+stream)
+		access.data = []string{"$O." + bucket + ".>"}
+	}
+
+	return access, nil
+}
+
+// bucketSubjects expands "bucket:mode" entries from a bucket annotation using the given expansion.
+// Invalid entries and backing streams the registry does not permit are logged and skipped, and
+// data subjects are subject to the registry's prefix ownership.
+func bucketSubjects(sa *corev1.ServiceAccount, annotation, api string, expand func(api, bucket, mode string) (bucketAccess, error), registry *SubjectRegistry, logger *zap.Logger) []string {
+	var subjects []string
+	for _, entry := range splitAnnotationList(sa.Annotations[annotation]) {
+		bucket, mode, _ := strings.Cut(entry, ":")
+		if err := validateBucketName(bucket); err != nil {
+			logInvalidAnnotation(sa, annotation, entry, err, logger)
+			continue
+		}
+		access, err := expand(api, bucket, strings.ToLower(mode))
+		if err != nil {
+			logInvalidAnnotation(sa, annotation, entry, err, logger)
+			continue
+		}
+		if !streamPermitted(sa, registry, annotation, access.stream, logger) {
+			continue
+		}
+		subjects = append(subjects, access.api...)
+		subjects = append(subjects, enforceRegistry(sa, registry, annotation, access.data, logger)...)
+	}
+	return subjects
+}
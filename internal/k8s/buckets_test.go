@@ -0,0 +1,160 @@
+package k8s
+
+import (
+	"testing"
+
+	"go.uber.org/zap"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// TestCache_BucketAnnotations tests expanding KV and Object Store bucket annotations
+func TestCache_BucketAnnotations(t *testing.T) {
+	registry, err := ParseSubjectRegistry([]byte(`
+prefixes:
+  - prefix: $KV.sessions
+    owner: identity
+streams:
+  - stream: KV_config
+    owner: shop
+  - stream: KV_sessions
+    owner: shop
+  - stream: OBJ_assets
+    owner: media
+`))
+	if err != nil {
+		t.Fatalf("Failed to parse registry: %v", err)
+	}
+	registryOpts := DefaultOptions()
+	registryOpts.Registry = registry
+
+	tests := []struct {
+		name        string
+		opts        Options
+		annotations map[string]string
+		wantPub     []string
+	}{
+		{
+			name:        "Read-only KV bucket",
+			opts:        DefaultOptions(),
+			annotations: map[string]string{"nats.io/kv-buckets": "config:ro"},
+			wantPub: []string{
+				"shop.>",
+				"$JS.API.STREAM.INFO.KV_config",
+				"$JS.API.STREAM.MSG.GET.KV_config",
+				"$JS.API.DIRECT.GET.KV_config",
+				"$JS.API.DIRECT.GET.KV_config.$KV.config.>",
+				"$JS.API.CONSUMER.CREATE.KV_config",
+				"$JS.API.CONSUMER.CREATE.KV_config.>",
+				"$JS.FC.KV_config.>",
+			},
+		},
+		{
+			name:        "Read-write KV bucket in a domain",
+			opts:        Options{JetStreamDomain: "hub"},
+			annotations: map[string]string{"nats.io/kv-buckets": "sessions:rw"},
+			wantPub: []string{
+				"shop.>",
+				"$JS.hub.API.STREAM.INFO.KV_sessions",
+				"$JS.hub.API.STREAM.MSG.GET.KV_sessions",
+				"$JS.hub.API.DIRECT.GET.KV_sessions",
+				"$JS.hub.API.DIRECT.GET.KV_sessions.$KV.sessions.>",
+				"$JS.hub.API.CONSUMER.CREATE.KV_sessions",
+				"$JS.hub.API.CONSUMER.CREATE.KV_sessions.>",
+				"$JS.FC.KV_sessions.>",
+				"$KV.sessions.>",
+			},
+		},
+		{
+			name:        "Read-write Object Store bucket",
+			opts:        DefaultOptions(),
+			annotations: map[string]string{"nats.io/object-buckets": "assets:rw"},
+			wantPub: []string{
+				"shop.>",
+				"$JS.API.STREAM.INFO.OBJ_assets",
+				"$JS.API.STREAM.MSG.GET.OBJ_assets",
+				"$JS.API.DIRECT.GET.OBJ_assets",
+				"$JS.API.DIRECT.GET.OBJ_assets.$O.assets.M.>",
+				"$JS.API.CONSUMER.CREATE.OBJ_assets",
+				"$JS.API.CONSUMER.CREATE.OBJ_assets.>",
+				"$JS.FC.OBJ_assets.>",
+				"$JS.API.STREAM.PURGE.OBJ_assets",
+				"$O.assets.>",
+			},
+		},
+		{
+			name: "Invalid entries skipped",
+			opts: DefaultOptions(),
+			annotations: map[string]string{
+				"nats.io/kv-buckets":     "config, config:write, con.fig:ro",
+				"nats.io/object-buckets": "assets:*, as sets:ro",
+			},
+			wantPub: []string{"shop.>"},
+		},
+		{
+			name:        "Registry drops bucket on foreign stream",
+			opts:        registryOpts,
+			annotations: map[string]string{"nats.io/object-buckets": "assets:ro", "nats.io/kv-buckets": "cache:ro"},
+			wantPub:     []string{"shop.>"},
+		},
+		{
+			name:        "Registry drops data subjects under foreign prefix",
+			opts:        registryOpts,
+			annotations: map[string]string{"nats.io/kv-buckets": "sessions:rw"},
+			wantPub: []string{
+				"shop.>",
+				"$JS.API.STREAM.INFO.KV_sessions",
+				"$JS.API.STREAM.MSG.GET.KV_sessions",
+				"$JS.API.DIRECT.GET.KV_sessions",
+				"$JS.API.DIRECT.GET.KV_sessions.$KV.sessions.>",
+				"$JS.API.CONSUMER.CREATE.KV_sessions",
+				"$JS.API.CONSUMER.CREATE.KV_sessions.>",
+				"$JS.FC.KV_sessions.>",
+			},
+		},
+		{
+			name:        "Registry allows owned bucket",
+			opts:        registryOpts,
+			annotations: map[string]string{"nats.io/kv-buckets": "config:rw"},
+			wantPub: []string{
+				"shop.>",
+				"$JS.API.STREAM.INFO.KV_config",
+				"$JS.API.STREAM.MSG.GET.KV_config",
+				"$JS.API.DIRECT.GET.KV_config",
+				"$JS.API.DIRECT.GET.KV_config.$KV.config.>",
+				"$JS.API.CONSUMER.CREATE.KV_config",
+				"$JS.API.CONSUMER.CREATE.KV_config.>",
+				"$JS.FC.KV_config.>",
+				"$KV.config.>",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cache := NewCacheWithOptions(zap.NewNop(), tt.opts)
+			cache.upsert(&corev1.ServiceAccount{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:        "api",
+					Namespace:   "shop",
+					Annotations: tt.annotations,
+				},
+			})
+
+			pubPerms, subPerms, found := cache.Get("shop", "api")
+			if !found {
+				t.Fatal("Expected ServiceAccount to be in cache after upsert")
+			}
+
+			if !equalStringSlices(pubPerms, tt.wantPub) {
+				t.Errorf("pubPerms = %v, want %v", pubPerms, tt.wantPub)
+			}
+
+			// Bucket reads are delivered to the client's inbox, so no extra subscriptions are granted
+			wantSub := []string{"_INBOX.>", "_INBOX_shop_api.>", "shop.>"}
+			if !equalStringSlices(subPerms, wantSub) {
+				t.Errorf("subPerms = %v, want %v", subPerms, wantSub)
+			}
+		})
+	}
+}
@@ -3,12 +3,16 @@ package k8s
 
 import (
 	"fmt"
+	"net/netip"
+	"strconv"
 	"strings"
 	"sync"
+	"time"
 
 	httpmetrics "github.com/portswigger-tim/nats-k8s-oidc-callout/internal/httpserver"
 	"go.uber.org/zap"
 	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/labels"
 )
 
 const (
@@ -16,26 +20,70 @@ const (
 	AnnotationAllowedPubSubjects = "nats.io/allowed-pub-subjects"
 	// AnnotationAllowedSubSubjects is the annotation key for allowed NATS subscribe subjects.
 	AnnotationAllowedSubSubjects = "nats.io/allowed-sub-subjects"
+	// AnnotationStrictInbox overrides the global strict inbox mode for a ServiceAccount ("true" or "false").
+	AnnotationStrictInbox = "nats.io/strict-inbox"
+
+	// sourceDefaults identifies the configured default templates in logs and metrics
+	sourceDefaults = "defaults"
 )
 
-// Permissions represents the NATS publish and subscribe permissions for a ServiceAccount
+// Permissions represents the NATS permissions for a ServiceAccount
 type Permissions struct {
 	Publish   []string
 	Subscribe []string
+	// SubscribeQueues allows subscribing to subjects only within a queue group
+	SubscribeQueues []QueueSubscription
+	// PublishDeny and SubscribeDeny remove subjects from the allowed lists
+	PublishDeny   []string
+	SubscribeDeny []string
+	// Limits sets per-connection limits; nil leaves connections unlimited
+	Limits *Limits
+	// PodMode controls how subject annotations on the ServiceAccount's pods change its permissions
+	PodMode string
+	// PodRules grant extra subjects to pods matching a label selector or owning workload
+	PodRules []PodRule
+	// Format is the permission format the ServiceAccount uses (document, legacy or defaults)
+	Format string
+	// Response allows replying to received requests; nil disables response permissions
+	Response *ResponsePermission
+	// SourceCIDRs restricts the client networks allowed to connect; empty allows any source
+	SourceCIDRs []netip.Prefix
+	// ConnectionTypes lists the NATS connection types allowed; empty allows any type
+	ConnectionTypes []string
+	// AllowedTimes restricts when clients may connect; nil allows any time
+	AllowedTimes *AllowedTimes
+	// NodeSelector restricts the nodes the client's pod may run on; nil allows any node
+	NodeSelector labels.Selector
+	// Disabled denies all connections; DisabledUntil denies them until the given time
+	Disabled      bool
+	DisabledUntil time.Time
+	// Candidate holds the permissions the candidate policy would issue, set only in shadow mode
+	Candidate *Permissions
 }
 
 // Cache is a thread-safe in-memory cache of ServiceAccount permissions
 type Cache struct {
 	mu     sync.RWMutex
 	cache  map[string]*Permissions // key: "namespace/name"
+	opts   Options
 	logger *zap.Logger
+	// onRevoke is called when a ServiceAccount's existing clients should be disconnected; nil if not set
+	onRevoke func(namespace, name, reason string)
+	now      func() time.Time
 }
 
-// NewCache creates a new empty ServiceAccount cache
+// NewCache creates a new empty ServiceAccount cache using the default permission model
 func NewCache(logger *zap.Logger) *Cache {
+	return NewCacheWithOptions(logger, DefaultOptions())
+}
+
+// NewCacheWithOptions creates a new empty ServiceAccount cache with custom permission options
+func NewCacheWithOptions(logger *zap.Logger, opts Options) *Cache {
 	return &Cache{
 		cache:  make(map[string]*Permissions),
+		opts:   opts.withDefaults(),
 		logger: logger,
+		now:    time.Now,
 	}
 }
 
@@ -66,14 +114,42 @@ func (c *Cache) Get(namespace, name string) (pubPerms, subPerms []string, found
 	return perms.Publish, perms.Subscribe, true
 }
 
+// Lookup retrieves the full permissions for a ServiceAccount by namespace and name.
+// The returned Permissions are shared with the cache and must not be modified.
+func (c *Cache) Lookup(namespace, name string) (*Permissions, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	perms, found := c.cache[makeKey(namespace, name)]
+	return perms, found
+}
+
 // upsert adds or updates a ServiceAccount in the cache
 func (c *Cache) upsert(sa *corev1.ServiceAccount) {
 	c.mu.Lock()
 	defer c.mu.Unlock()
 
 	key := makeKey(sa.Namespace, sa.Name)
-	perms := buildPermissions(sa, c.logger)
+	perms := c.build(sa)
+	previous, existed := c.cache[key]
 	c.cache[key] = perms
+	httpmetrics.SetPermissionFormat(sa.Namespace, sa.Name, perms.Format)
+
+	// Disconnect existing clients when a ServiceAccount becomes disabled or loses permissions
+	now := c.now()
+	switch {
+	case perms.DisabledAt(now) && (!existed || !previous.DisabledAt(now)):
+		c.logger.Info("ServiceAccount disabled",
+			zap.String("namespace", sa.Namespace),
+			zap.String("name", sa.Name),
+			zap.Time("disabled_until", perms.DisabledUntil))
+		c.revoke(sa.Namespace, sa.Name, RevokeDisabled)
+	case existed && permissionsReduced(previous, perms):
+		c.logger.Debug("ServiceAccount permissions reduced",
+			zap.String("namespace", sa.Namespace),
+			zap.String("name", sa.Name))
+		c.revoke(sa.Namespace, sa.Name, RevokePermissionsReduced)
+	}
 
 	c.logger.Debug("ServiceAccount added to cache",
 		zap.String("namespace", sa.Namespace),
@@ -84,66 +160,344 @@ func (c *Cache) upsert(sa *corev1.ServiceAccount) {
 		zap.Int("cache_size", len(c.cache)))
 }
 
+// build builds a ServiceAccount's permissions. In shadowed namespaces the issued permissions are
+// built without the candidate policy, which is kept in Candidate for comparison. The candidate
+// build uses candidateLogger, so what it drops is neither logged nor counted a second time, nor
+// reported as denied when only the candidate policy denies it.
+// The caller must hold the lock.
+func (c *Cache) build(sa *corev1.ServiceAccount) *Permissions {
+	if !c.opts.shadowed(sa.Namespace) {
+		return buildPermissions(sa, c.opts, c.logger)
+	}
+
+	current := buildPermissions(sa, c.opts.withoutCandidatePolicy(), c.logger)
+	current.Candidate = buildPermissions(sa, c.opts, candidateLogger)
+	return current
+}
+
+// candidateLogger builds and refines the candidate permissions in shadow mode. It discards logs,
+// and countDrops reports false for it so drops are not counted in metrics.
+var candidateLogger = zap.NewNop()
+
+// countDrops reports whether subjects dropped while building permissions with the logger are
+// counted in metrics.
+func countDrops(logger *zap.Logger) bool {
+	return logger != candidateLogger
+}
+
+// SetSubjectRegistry replaces the subject registry used when building permissions.
+// Existing entries keep their permissions until the ServiceAccount is upserted again.
+func (c *Cache) SetSubjectRegistry(registry *SubjectRegistry) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.opts.Registry = registry
+}
+
+// SetRevokeHandler registers a function called with a Revoke* reason when a ServiceAccount's
+// existing clients should be disconnected: when it is disabled, loses permissions or is deleted.
+// The function runs in its own goroutine.
+func (c *Cache) SetRevokeHandler(fn func(namespace, name, reason string)) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.onRevoke = fn
+}
+
+// revoke calls the revoke handler asynchronously, since disconnecting makes network calls and
+// the cache lock is held. The caller must hold the lock.
+func (c *Cache) revoke(namespace, name, reason string) {
+	if c.onRevoke != nil {
+		go c.onRevoke(namespace, name, reason)
+	}
+}
+
 // delete removes a ServiceAccount from the cache
 func (c *Cache) delete(namespace, name string) {
 	c.mu.Lock()
 	defer c.mu.Unlock()
 
 	key := makeKey(namespace, name)
+	if _, existed := c.cache[key]; existed {
+		c.revoke(namespace, name, RevokeDeleted)
+	}
 	delete(c.cache, key)
+	httpmetrics.DeletePermissionFormat(namespace, name)
 }
 
 // buildPermissions constructs NATS permissions from a ServiceAccount's annotations
-func buildPermissions(sa *corev1.ServiceAccount, logger *zap.Logger) *Permissions {
-	perms := &Permissions{}
-
-	// Default: namespace scope (always included)
-	defaultSubject := fmt.Sprintf("%s.>", sa.Namespace)
-	// Publish: Only namespace scope (response publishing handled via Resp field in auth callout)
-	perms.Publish = []string{defaultSubject}
-	// Subscribe: Inbox patterns first, then namespace scope
-	// - _INBOX.> for default convenience (works with standard NATS clients)
-	// - _INBOX_<namespace>_<serviceaccount>.> for private inbox pattern (enhanced security)
-	//   Note: Uses underscore separators to prevent _INBOX.> from matching the private inbox
-	privateInbox := fmt.Sprintf("_INBOX_%s_%s.>", sa.Namespace, sa.Name)
-	perms.Subscribe = []string{"_INBOX.>", privateInbox, defaultSubject}
-
-	// Add additional subjects from annotations
-	if pubAnnotation, ok := sa.Annotations[AnnotationAllowedPubSubjects]; ok {
-		additionalPub, filteredPub := parseSubjects(pubAnnotation)
-		if len(filteredPub) > 0 {
-			logger.Warn("Filtered NATS internal subjects from ServiceAccount annotation",
+func buildPermissions(sa *corev1.ServiceAccount, opts Options, logger *zap.Logger) *Permissions {
+	data := templateData{
+		Namespace:      sa.Namespace,
+		ServiceAccount: sa.Name,
+		Cluster:        opts.Cluster,
+	}
+
+	// Defaults: rendered from the configured templates (always included)
+	// Publish defaults to namespace scope only (response publishing handled via Resp field in auth callout)
+	// Subscribe defaults to the inbox patterns first, then namespace scope
+	perms := &Permissions{
+		Publish:   renderDefaultSubjects(sa, opts.PublishTemplates, data, logger),
+		Subscribe: renderDefaultSubjects(sa, opts.SubscribeTemplates, data, logger),
+	}
+
+	// A valid permissions document replaces the legacy subject, queue and response annotations
+	doc := permissionsDocument(sa, logger)
+	perms.Format = permissionFormat(sa, doc)
+
+	// Add additional subjects from the document or annotations, subject to prefix ownership rules
+	mqtt := mqttMode(sa, logger)
+	pubSource, subSource := AnnotationAllowedPubSubjects, AnnotationAllowedSubSubjects
+	var additionalPub, additionalSub []string
+	if doc != nil {
+		pubSource, subSource = AnnotationPermissions, AnnotationPermissions
+		additionalPub = renderAnnotationSubjects(sa, AnnotationPermissions, doc.publishRules().Allow, data, mqtt, logger)
+		additionalSub = renderAnnotationSubjects(sa, AnnotationPermissions, doc.subscribeRules().Allow, data, mqtt, logger)
+		perms.PublishDeny = renderAnnotationSubjects(sa, AnnotationPermissions, doc.publishRules().Deny, data, mqtt, logger)
+		perms.SubscribeDeny = renderAnnotationSubjects(sa, AnnotationPermissions, doc.subscribeRules().Deny, data, mqtt, logger)
+		perms.Limits = doc.Limits
+	} else {
+		additionalPub = annotationSubjects(sa, AnnotationAllowedPubSubjects, data, mqtt, logger)
+		additionalSub = annotationSubjects(sa, AnnotationAllowedSubSubjects, data, mqtt, logger)
+	}
+	perms.Publish = append(perms.Publish, enforceRegistry(sa, opts.Registry, pubSource, additionalPub, logger)...)
+	perms.Subscribe = append(perms.Subscribe, enforceRegistry(sa, opts.Registry, subSource, additionalSub, logger)...)
+
+	// JetStream API subjects expanded from the stream and consumer annotations, subject to stream ownership rules
+	perms.Publish = append(perms.Publish, jetStreamSubjects(sa, opts.JetStreamDomain, opts.Registry, logger)...)
+
+	// Micro services: discovery subscriptions and endpoint subjects, subject to prefix ownership rules
+	discovery, endpoints := microServiceSubjects(sa, logger)
+	perms.Subscribe = append(perms.Subscribe, discovery...)
+	perms.Subscribe = append(perms.Subscribe, enforceRegistry(sa, opts.Registry, AnnotationMicroServices, endpoints, logger)...)
+	perms.Publish = append(perms.Publish, microClientSubjects(sa, logger)...)
+
+	// Response permissions from global defaults and the document or annotations
+	_, explicitResponses := sa.Annotations[AnnotationAllowResponses]
+	if doc != nil {
+		perms.Response = documentResponsePermission(doc.Responses, opts)
+		explicitResponses = doc.Responses != nil && doc.Responses.Enabled != nil
+	} else {
+		perms.Response = buildResponsePermission(sa, opts, logger)
+	}
+
+	// Micro services reply to discovery and endpoint requests, so they need response permissions
+	// unless the ServiceAccount explicitly disables them
+	if len(discovery) > 0 && perms.Response == nil && !explicitResponses {
+		perms.Response = &ResponsePermission{MaxMsgs: DefaultResponseMaxMsgs}
+	}
+
+	// Source network restrictions from the global ranges, narrowed by annotation
+	perms.SourceCIDRs = buildSourceCIDRs(sa, opts.SourceCIDRs, logger)
+
+	// Connection types from the global default, replaced by annotation
+	perms.ConnectionTypes = buildConnectionTypes(sa, opts.ConnectionTypes, logger)
+
+	// MQTT mode: internal subjects used by the server on behalf of MQTT clients, and MQTT connections
+	if mqtt {
+		perms.Subscribe = append(perms.Subscribe, mqttSubscribeSubjects...)
+		perms.ConnectionTypes = withMQTTConnectionType(sa, perms.ConnectionTypes)
+	}
+
+	// Time windows the ServiceAccount may connect in
+	perms.AllowedTimes = buildAllowedTimes(sa, logger)
+
+	// Nodes the ServiceAccount's pods must be scheduled on
+	perms.NodeSelector = buildNodeSelector(sa, logger)
+
+	// Kill switch for compromised workloads
+	perms.Disabled, perms.DisabledUntil = buildDisabled(sa, logger)
+
+	// Strict inbox mode: replace the shared inbox with the private inbox only
+	if strictInbox(sa, opts.StrictInbox, logger) {
+		perms.Subscribe = applyStrictInbox(perms.Subscribe, data)
+	}
+
+	// Drop duplicates and subjects already covered by a broader wildcard to keep JWTs small
+	perms.Publish = normalizePermissionList(sa, "publish", perms.Publish, logger)
+	perms.Subscribe = normalizePermissionList(sa, "subscribe", perms.Subscribe, logger)
+	perms.PublishDeny = normalizePermissionList(sa, "publish deny", perms.PublishDeny, logger)
+	perms.SubscribeDeny = normalizePermissionList(sa, "subscribe deny", perms.SubscribeDeny, logger)
+
+	// Pod-level refinements applied at authorization time when pods are watched
+	perms.PodMode = podPermissionsMode(sa, opts.PodPermissions, logger)
+	perms.PodRules = buildPodRules(sa, opts.Registry, data, logger)
+
+	// Queue-group-restricted subscriptions, subject to prefix ownership rules
+	if doc != nil {
+		perms.SubscribeQueues = renderQueueSubscriptions(sa, AnnotationPermissions, doc.subscribeRules().Queues, opts.Registry, data, logger)
+	} else {
+		perms.SubscribeQueues = buildQueueSubscriptions(sa, opts.Registry, data, logger)
+	}
+	warnUncoveredQueues(sa, perms.SubscribeQueues, perms.Subscribe, logger)
+
+	return perms
+}
+
+// strictInbox reports whether strict inbox mode applies to a ServiceAccount.
+// The annotation takes precedence over the global setting to allow gradual migration.
+func strictInbox(sa *corev1.ServiceAccount, global bool, logger *zap.Logger) bool {
+	value, ok := sa.Annotations[AnnotationStrictInbox]
+	if !ok {
+		return global
+	}
+
+	strict, err := strconv.ParseBool(strings.TrimSpace(value))
+	if err != nil {
+		logInvalidAnnotation(sa, AnnotationStrictInbox, value, err, logger)
+		return global
+	}
+
+	return strict
+}
+
+// logInvalidAnnotation logs an annotation value that could not be parsed and was ignored
+func logInvalidAnnotation(sa *corev1.ServiceAccount, annotation, value string, err error, logger *zap.Logger) {
+	logger.Warn("Ignoring invalid ServiceAccount annotation",
+		zap.String("namespace", sa.Namespace),
+		zap.String("serviceaccount", sa.Name),
+		zap.String("annotation", annotation),
+		zap.String("value", value),
+		zap.Error(err))
+}
+
+// applyStrictInbox removes the shared inbox from subscribe permissions and ensures the private inbox is granted
+func applyStrictInbox(subjects []string, data templateData) []string {
+	privateInbox, _ := renderSubjects([]string{privateInboxTemplate}, data)
+
+	result := make([]string, 0, len(subjects)+1)
+	hasPrivate := false
+	for _, subject := range subjects {
+		if subject == SharedInboxSubject {
+			continue
+		}
+		if subject == privateInbox[0] {
+			hasPrivate = true
+		}
+		result = append(result, subject)
+	}
+	if !hasPrivate {
+		result = append([]string{privateInbox[0]}, result...)
+	}
+
+	return result
+}
+
+// enforceRegistry drops annotation subjects that the subject registry does not permit for the ServiceAccount
+func enforceRegistry(sa *corev1.ServiceAccount, registry *SubjectRegistry, annotation string, subjects []string, logger *zap.Logger) []string {
+	if registry == nil {
+		return subjects
+	}
+
+	allowed := make([]string, 0, len(subjects))
+	for _, subject := range subjects {
+		if reason := registry.check(sa.Namespace, sa.Name, subject); reason != "" {
+			logger.Warn("Dropped annotation subject denied by subject registry",
 				zap.String("namespace", sa.Namespace),
 				zap.String("serviceaccount", sa.Name),
-				zap.String("annotation", AnnotationAllowedPubSubjects),
-				zap.Strings("filtered", filteredPub))
-
-			// Increment metrics for each filtered subject
-			for _, subject := range filteredPub {
-				httpmetrics.IncrementFilteredSubjects(sa.Namespace, sa.Name, AnnotationAllowedPubSubjects, subject)
+				zap.String("annotation", annotation),
+				zap.String("subject", subject),
+				zap.String("reason", reason))
+			if countDrops(logger) {
+				httpmetrics.IncrementRegistryDeniedSubjects(sa.Namespace, sa.Name, annotation, reason)
 			}
+			continue
 		}
-		perms.Publish = append(perms.Publish, additionalPub...)
+		allowed = append(allowed, subject)
 	}
 
-	if subAnnotation, ok := sa.Annotations[AnnotationAllowedSubSubjects]; ok {
-		additionalSub, filteredSub := parseSubjects(subAnnotation)
-		if len(filteredSub) > 0 {
-			logger.Warn("Filtered NATS internal subjects from ServiceAccount annotation",
+	return allowed
+}
+
+// normalizePermissionList removes redundant subjects from a permission list and logs what was removed
+func normalizePermissionList(sa *corev1.ServiceAccount, kind string, subjects []string, logger *zap.Logger) []string {
+	kept, removed := normalizeSubjects(subjects)
+	if len(removed) > 0 {
+		logger.Debug("Removed redundant subjects from ServiceAccount permissions",
+			zap.String("namespace", sa.Namespace),
+			zap.String("serviceaccount", sa.Name),
+			zap.String("permission", kind),
+			zap.Strings("removed", removed))
+	}
+	return kept
+}
+
+// rejectInvalidSubjects drops syntactically invalid subjects, logging and counting each one
+func rejectInvalidSubjects(sa *corev1.ServiceAccount, source string, subjects []string, logger *zap.Logger) []string {
+	valid := make([]string, 0, len(subjects))
+	for _, subject := range subjects {
+		if err := validateSubject(subject); err != nil {
+			logger.Warn("Rejected invalid NATS subject",
 				zap.String("namespace", sa.Namespace),
 				zap.String("serviceaccount", sa.Name),
-				zap.String("annotation", AnnotationAllowedSubSubjects),
-				zap.Strings("filtered", filteredSub))
+				zap.String("source", source),
+				zap.Error(err))
+			if countDrops(logger) {
+				httpmetrics.IncrementInvalidSubjects(sa.Namespace, sa.Name, source)
+			}
+			continue
+		}
+		valid = append(valid, subject)
+	}
+	return valid
+}
 
-			// Increment metrics for each filtered subject
-			for _, subject := range filteredSub {
-				httpmetrics.IncrementFilteredSubjects(sa.Namespace, sa.Name, AnnotationAllowedSubSubjects, subject)
+// renderDefaultSubjects renders the configured default subject templates for a ServiceAccount
+func renderDefaultSubjects(sa *corev1.ServiceAccount, templates []string, data templateData, logger *zap.Logger) []string {
+	subjects, invalid := renderSubjects(templates, data)
+	if len(invalid) > 0 {
+		logger.Warn("Dropped default subject templates with unknown placeholders",
+			zap.String("namespace", sa.Namespace),
+			zap.String("serviceaccount", sa.Name),
+			zap.Strings("invalid", invalid))
+	}
+	return rejectInvalidSubjects(sa, sourceDefaults, subjects, logger)
+}
+
+// annotationSubjects parses and renders the subjects listed in a ServiceAccount annotation.
+// In MQTT mode, entries written as MQTT topic filters are translated to NATS subjects first.
+func annotationSubjects(sa *corev1.ServiceAccount, annotation string, data templateData, mqtt bool, logger *zap.Logger) []string {
+	value, ok := sa.Annotations[annotation]
+	if !ok {
+		return nil
+	}
+
+	parsed, filtered := parseSubjects(value)
+	if len(filtered) > 0 {
+		logger.Warn("Filtered NATS internal subjects from ServiceAccount annotation",
+			zap.String("namespace", sa.Namespace),
+			zap.String("serviceaccount", sa.Name),
+			zap.String("annotation", annotation),
+			zap.Strings("filtered", filtered))
+
+		// Increment metrics for each filtered subject
+		for _, subject := range filtered {
+			if countDrops(logger) {
+				httpmetrics.IncrementFilteredSubjects(sa.Namespace, sa.Name, annotation, subject)
 			}
 		}
-		perms.Subscribe = append(perms.Subscribe, additionalSub...)
 	}
 
-	return perms
+	return renderAnnotationSubjects(sa, annotation, parsed, data, mqtt, logger)
+}
+
+// renderAnnotationSubjects translates MQTT topic filters (in MQTT mode), renders placeholders and
+// drops invalid subjects requested by a ServiceAccount annotation.
+func renderAnnotationSubjects(sa *corev1.ServiceAccount, annotation string, parsed []string, data templateData, mqtt bool, logger *zap.Logger) []string {
+	if mqtt {
+		parsed = translateMQTTSubjects(sa, annotation, parsed, logger)
+	}
+
+	subjects, invalid := renderSubjects(parsed, data)
+	if len(invalid) > 0 {
+		logger.Warn("Dropped annotation subjects with unknown placeholders",
+			zap.String("namespace", sa.Namespace),
+			zap.String("serviceaccount", sa.Name),
+			zap.String("annotation", annotation),
+			zap.Strings("invalid", invalid))
+	}
+
+	return rejectInvalidSubjects(sa, annotation, subjects, logger)
 }
 
 // parseSubjects parses a comma-separated list of NATS subjects from an annotation value.
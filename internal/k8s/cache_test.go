@@ -1,9 +1,12 @@
 package k8s
 
 import (
+	"slices"
 	"testing"
 
+	"github.com/prometheus/client_golang/prometheus"
 	"go.uber.org/zap"
+	"go.uber.org/zap/zaptest/observer"
 	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
@@ -287,3 +290,211 @@ func equalStringSlices(a, b []string) bool {
 	}
 	return true
 }
+
+// TestCache_StrictInbox tests strict inbox mode from the global setting and the per-SA annotation
+func TestCache_StrictInbox(t *testing.T) {
+	tests := []struct {
+		name         string
+		globalStrict bool
+		annotations  map[string]string
+		wantSubPerms []string
+	}{
+		{
+			name:         "Shared inbox by default",
+			globalStrict: false,
+			wantSubPerms: []string{"_INBOX.>", "_INBOX_payments_api.>", "payments.>"},
+		},
+		{
+			name:         "Global strict mode",
+			globalStrict: true,
+			wantSubPerms: []string{"_INBOX_payments_api.>", "payments.>"},
+		},
+		{
+			name:         "Annotation opts in before global enforcement",
+			globalStrict: false,
+			annotations:  map[string]string{"nats.io/strict-inbox": "true"},
+			wantSubPerms: []string{"_INBOX_payments_api.>", "payments.>"},
+		},
+		{
+			name:         "Annotation opts out of global enforcement",
+			globalStrict: true,
+			annotations:  map[string]string{"nats.io/strict-inbox": "false"},
+			wantSubPerms: []string{"_INBOX.>", "_INBOX_payments_api.>", "payments.>"},
+		},
+		{
+			name:         "Invalid annotation falls back to global setting",
+			globalStrict: true,
+			annotations:  map[string]string{"nats.io/strict-inbox": "maybe"},
+			wantSubPerms: []string{"_INBOX_payments_api.>", "payments.>"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opts := DefaultOptions()
+			opts.StrictInbox = tt.globalStrict
+			cache := NewCacheWithOptions(zap.NewNop(), opts)
+
+			cache.upsert(&corev1.ServiceAccount{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:        "api",
+					Namespace:   "payments",
+					Annotations: tt.annotations,
+				},
+			})
+
+			_, subPerms, _ := cache.Get("payments", "api")
+			if !equalStringSlices(subPerms, tt.wantSubPerms) {
+				t.Errorf("subPerms = %v, want %v", subPerms, tt.wantSubPerms)
+			}
+		})
+	}
+}
+
+// TestCache_StrictInboxCustomTemplates tests that strict mode always grants the private inbox
+func TestCache_StrictInboxCustomTemplates(t *testing.T) {
+	cache := NewCacheWithOptions(zap.NewNop(), Options{
+		SubscribeTemplates: []string{"_INBOX.>", "tenant.{{.Namespace}}.>"},
+		StrictInbox:        true,
+	})
+
+	cache.upsert(&corev1.ServiceAccount{
+		ObjectMeta: metav1.ObjectMeta{Name: "api", Namespace: "payments"},
+	})
+
+	_, subPerms, _ := cache.Get("payments", "api")
+	want := []string{"_INBOX_payments_api.>", "tenant.payments.>"}
+	if !equalStringSlices(subPerms, want) {
+		t.Errorf("subPerms = %v, want %v", subPerms, want)
+	}
+}
+
+// TestCache_ShadowModeReporting tests that subjects dropped in shadow mode are logged and counted
+// once, by the issued permissions, and not for denials of the candidate policy alone
+func TestCache_ShadowModeReporting(t *testing.T) {
+	registry, err := ParseSubjectRegistry([]byte(testRegistry))
+	if err != nil {
+		t.Fatalf("Failed to parse registry: %v", err)
+	}
+
+	opts := DefaultOptions()
+	opts.Registry = registry
+	opts.ShadowNamespaces = []string{"shadow-reporting"}
+	core, logs := observer.New(zap.WarnLevel)
+	cache := NewCacheWithOptions(zap.New(core), opts)
+
+	cache.upsert(&corev1.ServiceAccount{ObjectMeta: metav1.ObjectMeta{
+		Name:        "api",
+		Namespace:   "shadow-reporting",
+		Annotations: map[string]string{AnnotationAllowedPubSubjects: "orders.created, orders..invalid"},
+	}})
+
+	if got := logs.FilterMessage("Rejected invalid NATS subject").Len(); got != 1 {
+		t.Errorf("Logged %d invalid subjects, want 1", got)
+	}
+	if got := logs.FilterMessage("Dropped annotation subject denied by subject registry").Len(); got != 0 {
+		t.Errorf("Logged %d registry denials, want none for a candidate-only denial", got)
+	}
+
+	labels := map[string]string{"namespace": "shadow-reporting", "serviceaccount": "api"}
+	if got := counterValue(t, "nats_auth_invalid_subjects_total", labels); got != 1 {
+		t.Errorf("Invalid subjects counted %v times, want 1", got)
+	}
+	if got := counterValue(t, "nats_auth_registry_denied_subjects_total", labels); got != 0 {
+		t.Errorf("Registry denials counted %v times, want 0", got)
+	}
+}
+
+// counterValue sums a registered counter's series whose labels include the given labels
+func counterValue(t *testing.T, name string, labels map[string]string) float64 {
+	t.Helper()
+
+	families, err := prometheus.DefaultGatherer.Gather()
+	if err != nil {
+		t.Fatalf("Failed to gather metrics: %v", err)
+	}
+
+	var total float64
+	for _, family := range families {
+		if family.GetName() != name {
+			continue
+		}
+	metrics:
+		for _, metric := range family.GetMetric() {
+			for _, pair := range metric.GetLabel() {
+				if want, ok := labels[pair.GetName()]; ok && pair.GetValue() != want {
+					continue metrics
+				}
+			}
+			total += metric.GetCounter().GetValue()
+		}
+	}
+	return total
+}
+
+// TestCache_ShadowMode tests building current and candidate permissions in shadowed namespaces
+func TestCache_ShadowMode(t *testing.T) {
+	registry, err := ParseSubjectRegistry([]byte(testRegistry))
+	if err != nil {
+		t.Fatalf("Failed to parse registry: %v", err)
+	}
+
+	opts := DefaultOptions()
+	opts.StrictInbox = true
+	opts.Registry = registry
+	opts.ShadowNamespaces = []string{"shop"}
+	opts.PodPermissions = PodPermissionsMerge
+	cache := NewCacheWithOptions(zap.NewNop(), opts)
+
+	for _, namespace := range []string{"shop", "payments"} {
+		cache.upsert(&corev1.ServiceAccount{ObjectMeta: metav1.ObjectMeta{
+			Name:        "api",
+			Namespace:   namespace,
+			Annotations: map[string]string{AnnotationAllowedPubSubjects: "orders.created"},
+		}})
+	}
+
+	// Shadowed: issued without the registry and strict inbox, candidate enforces both
+	perms, _ := cache.Lookup("shop", "api")
+	if perms.Candidate == nil {
+		t.Fatal("Expected candidate permissions in a shadowed namespace")
+	}
+	if want := []string{"shop.>", "orders.created"}; !equalStringSlices(perms.Publish, want) {
+		t.Errorf("Publish = %v, want %v", perms.Publish, want)
+	}
+	if want := []string{"_INBOX.>", "_INBOX_shop_api.>", "shop.>"}; !equalStringSlices(perms.Subscribe, want) {
+		t.Errorf("Subscribe = %v, want %v", perms.Subscribe, want)
+	}
+	if want := []string{"shop.>"}; !equalStringSlices(perms.Candidate.Publish, want) {
+		t.Errorf("Candidate.Publish = %v, want %v", perms.Candidate.Publish, want)
+	}
+	if want := []string{"_INBOX_shop_api.>", "shop.>"}; !equalStringSlices(perms.Candidate.Subscribe, want) {
+		t.Errorf("Candidate.Subscribe = %v, want %v", perms.Candidate.Subscribe, want)
+	}
+
+	// Pod refinements apply the registry to the candidate only
+	pod := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        "api-1",
+			Namespace:   "shop",
+			Annotations: map[string]string{AnnotationAllowedPubSubjects: "orders.updated"},
+		},
+		Spec: corev1.PodSpec{ServiceAccountName: "api"},
+	}
+	refined := cache.podPermissions(perms, pod, zap.NewNop())
+	if !slices.Contains(refined.Publish, "orders.updated") {
+		t.Errorf("Refined Publish = %v, want orders.updated", refined.Publish)
+	}
+	if refined.Candidate == nil || slices.Contains(refined.Candidate.Publish, "orders.updated") {
+		t.Errorf("Refined candidate = %+v, want orders.updated denied by the registry", refined.Candidate)
+	}
+
+	// Not shadowed: the policy is enforced
+	perms, _ = cache.Lookup("payments", "api")
+	if perms.Candidate != nil {
+		t.Error("Expected no candidate permissions outside shadowed namespaces")
+	}
+	if want := []string{"payments.>"}; !equalStringSlices(perms.Publish, want) {
+		t.Errorf("Publish = %v, want %v", perms.Publish, want)
+	}
+}
@@ -6,8 +6,11 @@ import (
 
 	"go.uber.org/zap"
 	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/util/runtime"
 	"k8s.io/client-go/informers"
+	corelisters "k8s.io/client-go/listers/core/v1"
 	"k8s.io/client-go/tools/cache"
 )
 
@@ -15,13 +18,21 @@ import (
 type Client struct {
 	cache    *Cache
 	informer cache.SharedIndexInformer
+	pods     corelisters.PodLister  // nil unless WatchPods was called
+	nodes    corelisters.NodeLister // nil unless WatchNodes was called
 	stopCh   chan struct{}
 	logger   *zap.Logger
 }
 
 // NewClient creates a new Kubernetes client with ServiceAccount informer
 func NewClient(factory informers.SharedInformerFactory, logger *zap.Logger) *Client {
-	saCache := NewCache(logger)
+	return NewClientWithOptions(factory, logger, DefaultOptions())
+}
+
+// NewClientWithOptions creates a new Kubernetes client with ServiceAccount informer
+// and custom permission options
+func NewClientWithOptions(factory informers.SharedInformerFactory, logger *zap.Logger, opts Options) *Client {
+	saCache := NewCacheWithOptions(logger, opts)
 
 	// Get the ServiceAccount informer
 	informer := factory.Core().V1().ServiceAccounts().Informer()
@@ -77,11 +88,177 @@ func NewClient(factory informers.SharedInformerFactory, logger *zap.Logger) *Cli
 	return client
 }
 
+// WatchSubjectRegistry watches a ConfigMap holding the subject prefix registry under key.
+// The factory must be scoped to the ConfigMap's namespace and started by the caller.
+// Every change re-evaluates all cached ServiceAccounts against the new registry.
+// Invalid documents are logged and the previous registry stays in effect.
+func (c *Client) WatchSubjectRegistry(factory informers.SharedInformerFactory, name, key string) error {
+	return watchConfigMap(factory, name,
+		func(cm *corev1.ConfigMap) {
+			registry, err := ParseSubjectRegistry([]byte(cm.Data[key]))
+			if err != nil {
+				c.logger.Error("Ignoring invalid subject registry",
+					zap.String("configmap", makeKey(cm.Namespace, cm.Name)),
+					zap.String("key", key),
+					zap.Error(err))
+				return
+			}
+
+			c.logger.Info("Subject registry updated",
+				zap.String("configmap", makeKey(cm.Namespace, cm.Name)),
+				zap.Int("prefixes", len(registry.Prefixes)))
+			c.cache.SetSubjectRegistry(registry)
+			c.resync()
+		},
+		func() {
+			c.logger.Warn("Subject registry ConfigMap deleted, registry checks disabled",
+				zap.String("configmap", name))
+			c.cache.SetSubjectRegistry(nil)
+			c.resync()
+		},
+	)
+}
+
+// resync rebuilds the cached permissions of every ServiceAccount known to the informer
+func (c *Client) resync() {
+	for _, obj := range c.informer.GetStore().List() {
+		if sa, ok := obj.(*corev1.ServiceAccount); ok {
+			c.cache.upsert(sa)
+		}
+	}
+}
+
+// WatchPods enables pod-level permissions by watching pods through the factory's pod informer.
+// Must be called before the factory is started. Only the pod fields needed for permissions are
+// kept in the informer cache to limit memory use.
+func (c *Client) WatchPods(factory informers.SharedInformerFactory) error {
+	podInformer := factory.Core().V1().Pods()
+	if err := podInformer.Informer().SetTransform(trimPod); err != nil {
+		return fmt.Errorf("failed to set pod transform: %w", err)
+	}
+	c.pods = podInformer.Lister()
+	return nil
+}
+
+// trimPod drops the pod fields that permissions do not use before the pod is cached
+func trimPod(obj interface{}) (interface{}, error) {
+	pod, ok := obj.(*corev1.Pod)
+	if !ok {
+		return obj, nil
+	}
+
+	return &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:            pod.Name,
+			Namespace:       pod.Namespace,
+			UID:             pod.UID,
+			ResourceVersion: pod.ResourceVersion,
+			Labels:          pod.Labels,
+			Annotations:     pod.Annotations,
+			OwnerReferences: pod.OwnerReferences,
+		},
+		Spec: corev1.PodSpec{
+			ServiceAccountName: pod.Spec.ServiceAccountName,
+			NodeName:           pod.Spec.NodeName,
+		},
+	}, nil
+}
+
+// LookupPodPermissions retrieves the NATS permissions for a pod running as a ServiceAccount.
+// The ServiceAccount's permissions are refined by pod rules and pod annotations when pods are
+// watched and the pod's UID matches the token's. When the pod is unknown (e.g. not yet seen by
+// the informer), cannot be looked up, has another UID or does not run as the ServiceAccount,
+// the ServiceAccount's permissions are returned unchanged in merge mode and reduced to inbox
+// subjects in narrow mode, since the pod's narrowing annotations cannot be applied.
+func (c *Client) LookupPodPermissions(namespace, serviceAccount, podName, podUID string) (*Permissions, bool) {
+	perms, found := c.cache.Lookup(namespace, serviceAccount)
+	if !found || c.pods == nil || podName == "" {
+		return perms, found
+	}
+
+	pod, err := c.pods.Pods(namespace).Get(podName)
+	if err != nil {
+		if !errors.IsNotFound(err) {
+			c.logger.Warn("Failed to look up pod, using unrefined permissions",
+				zap.String("namespace", namespace),
+				zap.String("pod", podName),
+				zap.Error(err))
+		} else {
+			c.logger.Debug("Pod not found in cache, using unrefined permissions",
+				zap.String("namespace", namespace),
+				zap.String("pod", podName))
+		}
+		return unrefinedPodPermissions(perms), true
+	}
+
+	if string(pod.UID) != podUID {
+		c.logger.Warn("Pod UID does not match the token, using unrefined permissions",
+			zap.String("namespace", namespace),
+			zap.String("pod", podName),
+			zap.String("pod_uid", string(pod.UID)),
+			zap.String("token_pod_uid", podUID))
+		return unrefinedPodPermissions(perms), true
+	}
+
+	if pod.Spec.ServiceAccountName != serviceAccount {
+		c.logger.Warn("Pod does not run as the token's ServiceAccount, using unrefined permissions",
+			zap.String("namespace", namespace),
+			zap.String("pod", podName),
+			zap.String("serviceaccount", serviceAccount),
+			zap.String("pod_serviceaccount", pod.Spec.ServiceAccountName))
+		return unrefinedPodPermissions(perms), true
+	}
+
+	return c.cache.podPermissions(perms, pod, c.cache.logger), true
+}
+
+// OnServiceAccountRevoked registers a function called with a Revoke* reason when a
+// ServiceAccount's existing clients should be disconnected.
+func (c *Client) OnServiceAccountRevoked(fn func(namespace, name, reason string)) {
+	c.cache.SetRevokeHandler(fn)
+}
+
+// WatchNodes enables node selector checks by watching nodes through the factory's node informer.
+// Must be called before the factory is started. Only node names and labels are cached.
+func (c *Client) WatchNodes(factory informers.SharedInformerFactory) error {
+	nodeInformer := factory.Core().V1().Nodes()
+	if err := nodeInformer.Informer().SetTransform(trimNode); err != nil {
+		return fmt.Errorf("failed to set node transform: %w", err)
+	}
+	c.nodes = nodeInformer.Lister()
+	return nil
+}
+
+// NodeLabels returns the labels of a node. found is false when nodes are not watched or the
+// node is unknown.
+func (c *Client) NodeLabels(name string) (nodeLabels map[string]string, found bool) {
+	if c.nodes == nil || name == "" {
+		return nil, false
+	}
+
+	node, err := c.nodes.Get(name)
+	if err != nil {
+		if !errors.IsNotFound(err) {
+			c.logger.Warn("Failed to look up node",
+				zap.String("node", name),
+				zap.Error(err))
+		}
+		return nil, false
+	}
+
+	return node.Labels, true
+}
+
 // GetPermissions retrieves the NATS permissions for a ServiceAccount
 func (c *Client) GetPermissions(namespace, name string) (pubPerms, subPerms []string, found bool) {
 	return c.cache.Get(namespace, name)
 }
 
+// LookupPermissions retrieves the full NATS permissions for a ServiceAccount
+func (c *Client) LookupPermissions(namespace, name string) (*Permissions, bool) {
+	return c.cache.Lookup(namespace, name)
+}
+
 // Shutdown gracefully shuts down the client
 func (c *Client) Shutdown(ctx context.Context) error {
 	close(c.stopCh)
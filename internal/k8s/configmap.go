@@ -0,0 +1,56 @@
+package k8s
+
+import (
+	"fmt"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/runtime"
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/tools/cache"
+)
+
+// watchConfigMap registers handlers on the factory's ConfigMap informer for a single named ConfigMap.
+// onChange is called on add and update; onDelete is called when the ConfigMap is removed.
+// The factory should be scoped to the ConfigMap's namespace.
+func watchConfigMap(factory informers.SharedInformerFactory, name string, onChange func(*corev1.ConfigMap), onDelete func()) error {
+	informer := factory.Core().V1().ConfigMaps().Informer()
+
+	_, err := informer.AddEventHandler(cache.FilteringResourceEventHandler{
+		FilterFunc: func(obj interface{}) bool {
+			cm := configMapFromObject(obj)
+			return cm != nil && cm.Name == name
+		},
+		Handler: &cache.ResourceEventHandlerFuncs{
+			AddFunc: func(obj interface{}) {
+				onChange(configMapFromObject(obj))
+			},
+			UpdateFunc: func(oldObj, newObj interface{}) {
+				onChange(configMapFromObject(newObj))
+			},
+			DeleteFunc: func(obj interface{}) {
+				onDelete()
+			},
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to add ConfigMap event handler: %w", err)
+	}
+
+	return nil
+}
+
+// configMapFromObject extracts a ConfigMap from an informer object, unwrapping tombstones.
+// Returns nil for unexpected object types.
+func configMapFromObject(obj interface{}) *corev1.ConfigMap {
+	if tombstone, ok := obj.(cache.DeletedFinalStateUnknown); ok {
+		obj = tombstone.Obj
+	}
+
+	cm, ok := obj.(*corev1.ConfigMap)
+	if !ok {
+		runtime.HandleError(fmt.Errorf("unexpected object type: %T", obj))
+		return nil
+	}
+
+	return cm
+}
@@ -0,0 +1,90 @@
+package k8s
+
+import (
+	"fmt"
+	"strings"
+
+	natsjwt "github.com/nats-io/jwt/v2"
+	"go.uber.org/zap"
+	corev1 "k8s.io/api/core/v1"
+)
+
+// AnnotationAllowedConnectionTypes sets the NATS connection types a ServiceAccount may use
+// (comma-separated, e.g. "STANDARD,WEBSOCKET"). Replaces the global default.
+const AnnotationAllowedConnectionTypes = "nats.io/allowed-connection-types"
+
+// DefaultConnectionTypes allows only standard NATS client connections.
+var DefaultConnectionTypes = []string{natsjwt.ConnectionTypeStandard}
+
+// noConnectionTypes is the connection type restriction of a ServiceAccount whose annotation is
+// invalid or lists no types. The empty type is never allowed, so every client is rejected rather
+// than falling back to the global default.
+var noConnectionTypes = []string{""}
+
+// validConnectionTypes are the connection types understood by the NATS server
+var validConnectionTypes = map[string]bool{
+	natsjwt.ConnectionTypeStandard:   true,
+	natsjwt.ConnectionTypeWebsocket:  true,
+	natsjwt.ConnectionTypeLeafnode:   true,
+	natsjwt.ConnectionTypeLeafnodeWS: true,
+	natsjwt.ConnectionTypeMqtt:       true,
+	natsjwt.ConnectionTypeMqttWS:     true,
+	natsjwt.ConnectionTypeInProcess:  true,
+}
+
+// ParseConnectionTypes normalizes and validates a list of NATS connection types.
+func ParseConnectionTypes(values []string) ([]string, error) {
+	types := make([]string, 0, len(values))
+	for _, value := range values {
+		value = strings.ToUpper(strings.TrimSpace(value))
+		if value == "" {
+			continue
+		}
+		if !validConnectionTypes[value] {
+			return nil, fmt.Errorf("unknown connection type %q", value)
+		}
+		types = append(types, value)
+	}
+	return types, nil
+}
+
+// ConnectionTypeAllowed reports whether a client may connect with the given connection type.
+// The authorization request does not distinguish MQTT and leafnode connections made over
+// websockets, so their _WS variants are accepted for the base type; the NATS server enforces
+// the exact type from the user claims.
+func (p *Permissions) ConnectionTypeAllowed(connType string) bool {
+	if len(p.ConnectionTypes) == 0 {
+		return true
+	}
+
+	for _, allowed := range p.ConnectionTypes {
+		if allowed == "" {
+			continue
+		}
+		if allowed == connType || allowed == connType+"_WS" {
+			return true
+		}
+	}
+	return false
+}
+
+// buildConnectionTypes resolves the allowed connection types for a ServiceAccount.
+// The annotation replaces the global default; invalid or empty annotations deny every connection type.
+func buildConnectionTypes(sa *corev1.ServiceAccount, global []string, logger *zap.Logger) []string {
+	value, ok := sa.Annotations[AnnotationAllowedConnectionTypes]
+	if !ok {
+		return global
+	}
+
+	types, err := ParseConnectionTypes(strings.Split(value, ","))
+	if err != nil {
+		logInvalidAnnotation(sa, AnnotationAllowedConnectionTypes, value, err, logger)
+		return noConnectionTypes
+	}
+	if len(types) == 0 {
+		logInvalidAnnotation(sa, AnnotationAllowedConnectionTypes, value, fmt.Errorf("no connection types listed"), logger)
+		return noConnectionTypes
+	}
+
+	return types
+}
@@ -0,0 +1,103 @@
+package k8s
+
+import (
+	"testing"
+
+	"go.uber.org/zap"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// TestCache_ConnectionTypes tests allowed connection types from the global default and annotations
+func TestCache_ConnectionTypes(t *testing.T) {
+	tests := []struct {
+		name        string
+		opts        Options
+		annotations map[string]string
+		want        []string
+	}{
+		{
+			name: "Standard only by default",
+			opts: DefaultOptions(),
+			want: []string{"STANDARD"},
+		},
+		{
+			name: "Unset global falls back to default",
+			opts: Options{},
+			want: []string{"STANDARD"},
+		},
+		{
+			name: "Global default",
+			opts: Options{ConnectionTypes: []string{"STANDARD", "WEBSOCKET"}},
+			want: []string{"STANDARD", "WEBSOCKET"},
+		},
+		{
+			name:        "Annotation replaces default",
+			opts:        DefaultOptions(),
+			annotations: map[string]string{"nats.io/allowed-connection-types": "websocket, MQTT_WS"},
+			want:        []string{"WEBSOCKET", "MQTT_WS"},
+		},
+		{
+			name:        "Unknown type denies every type",
+			opts:        DefaultOptions(),
+			annotations: map[string]string{"nats.io/allowed-connection-types": "STANDARD, GATEWAY"},
+			want:        noConnectionTypes,
+		},
+		{
+			name:        "Empty annotation denies every type",
+			opts:        DefaultOptions(),
+			annotations: map[string]string{"nats.io/allowed-connection-types": " , "},
+			want:        noConnectionTypes,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cache := NewCacheWithOptions(zap.NewNop(), tt.opts)
+			cache.upsert(&corev1.ServiceAccount{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:        "api",
+					Namespace:   "payments",
+					Annotations: tt.annotations,
+				},
+			})
+
+			perms, found := cache.Lookup("payments", "api")
+			if !found {
+				t.Fatal("Expected ServiceAccount to be in cache after upsert")
+			}
+
+			if !equalStringSlices(perms.ConnectionTypes, tt.want) {
+				t.Errorf("ConnectionTypes = %v, want %v", perms.ConnectionTypes, tt.want)
+			}
+		})
+	}
+}
+
+// TestPermissions_ConnectionTypeAllowed tests matching request connection types against allowed types
+func TestPermissions_ConnectionTypeAllowed(t *testing.T) {
+	tests := []struct {
+		name     string
+		allowed  []string
+		connType string
+		want     bool
+	}{
+		{name: "No restriction", allowed: nil, connType: "LEAFNODE", want: true},
+		{name: "Standard allowed", allowed: []string{"STANDARD"}, connType: "STANDARD", want: true},
+		{name: "Leafnode denied", allowed: []string{"STANDARD"}, connType: "LEAFNODE", want: false},
+		{name: "Unknown type denied", allowed: []string{"STANDARD"}, connType: "", want: false},
+		{name: "MQTT over websocket", allowed: []string{"MQTT_WS"}, connType: "MQTT", want: true},
+		{name: "Websocket is not standard", allowed: []string{"WEBSOCKET"}, connType: "STANDARD", want: false},
+		{name: "Invalid annotation denies standard", allowed: noConnectionTypes, connType: "STANDARD", want: false},
+		{name: "Invalid annotation denies unknown type", allowed: noConnectionTypes, connType: "", want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			perms := &Permissions{ConnectionTypes: tt.allowed}
+			if got := perms.ConnectionTypeAllowed(tt.connType); got != tt.want {
+				t.Errorf("ConnectionTypeAllowed(%q) = %v, want %v", tt.connType, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,48 @@
+package k8s
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+	corev1 "k8s.io/api/core/v1"
+)
+
+const (
+	// AnnotationDisabled set to "true" denies all connections for a ServiceAccount
+	AnnotationDisabled = "nats.io/disabled"
+	// AnnotationDisabledUntil denies all connections for a ServiceAccount until an RFC 3339
+	// timestamp (e.g. "2026-07-01T09:00:00Z")
+	AnnotationDisabledUntil = "nats.io/disabled-until"
+)
+
+// DisabledAt reports whether the ServiceAccount is disabled at the given time.
+func (p *Permissions) DisabledAt(now time.Time) bool {
+	return p.Disabled || now.Before(p.DisabledUntil)
+}
+
+// buildDisabled resolves the disabled annotations for a ServiceAccount.
+// Invalid values disable the ServiceAccount, since the annotations exist to cut off access.
+func buildDisabled(sa *corev1.ServiceAccount, logger *zap.Logger) (disabled bool, until time.Time) {
+	if value, ok := sa.Annotations[AnnotationDisabled]; ok {
+		parsed, err := strconv.ParseBool(strings.TrimSpace(value))
+		if err != nil {
+			logInvalidAnnotation(sa, AnnotationDisabled, value, err, logger)
+			return true, time.Time{}
+		}
+		disabled = parsed
+	}
+
+	if value, ok := sa.Annotations[AnnotationDisabledUntil]; ok {
+		parsed, err := time.Parse(time.RFC3339, strings.TrimSpace(value))
+		if err != nil {
+			logInvalidAnnotation(sa, AnnotationDisabledUntil, value, fmt.Errorf("expected an RFC 3339 timestamp: %w", err), logger)
+			return true, time.Time{}
+		}
+		until = parsed
+	}
+
+	return disabled, until
+}
@@ -0,0 +1,88 @@
+package k8s
+
+import (
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// TestCache_Disabled tests parsing the disabled annotations
+func TestCache_Disabled(t *testing.T) {
+	now := time.Date(2026, 7, 1, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name         string
+		annotations  map[string]string
+		wantDisabled bool
+	}{
+		{name: "No annotations", wantDisabled: false},
+		{name: "Disabled", annotations: map[string]string{AnnotationDisabled: "true"}, wantDisabled: true},
+		{name: "Explicitly enabled", annotations: map[string]string{AnnotationDisabled: "false"}, wantDisabled: false},
+		{name: "Invalid flag fails closed", annotations: map[string]string{AnnotationDisabled: "yes please"}, wantDisabled: true},
+		{name: "Disabled until future", annotations: map[string]string{AnnotationDisabledUntil: "2026-07-01T13:00:00Z"}, wantDisabled: true},
+		{name: "Disabled until past", annotations: map[string]string{AnnotationDisabledUntil: "2026-07-01T11:00:00Z"}, wantDisabled: false},
+		{name: "Timestamp with offset", annotations: map[string]string{AnnotationDisabledUntil: "2026-07-01T13:30:00+01:00"}, wantDisabled: true},
+		{name: "Invalid timestamp fails closed", annotations: map[string]string{AnnotationDisabledUntil: "tomorrow"}, wantDisabled: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cache := NewCache(zap.NewNop())
+			cache.upsert(&corev1.ServiceAccount{ObjectMeta: metav1.ObjectMeta{
+				Name:        "api",
+				Namespace:   "shop",
+				Annotations: tt.annotations,
+			}})
+
+			perms, found := cache.Lookup("shop", "api")
+			if !found {
+				t.Fatal("Expected ServiceAccount to be cached")
+			}
+			if got := perms.DisabledAt(now); got != tt.wantDisabled {
+				t.Errorf("DisabledAt() = %v, want %v", got, tt.wantDisabled)
+			}
+		})
+	}
+}
+
+// TestCache_DisabledHandler tests notifying the revoke handler only when a ServiceAccount becomes disabled
+func TestCache_DisabledHandler(t *testing.T) {
+	cache := NewCache(zap.NewNop())
+	disabled := make(chan string, 10)
+	cache.SetRevokeHandler(func(namespace, name, reason string) {
+		if reason == RevokeDisabled {
+			disabled <- namespace + "/" + name
+		}
+	})
+
+	newSA := func(annotations map[string]string) *corev1.ServiceAccount {
+		return &corev1.ServiceAccount{ObjectMeta: metav1.ObjectMeta{
+			Name:        "api",
+			Namespace:   "shop",
+			Annotations: annotations,
+		}}
+	}
+
+	cache.upsert(newSA(nil))
+	cache.upsert(newSA(map[string]string{AnnotationDisabled: "true"}))
+	// Further updates while disabled do not notify again
+	cache.upsert(newSA(map[string]string{AnnotationDisabled: "true", "other": "change"}))
+
+	select {
+	case got := <-disabled:
+		if got != "shop/api" {
+			t.Errorf("Disabled handler called with %q, want %q", got, "shop/api")
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Expected disabled handler to be called")
+	}
+
+	select {
+	case got := <-disabled:
+		t.Errorf("Expected a single notification, got another for %q", got)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
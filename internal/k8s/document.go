@@ -0,0 +1,277 @@
+package k8s
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	httpmetrics "github.com/portswigger-tim/nats-k8s-oidc-callout/internal/httpserver"
+	"go.uber.org/zap"
+	corev1 "k8s.io/api/core/v1"
+	"sigs.k8s.io/yaml"
+)
+
+const (
+	// AnnotationPermissions holds a versioned JSON or YAML permissions document.
+	// A valid document replaces the legacy subject, queue and response annotations.
+	AnnotationPermissions = "nats.io/permissions"
+
+	// PermissionsDocumentVersion is the supported permissions document version
+	PermissionsDocumentVersion = "v1"
+
+	// Permission formats reported in the nats_auth_permission_format metric
+	PermissionFormatDocument = "document"
+	PermissionFormatLegacy   = "legacy"
+	PermissionFormatDefaults = "defaults"
+)
+
+// legacyPermissionAnnotations are the annotations replaced by a valid permissions document
+var legacyPermissionAnnotations = []string{
+	AnnotationAllowedPubSubjects,
+	AnnotationAllowedSubSubjects,
+	AnnotationAllowedSubQueues,
+	AnnotationAllowResponses,
+	AnnotationResponseMaxMsgs,
+	AnnotationResponseTTL,
+}
+
+// PermissionsDocument is the versioned permissions document held in the nats.io/permissions annotation.
+//
+// Example document:
+//
+//	version: v1
+//	description: order processing workers
+//	publish:
+//	  allow: ["orders.>"]
+//	  deny: ["orders.audit.>"]
+//	subscribe:
+//	  allow: ["events.>"]
+//	  queues:
+//	    - subject: jobs.>
+//	      queue: workers
+//	responses:
+//	  maxMsgs: 1
+//	  ttl: 5s
+//	limits:
+//	  subs: 100
+//	  payload: 1048576
+type PermissionsDocument struct {
+	// Version must be "v1".
+	Version string `json:"version"`
+	// Description is free text for humans and is ignored.
+	Description string `json:"description,omitempty"`
+	// Publish lists publish subjects added to and removed from the defaults.
+	Publish *SubjectRules `json:"publish,omitempty"`
+	// Subscribe lists subscribe subjects and queue subscriptions.
+	Subscribe *SubscribeRules `json:"subscribe,omitempty"`
+	// Responses overrides the global response permission defaults.
+	Responses *ResponseRules `json:"responses,omitempty"`
+	// Limits sets per-connection limits; unset fields stay unlimited.
+	Limits *Limits `json:"limits,omitempty"`
+}
+
+// SubjectRules lists subjects to allow and deny.
+type SubjectRules struct {
+	Allow []string `json:"allow,omitempty"`
+	Deny  []string `json:"deny,omitempty"`
+}
+
+// SubscribeRules lists subscribe subjects to allow and deny, and queue-group-restricted subscriptions.
+type SubscribeRules struct {
+	Allow  []string            `json:"allow,omitempty"`
+	Deny   []string            `json:"deny,omitempty"`
+	Queues []QueueSubscription `json:"queues,omitempty"`
+}
+
+// ResponseRules overrides the response permission defaults.
+type ResponseRules struct {
+	// Enabled turns response permissions on or off.
+	Enabled *bool `json:"enabled,omitempty"`
+	// MaxMsgs is the number of responses allowed per request; -1 means unlimited.
+	MaxMsgs *int `json:"maxMsgs,omitempty"`
+	// TTL is how long a responder may reply, as a Go duration.
+	TTL string `json:"ttl,omitempty"`
+}
+
+// Limits sets per-connection limits enforced by the NATS server; nil fields are unlimited.
+type Limits struct {
+	// Subs is the maximum number of subscriptions.
+	Subs *int64 `json:"subs,omitempty"`
+	// Payload is the maximum message payload in bytes.
+	Payload *int64 `json:"payload,omitempty"`
+	// Data is the maximum number of bytes a connection may receive.
+	Data *int64 `json:"data,omitempty"`
+}
+
+// publishRules returns the document's publish rules, empty when unset.
+func (d *PermissionsDocument) publishRules() SubjectRules {
+	if d.Publish == nil {
+		return SubjectRules{}
+	}
+	return *d.Publish
+}
+
+// subscribeRules returns the document's subscribe rules, empty when unset.
+func (d *PermissionsDocument) subscribeRules() SubscribeRules {
+	if d.Subscribe == nil {
+		return SubscribeRules{}
+	}
+	return *d.Subscribe
+}
+
+// ParsePermissionsDocument strictly parses a YAML or JSON permissions document.
+// Unknown fields, unsupported versions and invalid values are reported as errors.
+func ParsePermissionsDocument(data []byte) (*PermissionsDocument, error) {
+	doc := &PermissionsDocument{}
+	if err := yaml.UnmarshalStrict(data, doc); err != nil {
+		return nil, fmt.Errorf("failed to parse permissions document: %w", err)
+	}
+
+	if doc.Version != PermissionsDocumentVersion {
+		return nil, fmt.Errorf("unsupported permissions document version %q (expected %q)", doc.Version, PermissionsDocumentVersion)
+	}
+
+	if doc.Publish != nil {
+		if err := validateDocumentSubjects("publish.allow", doc.Publish.Allow); err != nil {
+			return nil, err
+		}
+		if err := validateDocumentSubjects("publish.deny", doc.Publish.Deny); err != nil {
+			return nil, err
+		}
+	}
+
+	if doc.Subscribe != nil {
+		if err := validateDocumentSubjects("subscribe.allow", doc.Subscribe.Allow); err != nil {
+			return nil, err
+		}
+		if err := validateDocumentSubjects("subscribe.deny", doc.Subscribe.Deny); err != nil {
+			return nil, err
+		}
+		for i, q := range doc.Subscribe.Queues {
+			if err := validateDocumentSubjects(fmt.Sprintf("subscribe.queues[%d]", i), []string{q.Subject}); err != nil {
+				return nil, err
+			}
+			if err := validateQueueSubscription(q); err != nil {
+				return nil, fmt.Errorf("subscribe.queues[%d]: %w", i, err)
+			}
+		}
+	}
+
+	if r := doc.Responses; r != nil {
+		if r.MaxMsgs != nil && (*r.MaxMsgs == 0 || *r.MaxMsgs < -1) {
+			return nil, fmt.Errorf("responses.maxMsgs: must be a positive integer or -1 for unlimited")
+		}
+		if r.TTL != "" {
+			ttl, err := time.ParseDuration(r.TTL)
+			if err != nil {
+				return nil, fmt.Errorf("responses.ttl: %w", err)
+			}
+			if ttl < 0 {
+				return nil, fmt.Errorf("responses.ttl: must not be negative")
+			}
+		}
+	}
+
+	if l := doc.Limits; l != nil {
+		limits := []struct {
+			name  string
+			value *int64
+		}{{"subs", l.Subs}, {"payload", l.Payload}, {"data", l.Data}}
+		for _, limit := range limits {
+			if limit.value != nil && *limit.value < -1 {
+				return nil, fmt.Errorf("limits.%s: must be -1 for unlimited or a non-negative number", limit.name)
+			}
+		}
+	}
+
+	return doc, nil
+}
+
+// validateDocumentSubjects checks the syntax of the subjects in a document list.
+// NATS internal inbox subjects are rejected since they are managed automatically.
+func validateDocumentSubjects(path string, subjects []string) error {
+	for i, subject := range subjects {
+		if strings.HasPrefix(subject, "_INBOX") || strings.HasPrefix(subject, "_REPLY") {
+			return fmt.Errorf("%s[%d]: NATS internal subject %q is managed automatically", path, i, subject)
+		}
+		if err := validateSubject(subject); err != nil {
+			return fmt.Errorf("%s[%d]: %w", path, i, err)
+		}
+	}
+	return nil
+}
+
+// permissionsDocument returns the ServiceAccount's permissions document, or nil when it has none.
+// Invalid documents are logged, counted and ignored, leaving the legacy annotations in effect.
+func permissionsDocument(sa *corev1.ServiceAccount, logger *zap.Logger) *PermissionsDocument {
+	value, ok := sa.Annotations[AnnotationPermissions]
+	if !ok {
+		return nil
+	}
+
+	doc, err := ParsePermissionsDocument([]byte(value))
+	if err != nil {
+		logger.Warn("Ignoring invalid permissions document",
+			zap.String("namespace", sa.Namespace),
+			zap.String("serviceaccount", sa.Name),
+			zap.String("annotation", AnnotationPermissions),
+			zap.Error(err))
+		if countDrops(logger) {
+			httpmetrics.IncrementPermissionDocumentErrors(sa.Namespace, sa.Name)
+		}
+		return nil
+	}
+
+	var overridden []string
+	for _, annotation := range legacyPermissionAnnotations {
+		if _, ok := sa.Annotations[annotation]; ok {
+			overridden = append(overridden, annotation)
+		}
+	}
+	if len(overridden) > 0 {
+		logger.Warn("Ignoring legacy permission annotations replaced by the permissions document",
+			zap.String("namespace", sa.Namespace),
+			zap.String("serviceaccount", sa.Name),
+			zap.Strings("annotations", overridden))
+	}
+
+	return doc
+}
+
+// permissionFormat reports which permission format a ServiceAccount uses.
+func permissionFormat(sa *corev1.ServiceAccount, doc *PermissionsDocument) string {
+	if doc != nil {
+		return PermissionFormatDocument
+	}
+	for _, annotation := range legacyPermissionAnnotations {
+		if _, ok := sa.Annotations[annotation]; ok {
+			return PermissionFormatLegacy
+		}
+	}
+	return PermissionFormatDefaults
+}
+
+// documentResponsePermission resolves the response permission from the global defaults and
+// the document's response rules. Returns nil when responses are disabled.
+func documentResponsePermission(rules *ResponseRules, opts Options) *ResponsePermission {
+	allow := !opts.DisableResponses
+	if rules != nil && rules.Enabled != nil {
+		allow = *rules.Enabled
+	}
+	if !allow {
+		return nil
+	}
+
+	resp := &ResponsePermission{
+		MaxMsgs: opts.ResponseMaxMsgs,
+		Expires: opts.ResponseTTL,
+	}
+	if rules != nil && rules.MaxMsgs != nil {
+		resp.MaxMsgs = *rules.MaxMsgs
+	}
+	if rules != nil && rules.TTL != "" {
+		// Validated by ParsePermissionsDocument
+		resp.Expires, _ = time.ParseDuration(rules.TTL)
+	}
+	return resp
+}
@@ -0,0 +1,202 @@
+package k8s
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// TestParsePermissionsDocument tests strict parsing and schema validation of permissions documents
+func TestParsePermissionsDocument(t *testing.T) {
+	tests := []struct {
+		name    string
+		data    string
+		wantErr string
+	}{
+		{
+			name: "YAML document",
+			data: `
+version: v1
+description: order workers
+publish:
+  allow: ["orders.>"]
+  deny: ["orders.audit.>"]
+subscribe:
+  allow: ["events.>"]
+  queues:
+    - subject: jobs.>
+      queue: workers
+responses:
+  maxMsgs: -1
+  ttl: 5s
+limits:
+  subs: 100
+`,
+		},
+		{name: "JSON document", data: `{"version": "v1", "publish": {"allow": ["orders.>"]}}`},
+		{name: "Missing version", data: `publish: {allow: ["orders.>"]}`, wantErr: "unsupported permissions document version"},
+		{name: "Unsupported version", data: `version: v2`, wantErr: "unsupported permissions document version"},
+		{name: "Unknown field", data: "version: v1\npublish:\n  allowed: [\"orders.>\"]", wantErr: "unknown field"},
+		{name: "Duplicate field", data: "version: v1\nversion: v1", wantErr: "failed to parse permissions document"},
+		{name: "Invalid subject", data: "version: v1\npublish:\n  allow: [\"orders..>\"]", wantErr: "publish.allow[0]"},
+		{name: "Internal subject", data: "version: v1\nsubscribe:\n  deny: [\"_INBOX.>\"]", wantErr: "subscribe.deny[0]"},
+		{name: "Invalid queue", data: "version: v1\nsubscribe:\n  queues: [{subject: jobs.>, queue: \"bad queue\"}]", wantErr: "subscribe.queues[0]"},
+		{name: "Invalid max messages", data: "version: v1\nresponses:\n  maxMsgs: 0", wantErr: "responses.maxMsgs"},
+		{name: "Invalid TTL", data: "version: v1\nresponses:\n  ttl: soon", wantErr: "responses.ttl"},
+		{name: "Negative TTL", data: "version: v1\nresponses:\n  ttl: -5s", wantErr: "responses.ttl"},
+		{name: "Invalid limit", data: "version: v1\nlimits:\n  payload: -2", wantErr: "limits.payload"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ParsePermissionsDocument([]byte(tt.data))
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatalf("ParsePermissionsDocument() unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("ParsePermissionsDocument() error = %v, want error containing %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+// TestCache_PermissionsDocument tests building permissions from a permissions document and its precedence over legacy annotations
+func TestCache_PermissionsDocument(t *testing.T) {
+	document := `
+version: v1
+publish:
+  allow: ["orders.{{.ServiceAccount}}.>"]
+  deny: ["shop.admin.>"]
+subscribe:
+  allow: ["events.>"]
+  deny: ["shop.secrets.>"]
+  queues:
+    - subject: jobs.>
+      queue: workers
+responses:
+  ttl: 5s
+limits:
+  subs: 100
+`
+
+	tests := []struct {
+		name        string
+		annotations map[string]string
+		wantFormat  string
+		wantPub     []string
+		wantSub     []string
+		wantPubDeny []string
+		wantSubDeny []string
+		wantQueues  []string
+		wantResp    *ResponsePermission
+		wantSubs    int64
+	}{
+		{
+			name:        "Document",
+			annotations: map[string]string{"nats.io/permissions": document},
+			wantFormat:  PermissionFormatDocument,
+			wantPub:     []string{"shop.>", "orders.api.>"},
+			wantSub:     []string{"_INBOX.>", "_INBOX_shop_api.>", "shop.>", "events.>"},
+			wantPubDeny: []string{"shop.admin.>"},
+			wantSubDeny: []string{"shop.secrets.>"},
+			wantQueues:  []string{"jobs.> workers"},
+			wantResp:    &ResponsePermission{MaxMsgs: 1, Expires: 5 * time.Second},
+			wantSubs:    100,
+		},
+		{
+			name: "Document replaces legacy annotations",
+			annotations: map[string]string{
+				"nats.io/permissions":          document,
+				"nats.io/allowed-pub-subjects": "legacy.>",
+				"nats.io/allowed-sub-queues":   "legacy.> workers",
+				"nats.io/allow-responses":      "false",
+			},
+			wantFormat:  PermissionFormatDocument,
+			wantPub:     []string{"shop.>", "orders.api.>"},
+			wantSub:     []string{"_INBOX.>", "_INBOX_shop_api.>", "shop.>", "events.>"},
+			wantPubDeny: []string{"shop.admin.>"},
+			wantSubDeny: []string{"shop.secrets.>"},
+			wantQueues:  []string{"jobs.> workers"},
+			wantResp:    &ResponsePermission{MaxMsgs: 1, Expires: 5 * time.Second},
+			wantSubs:    100,
+		},
+		{
+			name: "Invalid document falls back to legacy annotations",
+			annotations: map[string]string{
+				"nats.io/permissions":          "version: v1\npublish:\n  allowed: [\"orders.>\"]",
+				"nats.io/allowed-pub-subjects": "legacy.>",
+			},
+			wantFormat: PermissionFormatLegacy,
+			wantPub:    []string{"shop.>", "legacy.>"},
+			wantSub:    []string{"_INBOX.>", "_INBOX_shop_api.>", "shop.>"},
+			wantResp:   &ResponsePermission{MaxMsgs: 1},
+		},
+		{
+			name:       "Defaults only",
+			wantFormat: PermissionFormatDefaults,
+			wantPub:    []string{"shop.>"},
+			wantSub:    []string{"_INBOX.>", "_INBOX_shop_api.>", "shop.>"},
+			wantResp:   &ResponsePermission{MaxMsgs: 1},
+		},
+		{
+			name:        "Document disables responses",
+			annotations: map[string]string{"nats.io/permissions": `{"version": "v1", "responses": {"enabled": false}}`},
+			wantFormat:  PermissionFormatDocument,
+			wantPub:     []string{"shop.>"},
+			wantSub:     []string{"_INBOX.>", "_INBOX_shop_api.>", "shop.>"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cache := NewCache(zap.NewNop())
+			cache.upsert(&corev1.ServiceAccount{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:        "api",
+					Namespace:   "shop",
+					Annotations: tt.annotations,
+				},
+			})
+
+			perms, found := cache.Lookup("shop", "api")
+			if !found {
+				t.Fatal("Expected ServiceAccount to be in cache after upsert")
+			}
+
+			if perms.Format != tt.wantFormat {
+				t.Errorf("Format = %q, want %q", perms.Format, tt.wantFormat)
+			}
+			if !equalStringSlices(perms.Publish, tt.wantPub) {
+				t.Errorf("Publish = %v, want %v", perms.Publish, tt.wantPub)
+			}
+			if !equalStringSlices(perms.Subscribe, tt.wantSub) {
+				t.Errorf("Subscribe = %v, want %v", perms.Subscribe, tt.wantSub)
+			}
+			if !equalStringSlices(perms.PublishDeny, tt.wantPubDeny) {
+				t.Errorf("PublishDeny = %v, want %v", perms.PublishDeny, tt.wantPubDeny)
+			}
+			if !equalStringSlices(perms.SubscribeDeny, tt.wantSubDeny) {
+				t.Errorf("SubscribeDeny = %v, want %v", perms.SubscribeDeny, tt.wantSubDeny)
+			}
+			if got := QueueSubscriptionStrings(perms.SubscribeQueues); !equalStringSlices(got, tt.wantQueues) {
+				t.Errorf("SubscribeQueues = %v, want %v", got, tt.wantQueues)
+			}
+			switch {
+			case tt.wantResp == nil && perms.Response != nil:
+				t.Errorf("Response = %+v, want nil", perms.Response)
+			case tt.wantResp != nil && (perms.Response == nil || *perms.Response != *tt.wantResp):
+				t.Errorf("Response = %+v, want %+v", perms.Response, tt.wantResp)
+			}
+			if tt.wantSubs != 0 && (perms.Limits == nil || perms.Limits.Subs == nil || *perms.Limits.Subs != tt.wantSubs) {
+				t.Errorf("Limits = %+v, want subs %d", perms.Limits, tt.wantSubs)
+			}
+		})
+	}
+}
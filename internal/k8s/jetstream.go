@@ -0,0 +1,171 @@
+package k8s
+
+import (
+	"fmt"
+	"strings"
+
+	"go.uber.org/zap"
+	corev1 "k8s.io/api/core/v1"
+
+	httpmetrics "github.com/portswigger-tim/nats-k8s-oidc-callout/internal/httpserver"
+)
+
+const (
+	// AnnotationJetStreamStreams grants access to JetStream streams as "STREAM:mode" entries,
+	// where mode is read, write or rw (e.g. "ORDERS:read,EVENTS:write").
+	AnnotationJetStreamStreams = "nats.io/jetstream-streams"
+	// AnnotationJetStreamConsumers grants use of existing durable consumers as "STREAM/CONSUMER" entries.
+	AnnotationJetStreamConsumers = "nats.io/jetstream-consumers"
+	// AnnotationJetStreamDomain overrides the JetStream domain used for API subjects.
+	AnnotationJetStreamDomain = "nats.io/jetstream-domain"
+
+	// Stream access modes
+	streamModeRead      = "read"
+	streamModeWrite     = "write"
+	streamModeReadWrite = "rw"
+)
+
+// jetStreamAPIPrefix returns the JetStream API subject prefix for a domain.
+func jetStreamAPIPrefix(domain string) string {
+	if domain == "" {
+		return "$JS.API"
+	}
+	return "$JS." + domain + ".API"
+}
+
+// validateJetStreamName checks that a stream, consumer or domain name can be used as a single subject token.
+func validateJetStreamName(name string) error {
+	if name == "" {
+		return fmt.Errorf("name is empty")
+	}
+	if strings.ContainsAny(name, ".*>/\\ \t\r\n") {
+		return fmt.Errorf("name %q contains an invalid character", name)
+	}
+	return nil
+}
+
+// streamSubjects returns the publish subjects for accessing a stream in the given mode.
+//   - write: stream info lookups; the stream's own subjects are granted with nats.io/allowed-pub-subjects
+//   - read: stream info, direct and API message gets, and ephemeral and ordered push consumers with
+//     flow control. Both CONSUMER.CREATE.<stream> and the named CONSUMER.CREATE.<stream>.> form are
+//     granted, as clients name their ephemeral consumers on servers 2.9 and later. DURABLE.CREATE,
+//     CONSUMER.DELETE and fetch or ack subjects are not, so existing durable consumers on the stream
+//     stay out of reach; grant those with nats.io/jetstream-consumers.
+func streamSubjects(api, stream, mode string) ([]string, error) {
+	subjects := []string{api + ".STREAM.INFO." + stream}
+
+	switch mode {
+	case streamModeWrite:
+		return subjects, nil
+	case streamModeRead, streamModeReadWrite:
+		return append(subjects,
+			api+".STREAM.MSG.GET."+stream,
+			api+".DIRECT.GET."+stream,
+			api+".DIRECT.GET."+stream+".>",
+			api+".CONSUMER.CREATE."+stream,
+			api+".CONSUMER.CREATE."+stream+".>",
+			"$JS.FC."+stream+".>",
+		), nil
+	default:
+		return nil, fmt.Errorf("unknown stream access mode %q (expected read, write or rw)", mode)
+	}
+}
+
+// consumerSubjects returns the publish subjects for consuming from an existing durable consumer.
+func consumerSubjects(api, stream, consumer string) []string {
+	return []string{
+		api + ".STREAM.INFO." + stream,
+		api + ".CONSUMER.INFO." + stream + "." + consumer,
+		api + ".CONSUMER.MSG.NEXT." + stream + "." + consumer,
+		"$JS.ACK." + stream + "." + consumer + ".>",
+		"$JS.FC." + stream + "." + consumer + ".>",
+	}
+}
+
+// jetStreamSubjects expands the JetStream stream, consumer, KV and Object Store annotations into publish subjects.
+// Invalid entries and streams the registry does not permit are logged and skipped.
+func jetStreamSubjects(sa *corev1.ServiceAccount, domain string, registry *SubjectRegistry, logger *zap.Logger) []string {
+	if value, ok := sa.Annotations[AnnotationJetStreamDomain]; ok {
+		value = strings.TrimSpace(value)
+		if err := validateJetStreamName(value); err != nil {
+			logInvalidAnnotation(sa, AnnotationJetStreamDomain, value, err, logger)
+		} else {
+			domain = value
+		}
+	}
+	api := jetStreamAPIPrefix(domain)
+
+	var subjects []string
+
+	for _, entry := range splitAnnotationList(sa.Annotations[AnnotationJetStreamStreams]) {
+		stream, mode, _ := strings.Cut(entry, ":")
+		if err := validateJetStreamName(stream); err != nil {
+			logInvalidAnnotation(sa, AnnotationJetStreamStreams, entry, err, logger)
+			continue
+		}
+		expanded, err := streamSubjects(api, stream, strings.ToLower(mode))
+		if err != nil {
+			logInvalidAnnotation(sa, AnnotationJetStreamStreams, entry, err, logger)
+			continue
+		}
+		if !streamPermitted(sa, registry, AnnotationJetStreamStreams, stream, logger) {
+			continue
+		}
+		subjects = append(subjects, expanded...)
+	}
+
+	for _, entry := range splitAnnotationList(sa.Annotations[AnnotationJetStreamConsumers]) {
+		stream, consumer, ok := strings.Cut(entry, "/")
+		if !ok {
+			logInvalidAnnotation(sa, AnnotationJetStreamConsumers, entry, fmt.Errorf("expected STREAM/CONSUMER"), logger)
+			continue
+		}
+		if err := validateJetStreamName(stream); err != nil {
+			logInvalidAnnotation(sa, AnnotationJetStreamConsumers, entry, err, logger)
+			continue
+		}
+		if err := validateJetStreamName(consumer); err != nil {
+			logInvalidAnnotation(sa, AnnotationJetStreamConsumers, entry, err, logger)
+			continue
+		}
+		if !streamPermitted(sa, registry, AnnotationJetStreamConsumers, stream, logger) {
+			continue
+		}
+		subjects = append(subjects, consumerSubjects(api, stream, consumer)...)
+	}
+
+	subjects = append(subjects, bucketSubjects(sa, AnnotationKVBuckets, api, kvBucketSubjects, registry, logger)...)
+	subjects = append(subjects, bucketSubjects(sa, AnnotationObjectBuckets, api, objectBucketSubjects, registry, logger)...)
+
+	return subjects
+}
+
+// streamPermitted checks a stream against the registry's stream ownership, logging and counting denials
+func streamPermitted(sa *corev1.ServiceAccount, registry *SubjectRegistry, annotation, stream string, logger *zap.Logger) bool {
+	reason := registry.checkStream(sa.Namespace, sa.Name, stream)
+	if reason == "" {
+		return true
+	}
+
+	logger.Warn("Dropped JetStream stream denied by subject registry",
+		zap.String("namespace", sa.Namespace),
+		zap.String("serviceaccount", sa.Name),
+		zap.String("annotation", annotation),
+		zap.String("stream", stream),
+		zap.String("reason", reason))
+	if countDrops(logger) {
+		httpmetrics.IncrementRegistryDeniedSubjects(sa.Namespace, sa.Name, annotation, reason)
+	}
+	return false
+}
+
+// splitAnnotationList splits a comma-separated annotation value into trimmed, non-empty entries.
+func splitAnnotationList(value string) []string {
+	var entries []string
+	for _, part := range strings.Split(value, ",") {
+		if trimmed := strings.TrimSpace(part); trimmed != "" {
+			entries = append(entries, trimmed)
+		}
+	}
+	return entries
+}
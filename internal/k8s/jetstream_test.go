@@ -0,0 +1,153 @@
+package k8s
+
+import (
+	"testing"
+
+	"go.uber.org/zap"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// TestCache_JetStreamAnnotations tests expanding stream and consumer annotations into $JS.API subjects
+func TestCache_JetStreamAnnotations(t *testing.T) {
+	registry, err := ParseSubjectRegistry([]byte(`
+streams:
+  - stream: EVENTS
+    owner: shop
+  - stream: ORDERS
+    owner: orders-team
+`))
+	if err != nil {
+		t.Fatalf("Failed to parse registry: %v", err)
+	}
+	registryOpts := DefaultOptions()
+	registryOpts.Registry = registry
+
+	tests := []struct {
+		name        string
+		opts        Options
+		annotations map[string]string
+		wantPub     []string
+	}{
+		{
+			name:        "Write access to a stream",
+			opts:        DefaultOptions(),
+			annotations: map[string]string{"nats.io/jetstream-streams": "EVENTS:write"},
+			wantPub:     []string{"shop.>", "$JS.API.STREAM.INFO.EVENTS"},
+		},
+		{
+			name:        "Read access to a stream",
+			opts:        DefaultOptions(),
+			annotations: map[string]string{"nats.io/jetstream-streams": "ORDERS:read"},
+			wantPub: []string{
+				"shop.>",
+				"$JS.API.STREAM.INFO.ORDERS",
+				"$JS.API.STREAM.MSG.GET.ORDERS",
+				"$JS.API.DIRECT.GET.ORDERS",
+				"$JS.API.DIRECT.GET.ORDERS.>",
+				"$JS.API.CONSUMER.CREATE.ORDERS",
+				"$JS.API.CONSUMER.CREATE.ORDERS.>",
+				"$JS.FC.ORDERS.>",
+			},
+		},
+		{
+			name:        "Durable consumer",
+			opts:        DefaultOptions(),
+			annotations: map[string]string{"nats.io/jetstream-consumers": "ORDERS/worker"},
+			wantPub: []string{
+				"shop.>",
+				"$JS.API.STREAM.INFO.ORDERS",
+				"$JS.API.CONSUMER.INFO.ORDERS.worker",
+				"$JS.API.CONSUMER.MSG.NEXT.ORDERS.worker",
+				"$JS.ACK.ORDERS.worker.>",
+				"$JS.FC.ORDERS.worker.>",
+			},
+		},
+		{
+			name: "Global JetStream domain",
+			opts: Options{JetStreamDomain: "hub"},
+			annotations: map[string]string{
+				"nats.io/jetstream-streams":   "EVENTS:write",
+				"nats.io/jetstream-consumers": "EVENTS/audit",
+			},
+			wantPub: []string{
+				"shop.>",
+				"$JS.hub.API.STREAM.INFO.EVENTS",
+				"$JS.hub.API.CONSUMER.INFO.EVENTS.audit",
+				"$JS.hub.API.CONSUMER.MSG.NEXT.EVENTS.audit",
+				"$JS.ACK.EVENTS.audit.>",
+				"$JS.FC.EVENTS.audit.>",
+			},
+		},
+		{
+			name: "Annotation overrides JetStream domain",
+			opts: Options{JetStreamDomain: "hub"},
+			annotations: map[string]string{
+				"nats.io/jetstream-streams": "EVENTS:write",
+				"nats.io/jetstream-domain":  "leaf",
+			},
+			wantPub: []string{"shop.>", "$JS.leaf.API.STREAM.INFO.EVENTS"},
+		},
+		{
+			name: "Invalid entries skipped",
+			opts: DefaultOptions(),
+			annotations: map[string]string{
+				"nats.io/jetstream-streams":   "ORDERS, EVENTS:admin, ORD.ERS:read, AUDIT:write",
+				"nats.io/jetstream-consumers": "ORDERS, ORDERS/*",
+			},
+			wantPub: []string{"shop.>", "$JS.API.STREAM.INFO.AUDIT"},
+		},
+		{
+			name:        "Registry allows owned stream",
+			opts:        registryOpts,
+			annotations: map[string]string{"nats.io/jetstream-streams": "EVENTS:write"},
+			wantPub:     []string{"shop.>", "$JS.API.STREAM.INFO.EVENTS"},
+		},
+		{
+			name:        "Registry drops foreign stream",
+			opts:        registryOpts,
+			annotations: map[string]string{"nats.io/jetstream-streams": "ORDERS:read,EVENTS:write"},
+			wantPub:     []string{"shop.>", "$JS.API.STREAM.INFO.EVENTS"},
+		},
+		{
+			name:        "Registry drops unregistered stream",
+			opts:        registryOpts,
+			annotations: map[string]string{"nats.io/jetstream-streams": "PAYMENTS:read"},
+			wantPub:     []string{"shop.>"},
+		},
+		{
+			name:        "Registry drops raw JetStream and bucket subjects",
+			opts:        registryOpts,
+			annotations: map[string]string{"nats.io/allowed-pub-subjects": "$JS.API.STREAM.INFO.ORDERS,$KV.config.>"},
+			wantPub:     []string{"shop.>"},
+		},
+		{
+			name:        "Registry drops consumer on foreign stream",
+			opts:        registryOpts,
+			annotations: map[string]string{"nats.io/jetstream-consumers": "ORDERS/worker"},
+			wantPub:     []string{"shop.>"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cache := NewCacheWithOptions(zap.NewNop(), tt.opts)
+			cache.upsert(&corev1.ServiceAccount{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:        "api",
+					Namespace:   "shop",
+					Annotations: tt.annotations,
+				},
+			})
+
+			pubPerms, _, found := cache.Get("shop", "api")
+			if !found {
+				t.Fatal("Expected ServiceAccount to be in cache after upsert")
+			}
+
+			if !equalStringSlices(pubPerms, tt.wantPub) {
+				t.Errorf("pubPerms = %v, want %v", pubPerms, tt.wantPub)
+			}
+		})
+	}
+}
@@ -0,0 +1,106 @@
+package k8s
+
+import (
+	"fmt"
+	"strings"
+
+	"go.uber.org/zap"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/client-go/informers"
+	"sigs.k8s.io/yaml"
+)
+
+// DefaultLockdownKey is the ConfigMap key holding the lockdown document.
+const DefaultLockdownKey = "lockdown.yaml"
+
+// Lockdown denies all new connections except those of allowlisted namespaces and ServiceAccounts.
+//
+// Example document:
+//
+//	enabled: true
+//	reason: "INC-1234: leaked credentials"
+//	allow: ["nats-system", "platform/operator"]
+type Lockdown struct {
+	Enabled bool `json:"enabled"`
+	// Reason is included in denial logs and audit events
+	Reason string `json:"reason,omitempty"`
+	// Allow lists namespaces ("ns") or ServiceAccounts ("ns/name") still allowed to connect
+	Allow []string `json:"allow,omitempty"`
+}
+
+// ParseLockdown strictly parses a YAML or JSON lockdown document. An empty document is not locked down.
+func ParseLockdown(data []byte) (*Lockdown, error) {
+	lockdown := &Lockdown{}
+	if err := yaml.UnmarshalStrict(data, lockdown); err != nil {
+		return nil, fmt.Errorf("failed to parse lockdown: %w", err)
+	}
+	if err := lockdown.Validate(); err != nil {
+		return nil, err
+	}
+	return lockdown, nil
+}
+
+// Validate checks that every allowlist entry is a namespace or namespace/name.
+func (l *Lockdown) Validate() error {
+	for _, entry := range l.Allow {
+		namespace, name, hasName := strings.Cut(entry, "/")
+		if namespace == "" || (hasName && (name == "" || strings.Contains(name, "/"))) {
+			return fmt.Errorf("lockdown allow entry %q: must be a namespace or namespace/serviceaccount", entry)
+		}
+	}
+	return nil
+}
+
+// Allows reports whether a ServiceAccount may connect. Everyone may connect when there is no
+// lockdown in effect.
+func (l *Lockdown) Allows(namespace, serviceAccount string) bool {
+	if l == nil || !l.Enabled {
+		return true
+	}
+	for _, entry := range l.Allow {
+		if entry == namespace || entry == makeKey(namespace, serviceAccount) {
+			return true
+		}
+	}
+	return false
+}
+
+// WatchLockdown watches a ConfigMap holding the lockdown document under key and passes every
+// change to fn. Deleting the ConfigMap or its key lifts the lockdown (fn receives nil).
+// The factory must be scoped to the ConfigMap's namespace and started by the caller.
+// Invalid documents are logged and the previous state stays in effect.
+func (c *Client) WatchLockdown(factory informers.SharedInformerFactory, name, key string, fn func(*Lockdown)) error {
+	return watchConfigMap(factory, name,
+		func(cm *corev1.ConfigMap) {
+			data, ok := cm.Data[key]
+			if !ok {
+				c.logger.Info("Lockdown key not set, lockdown lifted",
+					zap.String("configmap", makeKey(cm.Namespace, cm.Name)),
+					zap.String("key", key))
+				fn(nil)
+				return
+			}
+
+			lockdown, err := ParseLockdown([]byte(data))
+			if err != nil {
+				c.logger.Error("Ignoring invalid lockdown",
+					zap.String("configmap", makeKey(cm.Namespace, cm.Name)),
+					zap.String("key", key),
+					zap.Error(err))
+				return
+			}
+
+			c.logger.Info("Lockdown updated from ConfigMap",
+				zap.String("configmap", makeKey(cm.Namespace, cm.Name)),
+				zap.Bool("enabled", lockdown.Enabled),
+				zap.String("reason", lockdown.Reason),
+				zap.Strings("allow", lockdown.Allow))
+			fn(lockdown)
+		},
+		func() {
+			c.logger.Warn("Lockdown ConfigMap deleted, lockdown lifted",
+				zap.String("configmap", name))
+			fn(nil)
+		},
+	)
+}
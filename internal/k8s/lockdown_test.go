@@ -0,0 +1,137 @@
+package k8s
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+// TestParseLockdown tests strict parsing and validation of lockdown documents
+func TestParseLockdown(t *testing.T) {
+	tests := []struct {
+		name    string
+		doc     string
+		wantErr bool
+	}{
+		{name: "Valid YAML", doc: "enabled: true\nreason: INC-1234\nallow: [nats-system, platform/operator]", wantErr: false},
+		{name: "Valid JSON", doc: `{"enabled":true,"allow":["nats-system"]}`, wantErr: false},
+		{name: "Empty document", doc: "", wantErr: false},
+		{name: "Unknown field", doc: "enabled: true\nallowlist: [a]", wantErr: true},
+		{name: "Empty entry", doc: `allow: [""]`, wantErr: true},
+		{name: "Missing namespace", doc: "allow: [/operator]", wantErr: true},
+		{name: "Missing name", doc: "allow: [platform/]", wantErr: true},
+		{name: "Too many segments", doc: "allow: [a/b/c]", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ParseLockdown([]byte(tt.doc))
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ParseLockdown() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+// TestLockdown_Allows tests the lockdown allowlist
+func TestLockdown_Allows(t *testing.T) {
+	lockdown := &Lockdown{Enabled: true, Allow: []string{"nats-system", "platform/operator"}}
+
+	tests := []struct {
+		name      string
+		lockdown  *Lockdown
+		namespace string
+		sa        string
+		want      bool
+	}{
+		{name: "No lockdown", lockdown: nil, namespace: "shop", sa: "api", want: true},
+		{name: "Disabled lockdown", lockdown: &Lockdown{Allow: []string{"nats-system"}}, namespace: "shop", sa: "api", want: true},
+		{name: "Not allowlisted", lockdown: lockdown, namespace: "shop", sa: "api", want: false},
+		{name: "Allowlisted namespace", lockdown: lockdown, namespace: "nats-system", sa: "any", want: true},
+		{name: "Allowlisted ServiceAccount", lockdown: lockdown, namespace: "platform", sa: "operator", want: true},
+		{name: "Other ServiceAccount in namespace", lockdown: lockdown, namespace: "platform", sa: "other", want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.lockdown.Allows(tt.namespace, tt.sa); got != tt.want {
+				t.Errorf("Allows(%q, %q) = %v, want %v", tt.namespace, tt.sa, got, tt.want)
+			}
+		})
+	}
+}
+
+// TestClient_WatchLockdown tests that ConfigMap changes toggle the lockdown
+func TestClient_WatchLockdown(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	fakeClient := fake.NewSimpleClientset()
+	client := NewClient(informers.NewSharedInformerFactory(fakeClient, 0), zap.NewNop())
+
+	var mu sync.Mutex
+	var current *Lockdown
+	calls := 0
+	state := func() (*Lockdown, int) {
+		mu.Lock()
+		defer mu.Unlock()
+		return current, calls
+	}
+
+	factory := informers.NewSharedInformerFactoryWithOptions(fakeClient, 0, informers.WithNamespace("nats-system"))
+	err := client.WatchLockdown(factory, "lockdown", DefaultLockdownKey, func(lockdown *Lockdown) {
+		mu.Lock()
+		defer mu.Unlock()
+		current = lockdown
+		calls++
+	})
+	if err != nil {
+		t.Fatalf("WatchLockdown() error = %v", err)
+	}
+
+	stopCh := make(chan struct{})
+	defer close(stopCh)
+	factory.Start(stopCh)
+	factory.WaitForCacheSync(stopCh)
+
+	cm := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: "lockdown", Namespace: "nats-system"},
+		Data:       map[string]string{DefaultLockdownKey: "enabled: true\nreason: INC-1234\nallow: [nats-system]"},
+	}
+	if _, err := fakeClient.CoreV1().ConfigMaps("nats-system").Create(ctx, cm, metav1.CreateOptions{}); err != nil {
+		t.Fatalf("Failed to create ConfigMap: %v", err)
+	}
+	time.Sleep(100 * time.Millisecond)
+
+	lockdown, _ := state()
+	if lockdown == nil || !lockdown.Enabled || lockdown.Reason != "INC-1234" {
+		t.Fatalf("lockdown after create = %+v, want enabled with reason INC-1234", lockdown)
+	}
+
+	// An invalid document keeps the previous state
+	cm.Data[DefaultLockdownKey] = "enabled: false\nallow: [a/b/c]"
+	if _, err := fakeClient.CoreV1().ConfigMaps("nats-system").Update(ctx, cm, metav1.UpdateOptions{}); err != nil {
+		t.Fatalf("Failed to update ConfigMap: %v", err)
+	}
+	time.Sleep(100 * time.Millisecond)
+
+	if lockdown, calls := state(); calls != 1 || lockdown == nil || !lockdown.Enabled {
+		t.Fatalf("lockdown after invalid update = %+v (%d calls), want previous state", lockdown, calls)
+	}
+
+	if err := fakeClient.CoreV1().ConfigMaps("nats-system").Delete(ctx, "lockdown", metav1.DeleteOptions{}); err != nil {
+		t.Fatalf("Failed to delete ConfigMap: %v", err)
+	}
+	time.Sleep(100 * time.Millisecond)
+
+	if lockdown, _ := state(); lockdown != nil {
+		t.Errorf("lockdown after delete = %+v, want nil", lockdown)
+	}
+}
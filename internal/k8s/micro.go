@@ -0,0 +1,114 @@
+package k8s
+
+import (
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+
+	"go.uber.org/zap"
+	corev1 "k8s.io/api/core/v1"
+)
+
+const (
+	// AnnotationMicroServices lists the nats.go/micro services a ServiceAccount runs (e.g. "inventory,pricing").
+	// Each service is granted its discovery subscriptions and endpoint subjects under "<service>.>",
+	// which matches services that add their endpoints to a group named after the service. Entries
+	// written as "service:subject" grant the given endpoint subject instead, e.g. "inventory:get" for
+	// an endpoint outside any group or "inventory:v1.inventory.>" for another group.
+	AnnotationMicroServices = "nats.io/micro-services"
+	// AnnotationMicroClient grants publishing to the micro discovery subjects ("true" or "false").
+	AnnotationMicroClient = "nats.io/micro-client"
+)
+
+// microVerbs are the discovery requests every micro service answers
+var microVerbs = []string{"PING", "INFO", "STATS"}
+
+// validateServiceName checks a micro service name (letters, digits, '-' and '_', as required by nats.go/micro).
+func validateServiceName(name string) error {
+	if name == "" {
+		return fmt.Errorf("service name is empty")
+	}
+	for _, r := range name {
+		if !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '-' || r == '_') {
+			return fmt.Errorf("service name %q contains an invalid character", name)
+		}
+	}
+	return nil
+}
+
+// microDiscoverySubjects returns the discovery subjects a micro service subscribes to:
+// $SRV.<VERB> for all services, $SRV.<VERB>.<service> by name and $SRV.<VERB>.<service>.<id> by instance.
+func microDiscoverySubjects(service string) []string {
+	subjects := make([]string, 0, len(microVerbs)*3)
+	for _, verb := range microVerbs {
+		subjects = append(subjects,
+			"$SRV."+verb,
+			"$SRV."+verb+"."+service,
+			"$SRV."+verb+"."+service+".*",
+		)
+	}
+	return subjects
+}
+
+// validateEndpointSubject checks an explicit micro endpoint subject. Internal subjects starting
+// with '_' or '$' cannot be claimed as endpoints.
+func validateEndpointSubject(subject string) error {
+	if strings.HasPrefix(subject, "_") || strings.HasPrefix(subject, "$") {
+		return fmt.Errorf("endpoint subject %q is reserved", subject)
+	}
+	return validateSubject(subject)
+}
+
+// microServiceSubjects expands the micro services annotation into discovery subscriptions and
+// endpoint subjects. Invalid service names and endpoint subjects are logged and skipped.
+func microServiceSubjects(sa *corev1.ServiceAccount, logger *zap.Logger) (discovery, endpoints []string) {
+	var services []string
+	for _, entry := range splitAnnotationList(sa.Annotations[AnnotationMicroServices]) {
+		service, endpoint, explicit := strings.Cut(entry, ":")
+		service = strings.TrimSpace(service)
+		if err := validateServiceName(service); err != nil {
+			logInvalidAnnotation(sa, AnnotationMicroServices, entry, err, logger)
+			continue
+		}
+		if explicit {
+			endpoint = strings.TrimSpace(endpoint)
+			if err := validateEndpointSubject(endpoint); err != nil {
+				logInvalidAnnotation(sa, AnnotationMicroServices, entry, err, logger)
+				continue
+			}
+		} else {
+			endpoint = service + ".>"
+		}
+
+		if !slices.Contains(services, service) {
+			services = append(services, service)
+			discovery = append(discovery, microDiscoverySubjects(service)...)
+		}
+		endpoints = append(endpoints, endpoint)
+	}
+	return discovery, endpoints
+}
+
+// microClientSubjects returns the discovery publish subjects for a ServiceAccount with the micro client flag.
+func microClientSubjects(sa *corev1.ServiceAccount, logger *zap.Logger) []string {
+	value, ok := sa.Annotations[AnnotationMicroClient]
+	if !ok {
+		return nil
+	}
+
+	enabled, err := strconv.ParseBool(strings.TrimSpace(value))
+	if err != nil {
+		logInvalidAnnotation(sa, AnnotationMicroClient, value, err, logger)
+		return nil
+	}
+	if !enabled {
+		return nil
+	}
+
+	subjects := make([]string, 0, len(microVerbs)*2)
+	for _, verb := range microVerbs {
+		subjects = append(subjects, "$SRV."+verb, "$SRV."+verb+".>")
+	}
+	return subjects
+}
@@ -0,0 +1,149 @@
+package k8s
+
+import (
+	"testing"
+
+	"go.uber.org/zap"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// TestCache_MicroAnnotations tests expanding micro service and micro client annotations
+func TestCache_MicroAnnotations(t *testing.T) {
+	tests := []struct {
+		name         string
+		opts         Options
+		annotations  map[string]string
+		wantPub      []string
+		wantSub      []string
+		wantResponse *ResponsePermission
+	}{
+		{
+			name:        "Micro service",
+			opts:        DefaultOptions(),
+			annotations: map[string]string{"nats.io/micro-services": "inventory"},
+			wantPub:     []string{"shop.>"},
+			wantSub: []string{
+				"_INBOX.>", "_INBOX_shop_api.>", "shop.>",
+				"$SRV.PING", "$SRV.PING.inventory", "$SRV.PING.inventory.*",
+				"$SRV.INFO", "$SRV.INFO.inventory", "$SRV.INFO.inventory.*",
+				"$SRV.STATS", "$SRV.STATS.inventory", "$SRV.STATS.inventory.*",
+				"inventory.>",
+			},
+			wantResponse: &ResponsePermission{MaxMsgs: 1},
+		},
+		{
+			name:        "Multiple services with invalid name skipped",
+			opts:        DefaultOptions(),
+			annotations: map[string]string{"nats.io/micro-services": "inventory, pri.cing, pricing"},
+			wantPub:     []string{"shop.>"},
+			wantSub: []string{
+				"_INBOX.>", "_INBOX_shop_api.>", "shop.>",
+				"$SRV.PING", "$SRV.PING.inventory", "$SRV.PING.inventory.*",
+				"$SRV.INFO", "$SRV.INFO.inventory", "$SRV.INFO.inventory.*",
+				"$SRV.STATS", "$SRV.STATS.inventory", "$SRV.STATS.inventory.*",
+				"$SRV.PING.pricing", "$SRV.PING.pricing.*",
+				"$SRV.INFO.pricing", "$SRV.INFO.pricing.*",
+				"$SRV.STATS.pricing", "$SRV.STATS.pricing.*",
+				"inventory.>", "pricing.>",
+			},
+			wantResponse: &ResponsePermission{MaxMsgs: 1},
+		},
+		{
+			name:        "Explicit endpoint subjects for a service without a group",
+			opts:        DefaultOptions(),
+			annotations: map[string]string{"nats.io/micro-services": "inventory:get, inventory:v1.stock.>, pricing:_INBOX.>, pricing:$SRV.>"},
+			wantPub:     []string{"shop.>"},
+			wantSub: []string{
+				"_INBOX.>", "_INBOX_shop_api.>", "shop.>",
+				"$SRV.PING", "$SRV.PING.inventory", "$SRV.PING.inventory.*",
+				"$SRV.INFO", "$SRV.INFO.inventory", "$SRV.INFO.inventory.*",
+				"$SRV.STATS", "$SRV.STATS.inventory", "$SRV.STATS.inventory.*",
+				"get", "v1.stock.>",
+			},
+			wantResponse: &ResponsePermission{MaxMsgs: 1},
+		},
+		{
+			name:        "Micro service enables responses disabled globally",
+			opts:        Options{DisableResponses: true},
+			annotations: map[string]string{"nats.io/micro-services": "inventory"},
+			wantPub:     []string{"shop.>"},
+			wantSub: []string{
+				"_INBOX.>", "_INBOX_shop_api.>", "shop.>",
+				"$SRV.PING", "$SRV.PING.inventory", "$SRV.PING.inventory.*",
+				"$SRV.INFO", "$SRV.INFO.inventory", "$SRV.INFO.inventory.*",
+				"$SRV.STATS", "$SRV.STATS.inventory", "$SRV.STATS.inventory.*",
+				"inventory.>",
+			},
+			wantResponse: &ResponsePermission{MaxMsgs: 1},
+		},
+		{
+			name: "Explicitly disabled responses kept",
+			opts: DefaultOptions(),
+			annotations: map[string]string{
+				"nats.io/micro-services":  "inventory",
+				"nats.io/allow-responses": "false",
+			},
+			wantPub: []string{"shop.>"},
+			wantSub: []string{
+				"_INBOX.>", "_INBOX_shop_api.>", "shop.>",
+				"$SRV.PING", "$SRV.PING.inventory", "$SRV.PING.inventory.*",
+				"$SRV.INFO", "$SRV.INFO.inventory", "$SRV.INFO.inventory.*",
+				"$SRV.STATS", "$SRV.STATS.inventory", "$SRV.STATS.inventory.*",
+				"inventory.>",
+			},
+		},
+		{
+			name:        "Micro client",
+			opts:        DefaultOptions(),
+			annotations: map[string]string{"nats.io/micro-client": "true"},
+			wantPub: []string{
+				"shop.>",
+				"$SRV.PING", "$SRV.PING.>",
+				"$SRV.INFO", "$SRV.INFO.>",
+				"$SRV.STATS", "$SRV.STATS.>",
+			},
+			wantSub:      []string{"_INBOX.>", "_INBOX_shop_api.>", "shop.>"},
+			wantResponse: &ResponsePermission{MaxMsgs: 1},
+		},
+		{
+			name:         "Invalid micro client flag ignored",
+			opts:         DefaultOptions(),
+			annotations:  map[string]string{"nats.io/micro-client": "yes"},
+			wantPub:      []string{"shop.>"},
+			wantSub:      []string{"_INBOX.>", "_INBOX_shop_api.>", "shop.>"},
+			wantResponse: &ResponsePermission{MaxMsgs: 1},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cache := NewCacheWithOptions(zap.NewNop(), tt.opts)
+			cache.upsert(&corev1.ServiceAccount{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:        "api",
+					Namespace:   "shop",
+					Annotations: tt.annotations,
+				},
+			})
+
+			perms, found := cache.Lookup("shop", "api")
+			if !found {
+				t.Fatal("Expected ServiceAccount to be in cache after upsert")
+			}
+
+			if !equalStringSlices(perms.Publish, tt.wantPub) {
+				t.Errorf("Publish = %v, want %v", perms.Publish, tt.wantPub)
+			}
+			if !equalStringSlices(perms.Subscribe, tt.wantSub) {
+				t.Errorf("Subscribe = %v, want %v", perms.Subscribe, tt.wantSub)
+			}
+			switch {
+			case tt.wantResponse == nil && perms.Response != nil:
+				t.Errorf("Response = %+v, want nil", perms.Response)
+			case tt.wantResponse != nil && (perms.Response == nil || *perms.Response != *tt.wantResponse):
+				t.Errorf("Response = %+v, want %+v", perms.Response, tt.wantResponse)
+			}
+		})
+	}
+}
@@ -0,0 +1,125 @@
+package k8s
+
+import (
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+
+	natsjwt "github.com/nats-io/jwt/v2"
+	httpmetrics "github.com/portswigger-tim/nats-k8s-oidc-callout/internal/httpserver"
+	"go.uber.org/zap"
+	corev1 "k8s.io/api/core/v1"
+)
+
+// AnnotationMQTT enables MQTT mode for a ServiceAccount ("true" or "false").
+// MQTT mode grants the server's internal MQTT subjects, allows MQTT connections and translates
+// MQTT topic filters (e.g. "sensors/+/temp/#") in the subject annotations.
+const AnnotationMQTT = "nats.io/mqtt"
+
+// mqttSubscribeSubjects are the internal subjects the NATS server subscribes to on behalf of MQTT
+// clients: QoS 1/2 delivery subjects and JetStream API replies.
+var mqttSubscribeSubjects = []string{"$MQTT.sub.>", "$MQTT.JSA.>"}
+
+// placeholderStripper removes placeholders so their dots are not mistaken for MQTT topic characters
+var placeholderStripper = strings.NewReplacer(
+	PlaceholderNamespace, "",
+	PlaceholderServiceAccount, "",
+	PlaceholderPod, "",
+	PlaceholderCluster, "",
+)
+
+// mqttMode reports whether MQTT mode is enabled for a ServiceAccount.
+func mqttMode(sa *corev1.ServiceAccount, logger *zap.Logger) bool {
+	value, ok := sa.Annotations[AnnotationMQTT]
+	if !ok {
+		return false
+	}
+
+	enabled, err := strconv.ParseBool(strings.TrimSpace(value))
+	if err != nil {
+		logInvalidAnnotation(sa, AnnotationMQTT, value, err, logger)
+		return false
+	}
+
+	return enabled
+}
+
+// isMQTTFilter reports whether an annotation entry is written in MQTT topic syntax.
+func isMQTTFilter(subject string) bool {
+	return strings.ContainsAny(subject, "/+#")
+}
+
+// translateMQTTFilter converts an MQTT topic filter to the NATS subjects it covers.
+// Levels are separated by '/', '+' becomes '*', and a trailing '#' becomes both the parent
+// subject and '>' since MQTT's multi-level wildcard also matches the parent level.
+// Empty levels and '.' in topic levels are not supported.
+func translateMQTTFilter(filter string) ([]string, error) {
+	levels := strings.Split(filter, "/")
+	tokens := make([]string, 0, len(levels))
+
+	for i, level := range levels {
+		switch {
+		case level == "":
+			return nil, fmt.Errorf("MQTT topic %q: empty topic levels are not supported", filter)
+		case level == "#":
+			if i != len(levels)-1 {
+				return nil, fmt.Errorf("MQTT topic %q: '#' must be the last level", filter)
+			}
+			if len(tokens) == 0 {
+				return []string{">"}, nil
+			}
+			parent := strings.Join(tokens, ".")
+			return []string{parent, parent + ".>"}, nil
+		case level == "+":
+			tokens = append(tokens, "*")
+		case strings.ContainsAny(level, "+#"):
+			return nil, fmt.Errorf("MQTT topic %q: wildcards must occupy a whole level", filter)
+		case strings.Contains(placeholderStripper.Replace(level), "."):
+			return nil, fmt.Errorf("MQTT topic %q: '.' in topic levels is not supported", filter)
+		default:
+			tokens = append(tokens, level)
+		}
+	}
+
+	return []string{strings.Join(tokens, ".")}, nil
+}
+
+// translateMQTTSubjects translates MQTT topic filters in annotation entries to NATS subjects.
+// Entries in NATS syntax are kept as they are; untranslatable filters are logged, counted and dropped.
+func translateMQTTSubjects(sa *corev1.ServiceAccount, annotation string, subjects []string, logger *zap.Logger) []string {
+	translated := make([]string, 0, len(subjects))
+	for _, subject := range subjects {
+		if !isMQTTFilter(subject) {
+			translated = append(translated, subject)
+			continue
+		}
+
+		natsSubjects, err := translateMQTTFilter(subject)
+		if err != nil {
+			logger.Warn("Rejected invalid MQTT topic filter",
+				zap.String("namespace", sa.Namespace),
+				zap.String("serviceaccount", sa.Name),
+				zap.String("annotation", annotation),
+				zap.Error(err))
+			if countDrops(logger) {
+				httpmetrics.IncrementInvalidSubjects(sa.Namespace, sa.Name, annotation)
+			}
+			continue
+		}
+		translated = append(translated, natsSubjects...)
+	}
+	return translated
+}
+
+// withMQTTConnectionType adds the MQTT connection type unless MQTT is already allowed, any type is
+// allowed, or the ServiceAccount lists its connection types explicitly.
+func withMQTTConnectionType(sa *corev1.ServiceAccount, types []string) []string {
+	if _, ok := sa.Annotations[AnnotationAllowedConnectionTypes]; ok || len(types) == 0 {
+		return types
+	}
+	if slices.Contains(types, natsjwt.ConnectionTypeMqtt) || slices.Contains(types, natsjwt.ConnectionTypeMqttWS) {
+		return types
+	}
+	return append(slices.Clone(types), natsjwt.ConnectionTypeMqtt)
+}
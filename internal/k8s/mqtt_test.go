@@ -0,0 +1,112 @@
+package k8s
+
+import (
+	"testing"
+
+	"go.uber.org/zap"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// TestTranslateMQTTFilter tests converting MQTT topic filters to NATS subjects
+func TestTranslateMQTTFilter(t *testing.T) {
+	tests := []struct {
+		name    string
+		filter  string
+		want    []string
+		wantErr bool
+	}{
+		{name: "Topic name", filter: "sensors/kitchen/temp", want: []string{"sensors.kitchen.temp"}},
+		{name: "Single-level wildcard", filter: "sensors/+/temp", want: []string{"sensors.*.temp"}},
+		{name: "Multi-level wildcard matches parent", filter: "a/+/b/#", want: []string{"a.*.b", "a.*.b.>"}},
+		{name: "Bare multi-level wildcard", filter: "#", want: []string{">"}},
+		{name: "Placeholder level", filter: "devices/{{.ServiceAccount}}/#", want: []string{"devices.{{.ServiceAccount}}", "devices.{{.ServiceAccount}}.>"}},
+		{name: "Multi-level wildcard not last", filter: "a/#/b", wantErr: true},
+		{name: "Partial-level wildcard", filter: "a/b+/c", wantErr: true},
+		{name: "Empty level", filter: "a//b", wantErr: true},
+		{name: "Leading separator", filter: "/a/b", wantErr: true},
+		{name: "Dot in level", filter: "a/b.c", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := translateMQTTFilter(tt.filter)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("translateMQTTFilter(%q) error = %v, wantErr %v", tt.filter, err, tt.wantErr)
+			}
+			if !equalStringSlices(got, tt.want) {
+				t.Errorf("translateMQTTFilter(%q) = %v, want %v", tt.filter, got, tt.want)
+			}
+		})
+	}
+}
+
+// TestCache_MQTTMode tests MQTT mode permissions and topic filter translation
+func TestCache_MQTTMode(t *testing.T) {
+	tests := []struct {
+		name          string
+		annotations   map[string]string
+		wantPub       []string
+		wantSub       []string
+		wantConnTypes []string
+	}{
+		{
+			name: "MQTT mode",
+			annotations: map[string]string{
+				"nats.io/mqtt":                 "true",
+				"nats.io/allowed-pub-subjects": "telemetry/{{.ServiceAccount}}/#",
+				"nats.io/allowed-sub-subjects": "commands/+/reboot, shared.status",
+			},
+			wantPub:       []string{"iot.>", "telemetry.sensor", "telemetry.sensor.>"},
+			wantSub:       []string{"_INBOX.>", "_INBOX_iot_sensor.>", "iot.>", "commands.*.reboot", "shared.status", "$MQTT.sub.>", "$MQTT.JSA.>"},
+			wantConnTypes: []string{"STANDARD", "MQTT"},
+		},
+		{
+			name: "Explicit connection types kept",
+			annotations: map[string]string{
+				"nats.io/mqtt":                     "true",
+				"nats.io/allowed-connection-types": "MQTT_WS",
+			},
+			wantPub:       []string{"iot.>"},
+			wantSub:       []string{"_INBOX.>", "_INBOX_iot_sensor.>", "iot.>", "$MQTT.sub.>", "$MQTT.JSA.>"},
+			wantConnTypes: []string{"MQTT_WS"},
+		},
+		{
+			name: "MQTT syntax kept as a literal NATS subject outside MQTT mode",
+			annotations: map[string]string{
+				"nats.io/allowed-pub-subjects": "telemetry/+/temp",
+			},
+			wantPub:       []string{"iot.>", "telemetry/+/temp"},
+			wantSub:       []string{"_INBOX.>", "_INBOX_iot_sensor.>", "iot.>"},
+			wantConnTypes: []string{"STANDARD"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cache := NewCache(zap.NewNop())
+			cache.upsert(&corev1.ServiceAccount{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:        "sensor",
+					Namespace:   "iot",
+					Annotations: tt.annotations,
+				},
+			})
+
+			perms, found := cache.Lookup("iot", "sensor")
+			if !found {
+				t.Fatal("Expected ServiceAccount to be in cache after upsert")
+			}
+
+			if !equalStringSlices(perms.Publish, tt.wantPub) {
+				t.Errorf("Publish = %v, want %v", perms.Publish, tt.wantPub)
+			}
+			if !equalStringSlices(perms.Subscribe, tt.wantSub) {
+				t.Errorf("Subscribe = %v, want %v", perms.Subscribe, tt.wantSub)
+			}
+			if !equalStringSlices(perms.ConnectionTypes, tt.wantConnTypes) {
+				t.Errorf("ConnectionTypes = %v, want %v", perms.ConnectionTypes, tt.wantConnTypes)
+			}
+		})
+	}
+}
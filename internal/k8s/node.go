@@ -0,0 +1,68 @@
+package k8s
+
+import (
+	"fmt"
+	"strings"
+
+	"go.uber.org/zap"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+)
+
+// AnnotationAllowedNodeSelector restricts a ServiceAccount to pods scheduled on nodes matching a
+// label selector (e.g. "node-role.example.com/pci=true").
+const AnnotationAllowedNodeSelector = "nats.io/allowed-node-selector"
+
+// ParseNodeSelector parses a node label selector. Empty selectors are rejected so a blank
+// annotation cannot silently allow every node.
+func ParseNodeSelector(value string) (labels.Selector, error) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return nil, fmt.Errorf("node selector is empty")
+	}
+	return labels.Parse(value)
+}
+
+// buildNodeSelector resolves the node selector for a ServiceAccount.
+// An invalid annotation matches no nodes so the restriction fails closed.
+func buildNodeSelector(sa *corev1.ServiceAccount, logger *zap.Logger) labels.Selector {
+	value, ok := sa.Annotations[AnnotationAllowedNodeSelector]
+	if !ok {
+		return nil
+	}
+
+	selector, err := ParseNodeSelector(value)
+	if err != nil {
+		logInvalidAnnotation(sa, AnnotationAllowedNodeSelector, value, err, logger)
+		return labels.Nothing()
+	}
+
+	return selector
+}
+
+// NodeAllowed reports whether a node's labels satisfy the ServiceAccount's node selector.
+// Always true when the ServiceAccount has no node selector.
+func (p *Permissions) NodeAllowed(nodeLabels map[string]string) bool {
+	if p.NodeSelector == nil {
+		return true
+	}
+	return p.NodeSelector.Matches(labels.Set(nodeLabels))
+}
+
+// trimNode drops the node fields that node selectors do not use before the node is cached
+func trimNode(obj interface{}) (interface{}, error) {
+	node, ok := obj.(*corev1.Node)
+	if !ok {
+		return obj, nil
+	}
+
+	return &corev1.Node{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:            node.Name,
+			UID:             node.UID,
+			ResourceVersion: node.ResourceVersion,
+			Labels:          node.Labels,
+		},
+	}, nil
+}
@@ -0,0 +1,84 @@
+package k8s
+
+import (
+	"testing"
+
+	"go.uber.org/zap"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+// TestCache_NodeSelector tests parsing the allowed node selector annotation
+func TestCache_NodeSelector(t *testing.T) {
+	tests := []struct {
+		name        string
+		annotations map[string]string
+		nodeLabels  map[string]string
+		wantNil     bool
+		wantMatch   bool
+	}{
+		{name: "No annotation", wantNil: true},
+		{name: "Matching node", annotations: map[string]string{AnnotationAllowedNodeSelector: "pci=true,zone in (a,b)"}, nodeLabels: map[string]string{"pci": "true", "zone": "a"}, wantMatch: true},
+		{name: "Non-matching node", annotations: map[string]string{AnnotationAllowedNodeSelector: "pci=true"}, nodeLabels: map[string]string{"pci": "false"}, wantMatch: false},
+		{name: "Empty selector fails closed", annotations: map[string]string{AnnotationAllowedNodeSelector: " "}, nodeLabels: map[string]string{"pci": "true"}, wantMatch: false},
+		{name: "Invalid selector fails closed", annotations: map[string]string{AnnotationAllowedNodeSelector: "pci in (true"}, nodeLabels: map[string]string{"pci": "true"}, wantMatch: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cache := NewCache(zap.NewNop())
+			cache.upsert(&corev1.ServiceAccount{ObjectMeta: metav1.ObjectMeta{
+				Name:        "processor",
+				Namespace:   "payments",
+				Annotations: tt.annotations,
+			}})
+
+			perms, found := cache.Lookup("payments", "processor")
+			if !found {
+				t.Fatal("Expected ServiceAccount to be cached")
+			}
+			if tt.wantNil {
+				if perms.NodeSelector != nil {
+					t.Errorf("NodeSelector = %v, want nil", perms.NodeSelector)
+				}
+				return
+			}
+			if got := perms.NodeAllowed(tt.nodeLabels); got != tt.wantMatch {
+				t.Errorf("NodeAllowed(%v) = %v, want %v", tt.nodeLabels, got, tt.wantMatch)
+			}
+		})
+	}
+}
+
+// TestClient_NodeLabels tests looking up node labels through the node informer
+func TestClient_NodeLabels(t *testing.T) {
+	fakeClient := fake.NewSimpleClientset(&corev1.Node{ObjectMeta: metav1.ObjectMeta{
+		Name:   "pci-node",
+		Labels: map[string]string{"pci": "true"},
+	}})
+	factory := informers.NewSharedInformerFactory(fakeClient, 0)
+	client := NewClient(factory, zap.NewNop())
+
+	if _, found := client.NodeLabels("pci-node"); found {
+		t.Error("Expected no node labels before WatchNodes is called")
+	}
+
+	if err := client.WatchNodes(factory); err != nil {
+		t.Fatalf("WatchNodes() error = %v", err)
+	}
+
+	stopCh := make(chan struct{})
+	defer close(stopCh)
+	factory.Start(stopCh)
+	factory.WaitForCacheSync(stopCh)
+
+	nodeLabels, found := client.NodeLabels("pci-node")
+	if !found || nodeLabels["pci"] != "true" {
+		t.Errorf("NodeLabels(pci-node) = %v, %v; want pci=true", nodeLabels, found)
+	}
+	if _, found := client.NodeLabels("missing-node"); found {
+		t.Error("Expected unknown node to be reported as not found")
+	}
+}
@@ -0,0 +1,90 @@
+package k8s
+
+import (
+	"net/netip"
+	"slices"
+	"time"
+)
+
+// Options configures how the cache builds permissions for ServiceAccounts.
+type Options struct {
+	// PublishTemplates are the default publish subjects, which may contain placeholders.
+	PublishTemplates []string
+	// SubscribeTemplates are the default subscribe subjects, which may contain placeholders.
+	SubscribeTemplates []string
+	// Cluster is the value substituted for {{.Cluster}}.
+	Cluster string
+	// StrictInbox omits the shared _INBOX.> subscription and grants only the private inbox.
+	// ServiceAccounts can override this with the nats.io/strict-inbox annotation.
+	StrictInbox bool
+	// DisableResponses turns off response permissions (allow_responses) by default.
+	DisableResponses bool
+	// ResponseMaxMsgs is the default number of responses allowed per request; -1 means unlimited.
+	// Zero uses DefaultResponseMaxMsgs.
+	ResponseMaxMsgs int
+	// ResponseTTL is the default time a responder may reply; 0 uses the NATS server default.
+	ResponseTTL time.Duration
+	// SourceCIDRs restricts every client to these networks (e.g. the pod CIDR ranges).
+	// Empty allows any source. The nats.io/allowed-source-cidrs annotation can only narrow it.
+	SourceCIDRs []netip.Prefix
+	// ConnectionTypes are the NATS connection types allowed by default (e.g. STANDARD, WEBSOCKET).
+	// Empty uses DefaultConnectionTypes. The nats.io/allowed-connection-types annotation replaces it.
+	ConnectionTypes []string
+	// JetStreamDomain is the JetStream domain used in API subjects expanded from the
+	// nats.io/jetstream-* annotations. Empty uses the local $JS.API prefix.
+	JetStreamDomain string
+	// PodPermissions is the default pod permissions mode (PodPermissionsOff, PodPermissionsMerge or
+	// PodPermissionsNarrow). Empty means off. Only applies when pods are watched.
+	PodPermissions string
+	// Registry restricts which annotation subjects a ServiceAccount may be granted.
+	// Nil disables registry checks. Replaced at runtime by Cache.SetSubjectRegistry.
+	Registry *SubjectRegistry
+	// Shadow evaluates the subject registry and global strict inbox mode as a candidate policy in
+	// every namespace without enforcing them; ShadowNamespaces does so only in the listed namespaces.
+	// Shadowed ServiceAccounts are issued permissions built without these rules, and
+	// Permissions.Candidate holds the permissions they would be issued once enforced.
+	Shadow           bool
+	ShadowNamespaces []string
+}
+
+// DefaultOptions returns the options matching the built-in permission model.
+func DefaultOptions() Options {
+	return Options{
+		PublishTemplates:   DefaultPublishTemplates,
+		SubscribeTemplates: DefaultSubscribeTemplates,
+		ResponseMaxMsgs:    DefaultResponseMaxMsgs,
+		ConnectionTypes:    DefaultConnectionTypes,
+	}
+}
+
+// shadowed reports whether the candidate policy is evaluated without being enforced in a namespace.
+func (o Options) shadowed(namespace string) bool {
+	return o.Shadow || slices.Contains(o.ShadowNamespaces, namespace)
+}
+
+// withoutCandidatePolicy returns the options with the rules evaluated in shadow mode turned off.
+func (o Options) withoutCandidatePolicy() Options {
+	o.Registry = nil
+	o.StrictInbox = false
+	return o
+}
+
+// withDefaults fills in any unset template lists and limits with the built-in defaults.
+func (o Options) withDefaults() Options {
+	if len(o.PublishTemplates) == 0 {
+		o.PublishTemplates = DefaultPublishTemplates
+	}
+	if len(o.SubscribeTemplates) == 0 {
+		o.SubscribeTemplates = DefaultSubscribeTemplates
+	}
+	if o.ResponseMaxMsgs == 0 {
+		o.ResponseMaxMsgs = DefaultResponseMaxMsgs
+	}
+	if len(o.ConnectionTypes) == 0 {
+		o.ConnectionTypes = DefaultConnectionTypes
+	}
+	if o.PodPermissions == "" {
+		o.PodPermissions = PodPermissionsOff
+	}
+	return o
+}
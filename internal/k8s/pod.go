@@ -0,0 +1,255 @@
+package k8s
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"go.uber.org/zap"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"sigs.k8s.io/yaml"
+)
+
+const (
+	// AnnotationPodPermissions overrides the global pod permissions mode for a ServiceAccount
+	// ("merge", "narrow" or "off").
+	AnnotationPodPermissions = "nats.io/pod-permissions"
+	// AnnotationPodRules holds a YAML or JSON list of rules granting extra subjects to the
+	// ServiceAccount's pods by label selector or owning workload.
+	AnnotationPodRules = "nats.io/pod-rules"
+
+	// Pod permissions modes: how subject annotations on a pod change its ServiceAccount's permissions
+	PodPermissionsOff    = "off"
+	PodPermissionsMerge  = "merge"
+	PodPermissionsNarrow = "narrow"
+
+	// podTemplateHashLabel is set by the Deployment controller on pods and their ReplicaSet
+	podTemplateHashLabel = "pod-template-hash"
+)
+
+// ValidPodPermissionsMode reports whether a value is a pod permissions mode.
+func ValidPodPermissionsMode(mode string) bool {
+	switch mode {
+	case PodPermissionsOff, PodPermissionsMerge, PodPermissionsNarrow:
+		return true
+	default:
+		return false
+	}
+}
+
+// PodRule grants extra subjects to pods of a ServiceAccount that match a label selector and/or
+// belong to a workload.
+//
+// Example annotation value:
+//
+//   - selector: app=gateway
+//     publish: ["ingress.>"]
+//   - workload: Deployment/billing-worker
+//     subscribe: ["billing.jobs.>"]
+type PodRule struct {
+	// Selector is a label selector such as "app=gateway,tier!=canary".
+	Selector string `json:"selector,omitempty"`
+	// Workload is the pod's owning workload as "Kind/name", e.g. "Deployment/gateway".
+	Workload  string   `json:"workload,omitempty"`
+	Publish   []string `json:"publish,omitempty"`
+	Subscribe []string `json:"subscribe,omitempty"`
+
+	selector labels.Selector
+}
+
+// ParsePodRules strictly parses a YAML or JSON list of pod rules.
+// Every rule needs a selector or a workload so it cannot silently apply to all pods.
+func ParsePodRules(data []byte) ([]PodRule, error) {
+	var rules []PodRule
+	if err := yaml.UnmarshalStrict(data, &rules); err != nil {
+		return nil, fmt.Errorf("failed to parse pod rules: %w", err)
+	}
+
+	for i := range rules {
+		rule := &rules[i]
+		if rule.Selector == "" && rule.Workload == "" {
+			return nil, fmt.Errorf("pod rule %d: selector or workload is required", i)
+		}
+		if rule.Workload != "" {
+			if kind, name, ok := strings.Cut(rule.Workload, "/"); !ok || kind == "" || name == "" {
+				return nil, fmt.Errorf("pod rule %d: workload must be in Kind/name form, got %q", i, rule.Workload)
+			}
+		}
+		selector, err := labels.Parse(rule.Selector)
+		if err != nil {
+			return nil, fmt.Errorf("pod rule %d: invalid selector: %w", i, err)
+		}
+		rule.selector = selector
+	}
+
+	return rules, nil
+}
+
+// matches reports whether a pod satisfies the rule's selector and workload.
+func (r PodRule) matches(pod *corev1.Pod) bool {
+	if r.Workload != "" && r.Workload != PodWorkload(pod) {
+		return false
+	}
+	return r.selector == nil || r.selector.Matches(labels.Set(pod.Labels))
+}
+
+// PodWorkload returns the workload that owns a pod as "Kind/name", or "" for bare pods.
+// Pods created through a Deployment are attributed to the Deployment rather than its ReplicaSet,
+// using the pod-template-hash suffix the Deployment controller adds to ReplicaSet names.
+func PodWorkload(pod *corev1.Pod) string {
+	owner := metav1.GetControllerOfNoCopy(pod)
+	if owner == nil {
+		return ""
+	}
+
+	if owner.Kind == "ReplicaSet" {
+		if hash := pod.Labels[podTemplateHashLabel]; hash != "" {
+			if deployment, ok := strings.CutSuffix(owner.Name, "-"+hash); ok {
+				return "Deployment/" + deployment
+			}
+		}
+	}
+
+	return owner.Kind + "/" + owner.Name
+}
+
+// podPermissionsMode resolves the pod permissions mode for a ServiceAccount.
+// The annotation takes precedence over the global mode.
+func podPermissionsMode(sa *corev1.ServiceAccount, global string, logger *zap.Logger) string {
+	value, ok := sa.Annotations[AnnotationPodPermissions]
+	if !ok {
+		return global
+	}
+
+	mode := strings.ToLower(strings.TrimSpace(value))
+	if !ValidPodPermissionsMode(mode) {
+		logInvalidAnnotation(sa, AnnotationPodPermissions, value, fmt.Errorf("expected merge, narrow or off"), logger)
+		return global
+	}
+
+	return mode
+}
+
+// buildPodRules parses the ServiceAccount's pod rules and renders their subjects, dropping
+// invalid subjects and subjects denied by the registry. An invalid annotation is ignored.
+func buildPodRules(sa *corev1.ServiceAccount, registry *SubjectRegistry, data templateData, logger *zap.Logger) []PodRule {
+	value, ok := sa.Annotations[AnnotationPodRules]
+	if !ok {
+		return nil
+	}
+
+	rules, err := ParsePodRules([]byte(value))
+	if err != nil {
+		logInvalidAnnotation(sa, AnnotationPodRules, value, err, logger)
+		return nil
+	}
+
+	for i := range rules {
+		publish := renderAnnotationSubjects(sa, AnnotationPodRules, rules[i].Publish, data, false, logger)
+		subscribe := renderAnnotationSubjects(sa, AnnotationPodRules, rules[i].Subscribe, data, false, logger)
+		rules[i].Publish = enforceRegistry(sa, registry, AnnotationPodRules, publish, logger)
+		rules[i].Subscribe = enforceRegistry(sa, registry, AnnotationPodRules, subscribe, logger)
+	}
+
+	return rules
+}
+
+// podPermissions refines a ServiceAccount's permissions for one of its pods.
+// Matching pod rules add subjects first; then, depending on the mode, the pod's own subject
+// annotations are either added (merge) or used to narrow the permissions (narrow).
+// The returned Permissions are a copy and never share subject lists with the cache.
+// In shadow mode the candidate permissions are refined the same way, without logging or counting.
+func (c *Cache) podPermissions(perms *Permissions, pod *corev1.Pod, logger *zap.Logger) *Permissions {
+	refined := *perms
+	refined.Publish = slices.Clone(perms.Publish)
+	refined.Subscribe = slices.Clone(perms.Subscribe)
+
+	for _, rule := range perms.PodRules {
+		if rule.matches(pod) {
+			refined.Publish = append(refined.Publish, rule.Publish...)
+			refined.Subscribe = append(refined.Subscribe, rule.Subscribe...)
+		}
+	}
+
+	c.mu.RLock()
+	opts := c.opts
+	c.mu.RUnlock()
+
+	// Shadowed permissions are issued without the candidate policy, which only the candidate enforces
+	if perms.Candidate != nil {
+		opts = opts.withoutCandidatePolicy()
+		refined.Candidate = c.podPermissions(perms.Candidate, pod, candidateLogger)
+	}
+
+	// Pod annotations are parsed like ServiceAccount annotations and logged against the pod's ServiceAccount
+	podAnnotated := &corev1.ServiceAccount{ObjectMeta: metav1.ObjectMeta{
+		Namespace:   pod.Namespace,
+		Name:        pod.Spec.ServiceAccountName,
+		Annotations: pod.Annotations,
+	}}
+	data := templateData{Namespace: pod.Namespace, ServiceAccount: pod.Spec.ServiceAccountName, Cluster: opts.Cluster}
+	podSubjects := func(annotation string) []string {
+		subjects := annotationSubjects(podAnnotated, annotation, data, false, logger)
+		return enforceRegistry(podAnnotated, opts.Registry, annotation, subjects, logger)
+	}
+
+	switch perms.PodMode {
+	case PodPermissionsMerge:
+		refined.Publish = append(refined.Publish, podSubjects(AnnotationAllowedPubSubjects)...)
+		refined.Subscribe = append(refined.Subscribe, podSubjects(AnnotationAllowedSubSubjects)...)
+	case PodPermissionsNarrow:
+		if _, ok := pod.Annotations[AnnotationAllowedPubSubjects]; ok {
+			refined.Publish = narrowSubjects(refined.Publish, podSubjects(AnnotationAllowedPubSubjects))
+		}
+		if _, ok := pod.Annotations[AnnotationAllowedSubSubjects]; ok {
+			refined.Subscribe = narrowSubjects(refined.Subscribe, podSubjects(AnnotationAllowedSubSubjects))
+		}
+	}
+
+	refined.Publish = normalizePermissionList(podAnnotated, "publish", refined.Publish, logger)
+	refined.Subscribe = normalizePermissionList(podAnnotated, "subscribe", refined.Subscribe, logger)
+
+	return &refined
+}
+
+// unrefinedPodPermissions returns the permissions for a token whose pod cannot be refined.
+// Merge mode only adds to the ServiceAccount's permissions, so they are returned unchanged.
+// Narrow mode cannot tell what the pod would have been narrowed to, so only inbox subjects are kept.
+func unrefinedPodPermissions(perms *Permissions) *Permissions {
+	if perms.PodMode != PodPermissionsNarrow {
+		return perms
+	}
+
+	narrowed := *perms
+	narrowed.Publish = narrowSubjects(perms.Publish, nil)
+	narrowed.Subscribe = narrowSubjects(perms.Subscribe, nil)
+	if perms.Candidate != nil {
+		narrowed.Candidate = unrefinedPodPermissions(perms.Candidate)
+	}
+
+	return &narrowed
+}
+
+// narrowSubjects keeps the inbox subscriptions and the requested subjects that are covered by
+// an allowed subject. Requested subjects outside the allowed list are dropped.
+func narrowSubjects(allowed, requested []string) []string {
+	narrowed := make([]string, 0, len(requested))
+	for _, subject := range allowed {
+		if strings.HasPrefix(subject, "_INBOX") {
+			narrowed = append(narrowed, subject)
+		}
+	}
+
+	for _, subject := range requested {
+		for _, broader := range allowed {
+			if subjectCovers(broader, subject) {
+				narrowed = append(narrowed, subject)
+				break
+			}
+		}
+	}
+
+	return narrowed
+}
@@ -0,0 +1,249 @@
+package k8s
+
+import (
+	"testing"
+
+	"go.uber.org/zap"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+// TestParsePodRules tests strict parsing of pod rules
+func TestParsePodRules(t *testing.T) {
+	tests := []struct {
+		name    string
+		data    string
+		wantLen int
+		wantErr bool
+	}{
+		{name: "Selector rule", data: "- selector: app=gateway\n  publish: [\"ingress.>\"]", wantLen: 1},
+		{name: "Workload rule", data: `[{"workload": "Deployment/gateway", "subscribe": ["ingress.>"]}]`, wantLen: 1},
+		{name: "Set-based selector", data: "- selector: \"app in (gateway, edge), tier!=canary\"", wantLen: 1},
+		{name: "Missing selector and workload", data: "- publish: [\"ingress.>\"]", wantErr: true},
+		{name: "Invalid selector", data: "- selector: \"app in (gateway\"", wantErr: true},
+		{name: "Invalid workload", data: "- workload: gateway", wantErr: true},
+		{name: "Unknown field", data: "- selector: app=gateway\n  pub: [\"ingress.>\"]", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rules, err := ParsePodRules([]byte(tt.data))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParsePodRules() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if len(rules) != tt.wantLen {
+				t.Errorf("ParsePodRules() returned %d rules, want %d", len(rules), tt.wantLen)
+			}
+		})
+	}
+}
+
+// TestPodWorkload tests resolving a pod's owning workload from its ownerReferences
+func TestPodWorkload(t *testing.T) {
+	controller := true
+	tests := []struct {
+		name   string
+		owners []metav1.OwnerReference
+		labels map[string]string
+		want   string
+	}{
+		{
+			name:   "Deployment through ReplicaSet",
+			owners: []metav1.OwnerReference{{Kind: "ReplicaSet", Name: "gateway-7d4b9c", Controller: &controller}},
+			labels: map[string]string{"pod-template-hash": "7d4b9c"},
+			want:   "Deployment/gateway",
+		},
+		{
+			name:   "Bare ReplicaSet",
+			owners: []metav1.OwnerReference{{Kind: "ReplicaSet", Name: "gateway", Controller: &controller}},
+			want:   "ReplicaSet/gateway",
+		},
+		{
+			name:   "StatefulSet",
+			owners: []metav1.OwnerReference{{Kind: "StatefulSet", Name: "db", Controller: &controller}},
+			want:   "StatefulSet/db",
+		},
+		{
+			name:   "Non-controller owner ignored",
+			owners: []metav1.OwnerReference{{Kind: "StatefulSet", Name: "db"}},
+			want:   "",
+		},
+		{name: "Bare pod", want: ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pod := &corev1.Pod{ObjectMeta: metav1.ObjectMeta{OwnerReferences: tt.owners, Labels: tt.labels}}
+			if got := PodWorkload(pod); got != tt.want {
+				t.Errorf("PodWorkload() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+// TestClient_LookupPodPermissions tests refining ServiceAccount permissions with pod rules and pod annotations
+func TestClient_LookupPodPermissions(t *testing.T) {
+	controller := true
+	newPod := func(name, serviceAccount string, labels, annotations map[string]string, owners ...metav1.OwnerReference) *corev1.Pod {
+		return &corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:            name,
+				Namespace:       "shop",
+				UID:             types.UID(name + "-uid"),
+				Labels:          labels,
+				Annotations:     annotations,
+				OwnerReferences: owners,
+			},
+			Spec: corev1.PodSpec{ServiceAccountName: serviceAccount},
+		}
+	}
+
+	objects := []runtime.Object{
+		&corev1.ServiceAccount{ObjectMeta: metav1.ObjectMeta{
+			Name:      "api",
+			Namespace: "shop",
+			Annotations: map[string]string{
+				"nats.io/allowed-pub-subjects": "orders.>",
+				"nats.io/pod-rules":            "- selector: app=gateway\n  publish: [\"ingress.>\"]\n- workload: Deployment/worker\n  subscribe: [\"jobs.>\"]",
+			},
+		}},
+		&corev1.ServiceAccount{ObjectMeta: metav1.ObjectMeta{
+			Name:        "narrow",
+			Namespace:   "shop",
+			Annotations: map[string]string{"nats.io/pod-permissions": "narrow", "nats.io/allowed-pub-subjects": "orders.>"},
+		}},
+		newPod("gateway-1", "api", map[string]string{"app": "gateway"}, nil),
+		newPod("worker-5f7c-x1", "api", map[string]string{"pod-template-hash": "5f7c"}, nil,
+			metav1.OwnerReference{Kind: "ReplicaSet", Name: "worker-5f7c", Controller: &controller}),
+		newPod("merge-1", "api", nil, map[string]string{"nats.io/allowed-pub-subjects": "extra.{{.Pod}}"}),
+		newPod("other-sa", "narrow", nil, map[string]string{"nats.io/allowed-pub-subjects": "extra.>"}),
+		newPod("narrow-1", "narrow", nil, map[string]string{"nats.io/allowed-pub-subjects": "orders.eu.>, extra.>"}),
+	}
+
+	fakeClient := fake.NewSimpleClientset(objects...)
+	factory := informers.NewSharedInformerFactory(fakeClient, 0)
+	client := NewClientWithOptions(factory, zap.NewNop(), Options{PodPermissions: PodPermissionsMerge})
+	if err := client.WatchPods(factory); err != nil {
+		t.Fatalf("WatchPods() error = %v", err)
+	}
+
+	stopCh := make(chan struct{})
+	defer close(stopCh)
+	factory.Start(stopCh)
+	factory.WaitForCacheSync(stopCh)
+
+	tests := []struct {
+		name           string
+		serviceAccount string
+		pod            string
+		podUID         string
+		wantPub        []string
+		wantSub        []string
+	}{
+		{
+			name:           "Label rule",
+			serviceAccount: "api",
+			pod:            "gateway-1",
+			wantPub:        []string{"shop.>", "orders.>", "ingress.>"},
+			wantSub:        []string{"_INBOX.>", "_INBOX_shop_api.>", "shop.>"},
+		},
+		{
+			name:           "Workload rule",
+			serviceAccount: "api",
+			pod:            "worker-5f7c-x1",
+			wantPub:        []string{"shop.>", "orders.>"},
+			wantSub:        []string{"_INBOX.>", "_INBOX_shop_api.>", "shop.>", "jobs.>"},
+		},
+		{
+			name:           "Merged pod annotation",
+			serviceAccount: "api",
+			pod:            "merge-1",
+			wantPub:        []string{"shop.>", "orders.>", "extra.{{.Pod}}"},
+			wantSub:        []string{"_INBOX.>", "_INBOX_shop_api.>", "shop.>"},
+		},
+		{
+			name:           "Narrowed pod annotation",
+			serviceAccount: "narrow",
+			pod:            "narrow-1",
+			wantPub:        []string{"orders.eu.>"},
+			wantSub:        []string{"_INBOX.>", "_INBOX_shop_narrow.>", "shop.>"},
+		},
+		{
+			name:           "Pod of another ServiceAccount ignored",
+			serviceAccount: "api",
+			pod:            "other-sa",
+			wantPub:        []string{"shop.>", "orders.>"},
+			wantSub:        []string{"_INBOX.>", "_INBOX_shop_api.>", "shop.>"},
+		},
+		{
+			name:           "Unknown pod",
+			serviceAccount: "api",
+			pod:            "missing",
+			wantPub:        []string{"shop.>", "orders.>"},
+			wantSub:        []string{"_INBOX.>", "_INBOX_shop_api.>", "shop.>"},
+		},
+		{
+			name:           "Pod with another UID ignored",
+			serviceAccount: "api",
+			pod:            "gateway-1",
+			podUID:         "recreated-uid",
+			wantPub:        []string{"shop.>", "orders.>"},
+			wantSub:        []string{"_INBOX.>", "_INBOX_shop_api.>", "shop.>"},
+		},
+		{
+			name:           "Unknown pod in narrow mode",
+			serviceAccount: "narrow",
+			pod:            "missing",
+			wantPub:        []string{},
+			wantSub:        []string{"_INBOX.>", "_INBOX_shop_narrow.>"},
+		},
+		{
+			name:           "Pod with another UID in narrow mode",
+			serviceAccount: "narrow",
+			pod:            "narrow-1",
+			podUID:         "recreated-uid",
+			wantPub:        []string{},
+			wantSub:        []string{"_INBOX.>", "_INBOX_shop_narrow.>"},
+		},
+		{
+			name:           "Pod of another ServiceAccount in narrow mode",
+			serviceAccount: "narrow",
+			pod:            "gateway-1",
+			wantPub:        []string{},
+			wantSub:        []string{"_INBOX.>", "_INBOX_shop_narrow.>"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			podUID := tt.podUID
+			if podUID == "" {
+				podUID = tt.pod + "-uid"
+			}
+			perms, found := client.LookupPodPermissions("shop", tt.serviceAccount, tt.pod, podUID)
+			if !found {
+				t.Fatal("Expected ServiceAccount to be found")
+			}
+			if !equalStringSlices(perms.Publish, tt.wantPub) {
+				t.Errorf("Publish = %v, want %v", perms.Publish, tt.wantPub)
+			}
+			if !equalStringSlices(perms.Subscribe, tt.wantSub) {
+				t.Errorf("Subscribe = %v, want %v", perms.Subscribe, tt.wantSub)
+			}
+		})
+	}
+
+	// The cached ServiceAccount permissions are never modified by pod refinements
+	perms, _ := client.LookupPermissions("shop", "api")
+	if want := []string{"shop.>", "orders.>"}; !equalStringSlices(perms.Publish, want) {
+		t.Errorf("cached Publish = %v, want %v", perms.Publish, want)
+	}
+
+	if _, found := client.LookupPodPermissions("shop", "missing", "gateway-1", "gateway-1-uid"); found {
+		t.Error("Expected unknown ServiceAccount not to be found")
+	}
+}
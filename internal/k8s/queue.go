@@ -0,0 +1,142 @@
+package k8s
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"go.uber.org/zap"
+	corev1 "k8s.io/api/core/v1"
+)
+
+// AnnotationAllowedSubQueues grants subscriptions that are only allowed within a queue group.
+// Entries are comma-separated "subject queue" pairs (e.g. "orders.> workers, audit.* auditors"),
+// or a JSON list of {"subject": ..., "queue": ...} objects.
+const AnnotationAllowedSubQueues = "nats.io/allowed-sub-queues"
+
+// QueueSubscription allows subscribing to a subject only as a member of a queue group
+type QueueSubscription struct {
+	Subject string `json:"subject"`
+	Queue   string `json:"queue"`
+}
+
+// String returns the NATS permission form "subject queue".
+func (q QueueSubscription) String() string {
+	return q.Subject + " " + q.Queue
+}
+
+// QueueSubscriptionStrings returns queue subscriptions in NATS permission form.
+func QueueSubscriptionStrings(queues []QueueSubscription) []string {
+	if len(queues) == 0 {
+		return nil
+	}
+	entries := make([]string, 0, len(queues))
+	for _, q := range queues {
+		entries = append(entries, q.String())
+	}
+	return entries
+}
+
+// ParseQueueSubscriptions parses a queue subscription annotation value in either the
+// "subject queue" list form or the JSON form. Unknown JSON fields are rejected.
+func ParseQueueSubscriptions(value string) ([]QueueSubscription, error) {
+	value = strings.TrimSpace(value)
+	if strings.HasPrefix(value, "[") {
+		var queues []QueueSubscription
+		decoder := json.NewDecoder(bytes.NewReader([]byte(value)))
+		decoder.DisallowUnknownFields()
+		if err := decoder.Decode(&queues); err != nil {
+			return nil, fmt.Errorf("invalid JSON queue subscriptions: %w", err)
+		}
+		for i := range queues {
+			queues[i].Subject = strings.TrimSpace(queues[i].Subject)
+			queues[i].Queue = strings.TrimSpace(queues[i].Queue)
+		}
+		return queues, nil
+	}
+
+	var queues []QueueSubscription
+	for _, entry := range splitAnnotationList(value) {
+		fields := strings.Fields(entry)
+		if len(fields) != 2 {
+			return nil, fmt.Errorf("entry %q must be \"subject queue\"", entry)
+		}
+		queues = append(queues, QueueSubscription{Subject: fields[0], Queue: fields[1]})
+	}
+	return queues, nil
+}
+
+// validateQueueSubscription checks the subject and queue group of a queue subscription.
+// Queue groups follow subject syntax since the server matches them like subjects.
+func validateQueueSubscription(q QueueSubscription) error {
+	if err := validateSubject(q.Subject); err != nil {
+		return err
+	}
+	if err := validateSubject(q.Queue); err != nil {
+		return fmt.Errorf("invalid queue group: %w", err)
+	}
+	return nil
+}
+
+// buildQueueSubscriptions parses, renders and validates the queue subscription annotation.
+// An annotation that cannot be parsed is ignored.
+func buildQueueSubscriptions(sa *corev1.ServiceAccount, registry *SubjectRegistry, data templateData, logger *zap.Logger) []QueueSubscription {
+	value, ok := sa.Annotations[AnnotationAllowedSubQueues]
+	if !ok {
+		return nil
+	}
+
+	parsed, err := ParseQueueSubscriptions(value)
+	if err != nil {
+		logInvalidAnnotation(sa, AnnotationAllowedSubQueues, value, err, logger)
+		return nil
+	}
+
+	return renderQueueSubscriptions(sa, AnnotationAllowedSubQueues, parsed, registry, data, logger)
+}
+
+// renderQueueSubscriptions renders placeholders in queue subscriptions and drops invalid entries
+// and subjects denied by the registry, logging each one against the source annotation.
+func renderQueueSubscriptions(sa *corev1.ServiceAccount, source string, parsed []QueueSubscription, registry *SubjectRegistry, data templateData, logger *zap.Logger) []QueueSubscription {
+	var queues []QueueSubscription
+	for _, q := range parsed {
+		rendered, invalid := renderSubjects([]string{q.Subject, q.Queue}, data)
+		if len(invalid) > 0 {
+			logInvalidAnnotation(sa, source, q.String(), fmt.Errorf("unknown placeholder"), logger)
+			continue
+		}
+		q = QueueSubscription{Subject: rendered[0], Queue: rendered[1]}
+
+		if strings.HasPrefix(q.Subject, "_INBOX") || strings.HasPrefix(q.Subject, "_REPLY") {
+			logInvalidAnnotation(sa, source, q.String(), fmt.Errorf("NATS internal subjects are managed automatically"), logger)
+			continue
+		}
+		if err := validateQueueSubscription(q); err != nil {
+			logInvalidAnnotation(sa, source, q.String(), err, logger)
+			continue
+		}
+		if len(enforceRegistry(sa, registry, source, []string{q.Subject}, logger)) == 0 {
+			continue
+		}
+		queues = append(queues, q)
+	}
+	return queues
+}
+
+// warnUncoveredQueues logs queue subscriptions that have no effect because their subject is
+// also granted without a queue group.
+func warnUncoveredQueues(sa *corev1.ServiceAccount, queues []QueueSubscription, subscribe []string, logger *zap.Logger) {
+	for _, q := range queues {
+		for _, subject := range subscribe {
+			if subjectCovers(subject, q.Subject) {
+				logger.Warn("Queue group restriction has no effect because the subject is also granted without a queue",
+					zap.String("namespace", sa.Namespace),
+					zap.String("serviceaccount", sa.Name),
+					zap.String("queue_subscription", q.String()),
+					zap.String("covered_by", subject))
+				break
+			}
+		}
+	}
+}
@@ -0,0 +1,104 @@
+package k8s
+
+import (
+	"testing"
+
+	"go.uber.org/zap"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// TestParseQueueSubscriptions tests parsing the list and JSON queue subscription forms
+func TestParseQueueSubscriptions(t *testing.T) {
+	tests := []struct {
+		name    string
+		value   string
+		want    []string
+		wantErr bool
+	}{
+		{name: "Single entry", value: "orders.> workers", want: []string{"orders.> workers"}},
+		{name: "Multiple entries", value: "orders.> workers, audit.*  auditors", want: []string{"orders.> workers", "audit.* auditors"}},
+		{name: "JSON form", value: `[{"subject": "orders.>", "queue": "workers"}]`, want: []string{"orders.> workers"}},
+		{name: "Missing queue", value: "orders.>", wantErr: true},
+		{name: "Extra field", value: "orders.> workers extra", wantErr: true},
+		{name: "Unknown JSON field", value: `[{"subject": "orders.>", "group": "workers"}]`, wantErr: true},
+		{name: "Malformed JSON", value: `[{"subject": "orders.>"`, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseQueueSubscriptions(tt.value)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseQueueSubscriptions(%q) error = %v, wantErr %v", tt.value, err, tt.wantErr)
+			}
+			if !equalStringSlices(QueueSubscriptionStrings(got), tt.want) {
+				t.Errorf("ParseQueueSubscriptions(%q) = %v, want %v", tt.value, QueueSubscriptionStrings(got), tt.want)
+			}
+		})
+	}
+}
+
+// TestCache_QueueSubscriptions tests building queue subscriptions from ServiceAccount annotations
+func TestCache_QueueSubscriptions(t *testing.T) {
+	tests := []struct {
+		name       string
+		annotation string
+		want       []string
+	}{
+		{name: "List form", annotation: "jobs.> workers", want: []string{"jobs.> workers"}},
+		{name: "JSON form", annotation: `[{"subject": "jobs.{{.ServiceAccount}}", "queue": "{{.Namespace}}-workers"}]`, want: []string{"jobs.worker orders-workers"}},
+		{name: "Invalid entries skipped", annotation: `[{"subject": "jobs..>", "queue": "workers"}, {"subject": "jobs.>", "queue": "bad queue"}, {"subject": "_INBOX.>", "queue": "workers"}, {"subject": "tasks.>", "queue": "workers"}]`, want: []string{"tasks.> workers"}},
+		{name: "Unparseable annotation ignored", annotation: "jobs.>", want: nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cache := NewCache(zap.NewNop())
+			cache.upsert(&corev1.ServiceAccount{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:        "worker",
+					Namespace:   "orders",
+					Annotations: map[string]string{"nats.io/allowed-sub-queues": tt.annotation},
+				},
+			})
+
+			perms, found := cache.Lookup("orders", "worker")
+			if !found {
+				t.Fatal("Expected ServiceAccount to be in cache after upsert")
+			}
+
+			if got := QueueSubscriptionStrings(perms.SubscribeQueues); !equalStringSlices(got, tt.want) {
+				t.Errorf("SubscribeQueues = %v, want %v", got, tt.want)
+			}
+
+			// Queue subscriptions never widen the plain subscribe permissions
+			wantSub := []string{"_INBOX.>", "_INBOX_orders_worker.>", "orders.>"}
+			if !equalStringSlices(perms.Subscribe, wantSub) {
+				t.Errorf("Subscribe = %v, want %v", perms.Subscribe, wantSub)
+			}
+		})
+	}
+}
+
+// TestCache_QueueSubscriptionsRegistry tests that queue subscriptions follow prefix ownership rules
+func TestCache_QueueSubscriptionsRegistry(t *testing.T) {
+	registry, err := ParseSubjectRegistry([]byte("prefixes:\n  - prefix: payments\n    owner: payments\n"))
+	if err != nil {
+		t.Fatalf("Failed to parse registry: %v", err)
+	}
+
+	cache := NewCacheWithOptions(zap.NewNop(), Options{Registry: registry})
+	cache.upsert(&corev1.ServiceAccount{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        "worker",
+			Namespace:   "orders",
+			Annotations: map[string]string{"nats.io/allowed-sub-queues": "payments.> workers, jobs.> workers"},
+		},
+	})
+
+	perms, _ := cache.Lookup("orders", "worker")
+	want := []string{"jobs.> workers"}
+	if got := QueueSubscriptionStrings(perms.SubscribeQueues); !equalStringSlices(got, want) {
+		t.Errorf("SubscribeQueues = %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,269 @@
+package k8s
+
+import (
+	"fmt"
+	"strings"
+
+	"sigs.k8s.io/yaml"
+)
+
+const (
+	// DefaultSubjectRegistryKey is the ConfigMap key holding the subject registry document.
+	DefaultSubjectRegistryKey = "registry.yaml"
+
+	// registryAllowAll in a prefix allow list permits every namespace
+	registryAllowAll = "*"
+
+	// Reasons reported when the registry rejects an annotation subject
+	registryReasonFullWildcard  = "full_wildcard"
+	registryReasonLiteralTokens = "insufficient_literal_tokens"
+	registryReasonForeignPrefix = "foreign_prefix"
+	registryReasonForeignStream = "foreign_stream"
+	registryReasonUnregistered  = "unregistered_stream"
+	registryReasonJetStreamAPI  = "jetstream_api"
+
+	// jetStreamSubjectPrefix is the root of the JetStream API, ack and flow control subjects
+	jetStreamSubjectPrefix = "$JS"
+)
+
+// bucketSubjectPrefixes maps the KV and Object Store subject roots to their backing stream prefixes
+var bucketSubjectPrefixes = []struct {
+	prefix string
+	stream string
+}{
+	{prefix: "$KV", stream: "KV_"},
+	{prefix: "$O", stream: "OBJ_"},
+}
+
+// SubjectRegistry maps subject prefixes to the namespaces that own them and sets
+// cluster-wide rules for wildcard subjects requested through annotations.
+//
+// Example document:
+//
+//	prefixes:
+//	  - prefix: orders
+//	    owner: orders-team
+//	    allow: ["billing", "reporting/exporter"]
+//	streams:
+//	  - stream: ORDERS
+//	    owner: orders-team
+//	    allow: ["reporting/exporter"]
+//	wildcards:
+//	  allowFullWildcard: false
+//	  minLiteralTokens: 1
+type SubjectRegistry struct {
+	Prefixes  []PrefixOwnership `json:"prefixes"`
+	Streams   []StreamOwnership `json:"streams"`
+	Wildcards WildcardRules     `json:"wildcards"`
+}
+
+// PrefixOwnership assigns a subject prefix to an owning namespace.
+type PrefixOwnership struct {
+	// Prefix is a literal subject prefix such as "orders" or "platform.billing".
+	Prefix string `json:"prefix"`
+	// Owner is the namespace that owns the prefix.
+	Owner string `json:"owner"`
+	// Allow lists other namespaces ("ns") or ServiceAccounts ("ns/name") the owner permits
+	// to use the prefix. "*" allows every namespace.
+	Allow []string `json:"allow,omitempty"`
+}
+
+// StreamOwnership assigns a JetStream stream to an owning namespace. The JetStream API subjects
+// granted by annotations name streams rather than the subjects they capture, so prefix ownership
+// cannot protect them: while a registry is in effect, only registered streams can be granted, raw
+// $KV.<bucket> and $O.<bucket> subjects must name a bucket whose stream can be granted, and raw $JS
+// subjects are rejected in favour of the JetStream annotations.
+type StreamOwnership struct {
+	// Stream is the stream name, e.g. "ORDERS" or "KV_config" for a Key-Value bucket.
+	Stream string `json:"stream"`
+	// Owner is the namespace that owns the stream.
+	Owner string `json:"owner"`
+	// Allow lists other namespaces ("ns") or ServiceAccounts ("ns/name") the owner permits
+	// to access the stream. "*" allows every namespace.
+	Allow []string `json:"allow,omitempty"`
+}
+
+// WildcardRules restricts wildcard subjects requested through annotations.
+type WildcardRules struct {
+	// AllowFullWildcard permits a bare ">" subject. Defaults to false.
+	AllowFullWildcard bool `json:"allowFullWildcard"`
+	// MinLiteralTokens is the number of literal tokens required before the first wildcard.
+	MinLiteralTokens int `json:"minLiteralTokens"`
+}
+
+// ParseSubjectRegistry strictly parses a YAML or JSON subject registry document.
+func ParseSubjectRegistry(data []byte) (*SubjectRegistry, error) {
+	registry := &SubjectRegistry{}
+	if err := yaml.UnmarshalStrict(data, registry); err != nil {
+		return nil, fmt.Errorf("failed to parse subject registry: %w", err)
+	}
+
+	for i, p := range registry.Prefixes {
+		if p.Prefix == "" || p.Owner == "" {
+			return nil, fmt.Errorf("subject registry prefix %d: prefix and owner are required", i)
+		}
+		if strings.ContainsAny(p.Prefix, "*>") {
+			return nil, fmt.Errorf("subject registry prefix %q: prefixes must be literal", p.Prefix)
+		}
+		if err := validateSubject(p.Prefix); err != nil {
+			return nil, fmt.Errorf("subject registry prefix %q: %w", p.Prefix, err)
+		}
+	}
+	for i, s := range registry.Streams {
+		if s.Stream == "" || s.Owner == "" {
+			return nil, fmt.Errorf("subject registry stream %d: stream and owner are required", i)
+		}
+		if err := validateJetStreamName(s.Stream); err != nil {
+			return nil, fmt.Errorf("subject registry stream %q: %w", s.Stream, err)
+		}
+	}
+	if registry.Wildcards.MinLiteralTokens < 0 {
+		return nil, fmt.Errorf("subject registry wildcards.minLiteralTokens must not be negative")
+	}
+
+	return registry, nil
+}
+
+// check reports whether a ServiceAccount may be granted an annotation subject.
+// Returns an empty reason when the subject is allowed.
+func (r *SubjectRegistry) check(namespace, name, subject string) string {
+	if r == nil {
+		return ""
+	}
+
+	if subject == ">" && !r.Wildcards.AllowFullWildcard {
+		return registryReasonFullWildcard
+	}
+
+	// {{.Pod}} is resolved at authorization time and may become any token, so it is checked as '*'
+	subject = strings.Join(registryTokens(subject), ".")
+
+	if literalPrefixTokens(subject) < r.Wildcards.MinLiteralTokens {
+		return registryReasonLiteralTokens
+	}
+
+	for _, p := range r.Prefixes {
+		if p.permits(namespace, name) {
+			continue
+		}
+		if subjectOverlapsPrefix(subject, p.Prefix) {
+			return registryReasonForeignPrefix
+		}
+	}
+
+	return r.checkStreamSubject(namespace, name, subject)
+}
+
+// checkStreamSubject checks a raw subject that could reach the JetStream API or a bucket against
+// stream ownership. Returns an empty reason when the subject is allowed.
+func (r *SubjectRegistry) checkStreamSubject(namespace, name, subject string) string {
+	if subjectOverlapsPrefix(subject, jetStreamSubjectPrefix) {
+		return registryReasonJetStreamAPI
+	}
+
+	tokens := strings.Split(subject, ".")
+	for _, b := range bucketSubjectPrefixes {
+		if !subjectOverlapsPrefix(subject, b.prefix) {
+			continue
+		}
+		if tokens[0] == ">" || len(tokens) > 1 && (tokens[1] == "*" || tokens[1] == ">") {
+			// A wildcard bucket reaches unregistered buckets
+			return registryReasonUnregistered
+		}
+		if len(tokens) == 1 {
+			// The bare root names no bucket
+			continue
+		}
+		if reason := r.checkStream(namespace, name, b.stream+tokens[1]); reason != "" {
+			return reason
+		}
+	}
+
+	return ""
+}
+
+// checkStream reports whether a ServiceAccount may be granted JetStream API access to a stream.
+// Returns an empty reason when the stream is allowed.
+func (r *SubjectRegistry) checkStream(namespace, name, stream string) string {
+	if r == nil {
+		return ""
+	}
+
+	for _, s := range r.Streams {
+		if s.Stream != stream {
+			continue
+		}
+		if ownershipPermits(s.Owner, s.Allow, namespace, name) {
+			return ""
+		}
+		return registryReasonForeignStream
+	}
+
+	return registryReasonUnregistered
+}
+
+// permits reports whether the namespace or ServiceAccount may use the prefix.
+func (p PrefixOwnership) permits(namespace, name string) bool {
+	return ownershipPermits(p.Owner, p.Allow, namespace, name)
+}
+
+// ownershipPermits reports whether the namespace or ServiceAccount is the owner or in its allow list.
+func ownershipPermits(owner string, allow []string, namespace, name string) bool {
+	if owner == namespace {
+		return true
+	}
+
+	for _, allowed := range allow {
+		if allowed == registryAllowAll || allowed == namespace || allowed == makeKey(namespace, name) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// registryTokens splits a subject into tokens, replacing every token containing {{.Pod}}
+// with '*'. The placeholder contains a dot, so it is swapped out before splitting.
+func registryTokens(subject string) []string {
+	const podMarker = "\x00"
+	tokens := strings.Split(strings.ReplaceAll(subject, PlaceholderPod, podMarker), ".")
+	for i, token := range tokens {
+		if strings.Contains(token, podMarker) {
+			tokens[i] = "*"
+		}
+	}
+	return tokens
+}
+
+// literalPrefixTokens counts the literal tokens before the first wildcard in a subject.
+func literalPrefixTokens(subject string) int {
+	count := 0
+	for _, token := range strings.Split(subject, ".") {
+		if token == "*" || token == ">" {
+			break
+		}
+		count++
+	}
+	return count
+}
+
+// subjectOverlapsPrefix reports whether a subject could match any subject at or below a literal prefix.
+func subjectOverlapsPrefix(subject, prefix string) bool {
+	s := strings.Split(subject, ".")
+	p := strings.Split(prefix, ".")
+
+	for i, token := range p {
+		if i >= len(s) {
+			// Subject is shorter than the prefix, so it cannot reach it
+			return false
+		}
+		if s[i] == ">" {
+			return true
+		}
+		if s[i] != "*" && s[i] != token {
+			return false
+		}
+	}
+
+	return true
+}
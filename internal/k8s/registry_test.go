@@ -0,0 +1,245 @@
+package k8s
+
+import (
+	"context"
+	"slices"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+const testRegistry = `
+prefixes:
+  - prefix: orders
+    owner: orders-team
+    allow: ["billing", "reporting/exporter"]
+  - prefix: platform.audit
+    owner: platform
+wildcards:
+  allowFullWildcard: false
+  minLiteralTokens: 1
+`
+
+// TestParseSubjectRegistry tests strict parsing of registry documents
+func TestParseSubjectRegistry(t *testing.T) {
+	tests := []struct {
+		name    string
+		doc     string
+		wantErr bool
+	}{
+		{name: "Valid YAML", doc: testRegistry, wantErr: false},
+		{name: "Valid JSON", doc: `{"prefixes":[{"prefix":"orders","owner":"orders-team"}]}`, wantErr: false},
+		{name: "Empty document", doc: "", wantErr: false},
+		{name: "Unknown field", doc: "prefixes: []\nunknown: true", wantErr: true},
+		{name: "Missing owner", doc: "prefixes:\n  - prefix: orders", wantErr: true},
+		{name: "Wildcard prefix", doc: "prefixes:\n  - prefix: orders.>\n    owner: a", wantErr: true},
+		{name: "Malformed prefix", doc: "prefixes:\n  - prefix: orders..eu\n    owner: a", wantErr: true},
+		{name: "Negative literal tokens", doc: "wildcards:\n  minLiteralTokens: -1", wantErr: true},
+		{name: "Stream missing owner", doc: "streams:\n  - stream: ORDERS", wantErr: true},
+		{name: "Invalid stream name", doc: "streams:\n  - stream: ORDERS.>\n    owner: a", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ParseSubjectRegistry([]byte(tt.doc))
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ParseSubjectRegistry() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+// TestSubjectRegistry_Check tests prefix ownership and wildcard rules
+func TestSubjectRegistry_Check(t *testing.T) {
+	registry, err := ParseSubjectRegistry([]byte(testRegistry))
+	if err != nil {
+		t.Fatalf("Failed to parse registry: %v", err)
+	}
+
+	tests := []struct {
+		name       string
+		namespace  string
+		saName     string
+		subject    string
+		wantReason string
+	}{
+		{name: "Owner uses own prefix", namespace: "orders-team", saName: "api", subject: "orders.>", wantReason: ""},
+		{name: "Allowed namespace", namespace: "billing", saName: "api", subject: "orders.created", wantReason: ""},
+		{name: "Allowed ServiceAccount", namespace: "reporting", saName: "exporter", subject: "orders.*", wantReason: ""},
+		{name: "Other ServiceAccount in allowed SA's namespace", namespace: "reporting", saName: "other", subject: "orders.*", wantReason: registryReasonForeignPrefix},
+		{name: "Foreign prefix", namespace: "shop", saName: "api", subject: "orders.>", wantReason: registryReasonForeignPrefix},
+		{name: "Wildcard overlapping foreign prefix", namespace: "shop", saName: "api", subject: "platform.*.>", wantReason: registryReasonForeignPrefix},
+		{name: "Sibling of foreign multi-token prefix", namespace: "shop", saName: "api", subject: "platform.metrics.>", wantReason: ""},
+		{name: "Shorter than foreign prefix", namespace: "shop", saName: "api", subject: "platform", wantReason: ""},
+		{name: "Unregistered prefix", namespace: "shop", saName: "api", subject: "shop-events.>", wantReason: ""},
+		{name: "Bare full wildcard", namespace: "orders-team", saName: "api", subject: ">", wantReason: registryReasonFullWildcard},
+		{name: "Leading token wildcard", namespace: "shop", saName: "api", subject: "*.created", wantReason: registryReasonLiteralTokens},
+		{name: "Leading pod placeholder", namespace: "shop", saName: "api", subject: "{{.Pod}}.>", wantReason: registryReasonLiteralTokens},
+		{name: "Pod placeholder inside token", namespace: "shop", saName: "api", subject: "ord{{.Pod}}.>", wantReason: registryReasonLiteralTokens},
+		{name: "Pod placeholder overlapping foreign prefix", namespace: "shop", saName: "api", subject: "platform.{{.Pod}}.>", wantReason: registryReasonForeignPrefix},
+		{name: "Pod placeholder under own prefix", namespace: "orders-team", saName: "api", subject: "orders.{{.Pod}}", wantReason: ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := registry.check(tt.namespace, tt.saName, tt.subject); got != tt.wantReason {
+				t.Errorf("check(%q) = %q, want %q", tt.subject, got, tt.wantReason)
+			}
+		})
+	}
+}
+
+// TestSubjectRegistry_CheckStream tests stream ownership rules
+func TestSubjectRegistry_CheckStream(t *testing.T) {
+	registry, err := ParseSubjectRegistry([]byte("streams:\n  - stream: ORDERS\n    owner: orders-team\n    allow: [\"reporting/exporter\"]"))
+	if err != nil {
+		t.Fatalf("Failed to parse registry: %v", err)
+	}
+
+	tests := []struct {
+		name       string
+		registry   *SubjectRegistry
+		namespace  string
+		saName     string
+		stream     string
+		wantReason string
+	}{
+		{name: "Owner", registry: registry, namespace: "orders-team", saName: "api", stream: "ORDERS", wantReason: ""},
+		{name: "Allowed ServiceAccount", registry: registry, namespace: "reporting", saName: "exporter", stream: "ORDERS", wantReason: ""},
+		{name: "Foreign stream", registry: registry, namespace: "shop", saName: "api", stream: "ORDERS", wantReason: registryReasonForeignStream},
+		{name: "Unregistered stream", registry: registry, namespace: "shop", saName: "api", stream: "SHOP", wantReason: registryReasonUnregistered},
+		{name: "No registry", registry: nil, namespace: "shop", saName: "api", stream: "ORDERS", wantReason: ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.registry.checkStream(tt.namespace, tt.saName, tt.stream); got != tt.wantReason {
+				t.Errorf("checkStream(%q) = %q, want %q", tt.stream, got, tt.wantReason)
+			}
+		})
+	}
+}
+
+// TestSubjectRegistry_CheckStreamSubjects tests that raw JetStream, KV and Object Store subjects
+// cannot bypass stream ownership
+func TestSubjectRegistry_CheckStreamSubjects(t *testing.T) {
+	registry, err := ParseSubjectRegistry([]byte(`
+streams:
+  - stream: ORDERS
+    owner: orders-team
+  - stream: KV_config
+    owner: shop
+  - stream: KV_sessions
+    owner: identity
+  - stream: OBJ_assets
+    owner: shop
+`))
+	if err != nil {
+		t.Fatalf("Failed to parse registry: %v", err)
+	}
+
+	tests := []struct {
+		name       string
+		subject    string
+		wantReason string
+	}{
+		{name: "Raw stream info on foreign stream", subject: "$JS.API.STREAM.INFO.ORDERS", wantReason: registryReasonJetStreamAPI},
+		{name: "Raw JetStream API in a domain", subject: "$JS.hub.API.>", wantReason: registryReasonJetStreamAPI},
+		{name: "Raw ack subject", subject: "$JS.ACK.ORDERS.worker.>", wantReason: registryReasonJetStreamAPI},
+		{name: "Wildcard reaching the JetStream API", subject: "*.API.>", wantReason: registryReasonJetStreamAPI},
+		{name: "Owned KV bucket", subject: "$KV.config.>", wantReason: ""},
+		{name: "Foreign KV bucket", subject: "$KV.sessions.>", wantReason: registryReasonForeignStream},
+		{name: "Unregistered KV bucket", subject: "$KV.cache.>", wantReason: registryReasonUnregistered},
+		{name: "Wildcard KV bucket", subject: "$KV.*.token", wantReason: registryReasonUnregistered},
+		{name: "Owned object bucket", subject: "$O.assets.>", wantReason: ""},
+		{name: "Unrelated subject", subject: "shop.events.>", wantReason: ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := registry.check("shop", "api", tt.subject); got != tt.wantReason {
+				t.Errorf("check(%q) = %q, want %q", tt.subject, got, tt.wantReason)
+			}
+		})
+	}
+}
+
+// TestCache_RegistryPodPlaceholder tests that {{.Pod}} cannot be used to reach a foreign prefix
+func TestCache_RegistryPodPlaceholder(t *testing.T) {
+	registry, err := ParseSubjectRegistry([]byte("prefixes:\n  - prefix: orders\n    owner: orders-team"))
+	if err != nil {
+		t.Fatalf("Failed to parse registry: %v", err)
+	}
+
+	opts := DefaultOptions()
+	opts.Registry = registry
+	cache := NewCacheWithOptions(zap.NewNop(), opts)
+	cache.upsert(&corev1.ServiceAccount{ObjectMeta: metav1.ObjectMeta{
+		Name:        "sa",
+		Namespace:   "evil",
+		Annotations: map[string]string{AnnotationAllowedSubSubjects: "{{.Pod}}.>, orders.>"},
+	}})
+
+	_, subPerms, _ := cache.Get("evil", "sa")
+	if granted := ExpandPodSubjects(subPerms, "orders"); slices.Contains(granted, "orders.>") {
+		t.Errorf("pod named orders was granted orders.>: %v", granted)
+	}
+}
+
+// TestClient_WatchSubjectRegistry tests that registry ConfigMap changes re-evaluate cached ServiceAccounts
+func TestClient_WatchSubjectRegistry(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	fakeClient := fake.NewSimpleClientset(&corev1.ServiceAccount{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "api",
+			Namespace: "shop",
+			Annotations: map[string]string{
+				"nats.io/allowed-pub-subjects": "orders.>, shop-events.>",
+			},
+		},
+	})
+
+	informerFactory := informers.NewSharedInformerFactory(fakeClient, 0)
+	client := NewClient(informerFactory, zap.NewNop())
+
+	registryFactory := informers.NewSharedInformerFactoryWithOptions(fakeClient, 0, informers.WithNamespace("nats-system"))
+	if err := client.WatchSubjectRegistry(registryFactory, "subject-registry", DefaultSubjectRegistryKey); err != nil {
+		t.Fatalf("WatchSubjectRegistry() error = %v", err)
+	}
+
+	stopCh := make(chan struct{})
+	defer close(stopCh)
+
+	informerFactory.Start(stopCh)
+	informerFactory.WaitForCacheSync(stopCh)
+	registryFactory.Start(stopCh)
+	registryFactory.WaitForCacheSync(stopCh)
+
+	pubPerms, _, _ := client.GetPermissions("shop", "api")
+	if !equalStringSlices(pubPerms, []string{"shop.>", "orders.>", "shop-events.>"}) {
+		t.Fatalf("pubPerms before registry = %v", pubPerms)
+	}
+
+	_, err := fakeClient.CoreV1().ConfigMaps("nats-system").Create(ctx, &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: "subject-registry", Namespace: "nats-system"},
+		Data:       map[string]string{DefaultSubjectRegistryKey: testRegistry},
+	}, metav1.CreateOptions{})
+	if err != nil {
+		t.Fatalf("Failed to create ConfigMap: %v", err)
+	}
+
+	// Give the informer time to process
+	time.Sleep(100 * time.Millisecond)
+
+	pubPerms, _, _ = client.GetPermissions("shop", "api")
+	if !equalStringSlices(pubPerms, []string{"shop.>", "shop-events.>"}) {
+		t.Errorf("pubPerms after registry = %v, want [shop.> shop-events.>]", pubPerms)
+	}
+}
@@ -0,0 +1,90 @@
+package k8s
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+	corev1 "k8s.io/api/core/v1"
+)
+
+const (
+	// AnnotationAllowResponses enables or disables response permissions for a ServiceAccount ("true" or "false").
+	AnnotationAllowResponses = "nats.io/allow-responses"
+	// AnnotationResponseMaxMsgs sets the number of responses allowed per request (-1 for unlimited).
+	AnnotationResponseMaxMsgs = "nats.io/response-max-msgs"
+	// AnnotationResponseTTL sets how long a responder may reply to a request (Go duration, e.g. "5s").
+	AnnotationResponseTTL = "nats.io/response-ttl"
+
+	// DefaultResponseMaxMsgs allows one response per request (NATS default)
+	DefaultResponseMaxMsgs = 1
+)
+
+// ResponsePermission allows a client to publish replies to the reply subjects of requests it receives
+// (equivalent to allow_responses in NATS server configuration).
+type ResponsePermission struct {
+	// MaxMsgs is the number of responses allowed per request; -1 means unlimited.
+	MaxMsgs int
+	// Expires is how long after the request a response is allowed; 0 uses the NATS server default.
+	Expires time.Duration
+}
+
+// buildResponsePermission resolves the response permission for a ServiceAccount from the global
+// defaults and its annotations. Returns nil when responses are disabled.
+// Invalid annotation values are logged and the global default is used instead.
+func buildResponsePermission(sa *corev1.ServiceAccount, opts Options, logger *zap.Logger) *ResponsePermission {
+	allow := !opts.DisableResponses
+	if value, ok := sa.Annotations[AnnotationAllowResponses]; ok {
+		parsed, err := strconv.ParseBool(strings.TrimSpace(value))
+		if err != nil {
+			logInvalidAnnotation(sa, AnnotationAllowResponses, value, err, logger)
+		} else {
+			allow = parsed
+		}
+	}
+	if !allow {
+		return nil
+	}
+
+	resp := &ResponsePermission{
+		MaxMsgs: opts.ResponseMaxMsgs,
+		Expires: opts.ResponseTTL,
+	}
+
+	if value, ok := sa.Annotations[AnnotationResponseMaxMsgs]; ok {
+		maxMsgs, err := parseResponseMaxMsgs(value)
+		if err != nil {
+			logInvalidAnnotation(sa, AnnotationResponseMaxMsgs, value, err, logger)
+		} else {
+			resp.MaxMsgs = maxMsgs
+		}
+	}
+
+	if value, ok := sa.Annotations[AnnotationResponseTTL]; ok {
+		ttl, err := time.ParseDuration(strings.TrimSpace(value))
+		switch {
+		case err != nil:
+			logInvalidAnnotation(sa, AnnotationResponseTTL, value, err, logger)
+		case ttl < 0:
+			logInvalidAnnotation(sa, AnnotationResponseTTL, value, fmt.Errorf("must not be negative"), logger)
+		default:
+			resp.Expires = ttl
+		}
+	}
+
+	return resp
+}
+
+// parseResponseMaxMsgs parses a response message limit: a positive integer or -1 for unlimited.
+func parseResponseMaxMsgs(value string) (int, error) {
+	maxMsgs, err := strconv.Atoi(strings.TrimSpace(value))
+	if err != nil {
+		return 0, err
+	}
+	if maxMsgs == 0 || maxMsgs < -1 {
+		return 0, fmt.Errorf("must be a positive integer or -1 for unlimited")
+	}
+	return maxMsgs, nil
+}
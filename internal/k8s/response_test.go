@@ -0,0 +1,99 @@
+package k8s
+
+import (
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// TestCache_ResponsePermission tests response permissions from global defaults and annotations
+func TestCache_ResponsePermission(t *testing.T) {
+	tests := []struct {
+		name        string
+		opts        Options
+		annotations map[string]string
+		want        *ResponsePermission
+	}{
+		{
+			name: "Default single response",
+			opts: DefaultOptions(),
+			want: &ResponsePermission{MaxMsgs: 1},
+		},
+		{
+			name: "Global defaults",
+			opts: Options{ResponseMaxMsgs: 3, ResponseTTL: 10 * time.Second},
+			want: &ResponsePermission{MaxMsgs: 3, Expires: 10 * time.Second},
+		},
+		{
+			name: "Annotations override defaults",
+			opts: DefaultOptions(),
+			annotations: map[string]string{
+				"nats.io/response-max-msgs": "-1",
+				"nats.io/response-ttl":      "5s",
+			},
+			want: &ResponsePermission{MaxMsgs: -1, Expires: 5 * time.Second},
+		},
+		{
+			name:        "Annotation disables responses",
+			opts:        DefaultOptions(),
+			annotations: map[string]string{"nats.io/allow-responses": "false"},
+			want:        nil,
+		},
+		{
+			name:        "Globally disabled",
+			opts:        Options{DisableResponses: true},
+			annotations: map[string]string{"nats.io/response-max-msgs": "5"},
+			want:        nil,
+		},
+		{
+			name:        "Annotation enables responses when globally disabled",
+			opts:        Options{DisableResponses: true},
+			annotations: map[string]string{"nats.io/allow-responses": "true"},
+			want:        &ResponsePermission{MaxMsgs: 1},
+		},
+		{
+			name: "Invalid annotations fall back to defaults",
+			opts: DefaultOptions(),
+			annotations: map[string]string{
+				"nats.io/response-max-msgs": "0",
+				"nats.io/response-ttl":      "-5s",
+			},
+			want: &ResponsePermission{MaxMsgs: 1},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cache := NewCacheWithOptions(zap.NewNop(), tt.opts)
+			cache.upsert(&corev1.ServiceAccount{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:        "api",
+					Namespace:   "payments",
+					Annotations: tt.annotations,
+				},
+			})
+
+			perms, found := cache.Lookup("payments", "api")
+			if !found {
+				t.Fatal("Expected ServiceAccount to be in cache after upsert")
+			}
+
+			if tt.want == nil {
+				if perms.Response != nil {
+					t.Errorf("Response = %+v, want nil", *perms.Response)
+				}
+				return
+			}
+
+			if perms.Response == nil {
+				t.Fatalf("Response = nil, want %+v", *tt.want)
+			}
+			if *perms.Response != *tt.want {
+				t.Errorf("Response = %+v, want %+v", *perms.Response, *tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,252 @@
+package k8s
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/client-go/informers"
+	"sigs.k8s.io/yaml"
+)
+
+// DefaultRevocationKey is the ConfigMap key holding the revocation list.
+const DefaultRevocationKey = "revocations.yaml"
+
+// RevocationPruneInterval is the resync period for the revocation list ConfigMap informer. Every
+// resync prunes the list again, so expired entries and entries for deleted pods are dropped
+// without the ConfigMap being edited.
+const RevocationPruneInterval = time.Minute
+
+// Revocation kinds returned by RevocationList.Revoked, identifying the entry a token matched.
+const (
+	RevokedTokenID      = "jti"
+	RevokedPod          = "pod_uid"
+	RevokedIssuedBefore = "issued_before"
+)
+
+// RevocationList rejects leaked tokens before their expiry. Entries revoke matching tokens for as
+// long as the tokens themselves are accepted; the optional expires field is only a hint for when an
+// entry can be pruned from the list, so a wrong value never lets a revoked token back in early.
+//
+// Example document:
+//
+//	tokens:
+//	  - jti: 1b20f55e-e39a-4010-96e3-5bba8e300ae7
+//	    expires: "2026-07-01T13:00:00Z"
+//	pods:
+//	  - uid: 989f1a6e-8af7-4740-93d8-206f8daf9a84
+//	serviceAccounts:
+//	  - serviceAccount: shop/api
+//	    issuedBefore: "2026-07-01T12:00:00Z"
+//	    expires: "2026-07-02T12:00:00Z"
+type RevocationList struct {
+	Tokens          []TokenRevocation          `json:"tokens,omitempty"`
+	Pods            []PodRevocation            `json:"pods,omitempty"`
+	ServiceAccounts []ServiceAccountRevocation `json:"serviceAccounts,omitempty"`
+}
+
+// TokenRevocation revokes a single token by its jti claim.
+type TokenRevocation struct {
+	TokenID string `json:"jti"`
+	// Expires is the exp claim of the revoked token, after which the entry is pruned.
+	// Optional; without it the entry is kept until it is removed from the list.
+	Expires time.Time `json:"expires,omitempty"`
+}
+
+// PodRevocation revokes every token bound to a pod by the pod's UID. The kubelet keeps issuing
+// tokens while the pod runs, so the entry is kept until the pod is gone.
+type PodRevocation struct {
+	UID string `json:"uid"`
+	// Expires is the latest exp claim of the pod's tokens. Optional; it keeps the entry after the
+	// pod is deleted until the pod's last token has expired.
+	Expires time.Time `json:"expires,omitempty"`
+}
+
+// ServiceAccountRevocation revokes every token of a ServiceAccount issued before a cutoff.
+type ServiceAccountRevocation struct {
+	// ServiceAccount in "namespace/name" form
+	ServiceAccount string    `json:"serviceAccount"`
+	IssuedBefore   time.Time `json:"issuedBefore"`
+	// Expires is the latest exp claim of tokens issued before the cutoff, i.e. the cutoff plus the
+	// maximum token lifetime, after which the entry is pruned. Optional.
+	Expires time.Time `json:"expires,omitempty"`
+}
+
+// ParseRevocationList strictly parses a YAML or JSON revocation list. An empty document revokes nothing.
+func ParseRevocationList(data []byte) (*RevocationList, error) {
+	list := &RevocationList{}
+	if err := yaml.UnmarshalStrict(data, list); err != nil {
+		return nil, fmt.Errorf("failed to parse revocation list: %w", err)
+	}
+	if err := list.validate(); err != nil {
+		return nil, err
+	}
+	return list, nil
+}
+
+// validate checks that every entry identifies what it revokes.
+func (l *RevocationList) validate() error {
+	for i, entry := range l.Tokens {
+		if entry.TokenID == "" {
+			return fmt.Errorf("revocation tokens[%d]: jti is required", i)
+		}
+	}
+	for i, entry := range l.Pods {
+		if entry.UID == "" {
+			return fmt.Errorf("revocation pods[%d]: uid is required", i)
+		}
+	}
+	for i, entry := range l.ServiceAccounts {
+		namespace, name, ok := strings.Cut(entry.ServiceAccount, "/")
+		if !ok || namespace == "" || name == "" || strings.Contains(name, "/") {
+			return fmt.Errorf("revocation serviceAccounts[%d]: serviceAccount must be in namespace/name form, got %q", i, entry.ServiceAccount)
+		}
+		if entry.IssuedBefore.IsZero() {
+			return fmt.Errorf("revocation serviceAccounts[%d]: issuedBefore is required", i)
+		}
+	}
+	return nil
+}
+
+// Prune returns a copy of the list without the entries that can no longer match an accepted token:
+// entries whose expires hint is at or before now, and pod entries whose pod is gone and whose
+// expires hint, if any, has passed. podExists reports whether a pod UID still exists; with nil,
+// every pod entry is kept.
+func (l *RevocationList) Prune(now time.Time, podExists func(uid string) bool) *RevocationList {
+	pruned := &RevocationList{}
+	for _, entry := range l.Tokens {
+		if hintPending(entry.Expires, now) {
+			pruned.Tokens = append(pruned.Tokens, entry)
+		}
+	}
+	for _, entry := range l.Pods {
+		podGone := podExists != nil && !podExists(entry.UID)
+		if !podGone || (!entry.Expires.IsZero() && entry.Expires.After(now)) {
+			pruned.Pods = append(pruned.Pods, entry)
+		}
+	}
+	for _, entry := range l.ServiceAccounts {
+		if hintPending(entry.Expires, now) {
+			pruned.ServiceAccounts = append(pruned.ServiceAccounts, entry)
+		}
+	}
+	return pruned
+}
+
+// hintPending reports whether an entry's optional expires hint is unset or still in the future
+func hintPending(expires, now time.Time) bool {
+	return expires.IsZero() || expires.After(now)
+}
+
+// Len returns the number of entries in the list.
+func (l *RevocationList) Len() int {
+	if l == nil {
+		return 0
+	}
+	return len(l.Tokens) + len(l.Pods) + len(l.ServiceAccounts)
+}
+
+// Revoked returns the kind of the first entry revoking a token, or an empty string if the token
+// is not revoked. Entries match regardless of their expires hint: the token's own exp claim,
+// checked when the token is validated, decides how long it is accepted. Tokens without an iat
+// claim are treated as issued before any cutoff.
+func (l *RevocationList) Revoked(namespace, serviceAccount, tokenID, podUID string, issuedAt time.Time) string {
+	if l == nil {
+		return ""
+	}
+	if tokenID != "" {
+		for _, entry := range l.Tokens {
+			if entry.TokenID == tokenID {
+				return RevokedTokenID
+			}
+		}
+	}
+	if podUID != "" {
+		for _, entry := range l.Pods {
+			if entry.UID == podUID {
+				return RevokedPod
+			}
+		}
+	}
+	key := makeKey(namespace, serviceAccount)
+	for _, entry := range l.ServiceAccounts {
+		if entry.ServiceAccount == key && issuedAt.Before(entry.IssuedBefore) {
+			return RevokedIssuedBefore
+		}
+	}
+	return ""
+}
+
+// WatchRevocationList watches a ConfigMap holding the revocation list under key and passes every
+// change to fn, pruned of entries that can no longer match (see RevocationList.Prune). Pod entries
+// are only pruned when pods are watched. Deleting the ConfigMap or its key
+// clears the list (fn receives nil). The factory must be scoped to the ConfigMap's namespace and
+// started by the caller; pruning is repeated on every resync, so the factory should resync every
+// RevocationPruneInterval. Invalid documents are logged and the previous list stays in effect.
+func (c *Client) WatchRevocationList(factory informers.SharedInformerFactory, name, key string, fn func(*RevocationList)) error {
+	// Resyncs deliver the unchanged ConfigMap; only log when it or the pruned list changed
+	lastVersion, lastEntries := "", -1
+
+	return watchConfigMap(factory, name,
+		func(cm *corev1.ConfigMap) {
+			data, ok := cm.Data[key]
+			if !ok {
+				c.logger.Info("Revocation list key not set, revocations cleared",
+					zap.String("configmap", makeKey(cm.Namespace, cm.Name)),
+					zap.String("key", key))
+				fn(nil)
+				return
+			}
+
+			list, err := ParseRevocationList([]byte(data))
+			if err != nil {
+				c.logger.Error("Ignoring invalid revocation list",
+					zap.String("configmap", makeKey(cm.Namespace, cm.Name)),
+					zap.String("key", key),
+					zap.Error(err))
+				return
+			}
+
+			pruned := list.Prune(time.Now(), c.podUIDExists())
+			if cm.ResourceVersion != lastVersion || pruned.Len() != lastEntries {
+				c.logger.Info("Revocation list updated from ConfigMap",
+					zap.String("configmap", makeKey(cm.Namespace, cm.Name)),
+					zap.Int("entries", pruned.Len()),
+					zap.Int("pruned", list.Len()-pruned.Len()))
+			}
+			lastVersion, lastEntries = cm.ResourceVersion, pruned.Len()
+			fn(pruned)
+		},
+		func() {
+			c.logger.Warn("Revocation list ConfigMap deleted, revocations cleared",
+				zap.String("configmap", name))
+			fn(nil)
+		},
+	)
+}
+
+// podUIDExists returns a function reporting whether a pod UID is in the pod informer's cache,
+// or nil when pods are not watched or cannot be listed
+func (c *Client) podUIDExists() func(uid string) bool {
+	if c.pods == nil {
+		return nil
+	}
+
+	pods, err := c.pods.List(labels.Everything())
+	if err != nil {
+		c.logger.Warn("Failed to list pods, keeping every pod revocation", zap.Error(err))
+		return nil
+	}
+	uids := make(map[string]struct{}, len(pods))
+	for _, pod := range pods {
+		uids[string(pod.UID)] = struct{}{}
+	}
+
+	return func(uid string) bool {
+		_, ok := uids[uid]
+		return ok
+	}
+}
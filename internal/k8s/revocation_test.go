@@ -0,0 +1,332 @@
+package k8s
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+const testRevocationList = `
+tokens:
+  - jti: 1b20f55e-e39a-4010-96e3-5bba8e300ae7
+    expires: "2026-07-01T13:00:00Z"
+pods:
+  - uid: 989f1a6e-8af7-4740-93d8-206f8daf9a84
+    expires: "2026-07-01T13:00:00Z"
+serviceAccounts:
+  - serviceAccount: shop/api
+    issuedBefore: "2026-07-01T12:00:00Z"
+    expires: "2026-07-02T12:00:00Z"
+`
+
+// TestParseRevocationList tests strict parsing and validation of revocation lists
+func TestParseRevocationList(t *testing.T) {
+	tests := []struct {
+		name    string
+		doc     string
+		wantErr bool
+	}{
+		{name: "Valid YAML", doc: testRevocationList, wantErr: false},
+		{name: "Valid JSON", doc: `{"tokens":[{"jti":"abc","expires":"2026-07-01T13:00:00Z"}]}`, wantErr: false},
+		{name: "Empty document", doc: "", wantErr: false},
+		{name: "Unknown field", doc: "tokens: []\nusers: []", wantErr: true},
+		{name: "Token without expiry", doc: "tokens:\n  - jti: abc", wantErr: false},
+		{name: "Token without jti", doc: "tokens:\n  - expires: \"2026-07-01T13:00:00Z\"", wantErr: true},
+		{name: "Pod without expiry", doc: "pods:\n  - uid: abc", wantErr: false},
+		{name: "Pod without UID", doc: "pods:\n  - expires: \"2026-07-01T13:00:00Z\"", wantErr: true},
+		{name: "ServiceAccount without namespace", doc: "serviceAccounts:\n  - serviceAccount: api\n    issuedBefore: \"2026-07-01T12:00:00Z\"\n    expires: \"2026-07-02T12:00:00Z\"", wantErr: true},
+		{name: "ServiceAccount without cutoff", doc: "serviceAccounts:\n  - serviceAccount: shop/api\n    expires: \"2026-07-02T12:00:00Z\"", wantErr: true},
+		{name: "Invalid timestamp", doc: "tokens:\n  - jti: abc\n    expires: tomorrow", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ParseRevocationList([]byte(tt.doc))
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ParseRevocationList() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+// TestRevocationList_Revoked tests matching tokens against revocation entries
+func TestRevocationList_Revoked(t *testing.T) {
+	list, err := ParseRevocationList([]byte(testRevocationList))
+	if err != nil {
+		t.Fatalf("ParseRevocationList() error = %v", err)
+	}
+
+	issued := time.Date(2026, 7, 1, 12, 15, 0, 0, time.UTC)
+
+	tests := []struct {
+		name      string
+		list      *RevocationList
+		namespace string
+		sa        string
+		tokenID   string
+		podUID    string
+		issuedAt  time.Time
+		want      string
+	}{
+		{name: "No list", list: nil, namespace: "shop", sa: "api", tokenID: "1b20f55e-e39a-4010-96e3-5bba8e300ae7", want: ""},
+		{name: "Revoked jti", list: list, namespace: "billing", sa: "worker", tokenID: "1b20f55e-e39a-4010-96e3-5bba8e300ae7", issuedAt: issued, want: RevokedTokenID},
+		{name: "Revoked pod", list: list, namespace: "billing", sa: "worker", podUID: "989f1a6e-8af7-4740-93d8-206f8daf9a84", issuedAt: issued, want: RevokedPod},
+		{name: "Issued before cutoff", list: list, namespace: "shop", sa: "api", issuedAt: issued.Add(-time.Hour), want: RevokedIssuedBefore},
+		{name: "Missing iat", list: list, namespace: "shop", sa: "api", want: RevokedIssuedBefore},
+		{name: "Issued after cutoff", list: list, namespace: "shop", sa: "api", issuedAt: issued, want: ""},
+		{name: "Other ServiceAccount", list: list, namespace: "shop", sa: "web", issuedAt: issued.Add(-time.Hour), want: ""},
+		{name: "Unknown jti", list: list, namespace: "billing", sa: "worker", tokenID: "other", issuedAt: issued, want: ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.list.Revoked(tt.namespace, tt.sa, tt.tokenID, tt.podUID, tt.issuedAt); got != tt.want {
+				t.Errorf("Revoked() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+// TestRevocationList_Prune tests dropping entries by their expires hint and pod entries once the pod is gone
+func TestRevocationList_Prune(t *testing.T) {
+	list, err := ParseRevocationList([]byte(`
+tokens:
+  - jti: hinted
+    expires: "2026-07-01T13:00:00Z"
+  - jti: unhinted
+pods:
+  - uid: running
+  - uid: deleted
+  - uid: deleted-hinted
+    expires: "2026-07-01T13:00:00Z"
+serviceAccounts:
+  - serviceAccount: shop/api
+    issuedBefore: "2026-07-01T12:00:00Z"
+    expires: "2026-07-02T12:00:00Z"
+`))
+	if err != nil {
+		t.Fatalf("ParseRevocationList() error = %v", err)
+	}
+	podExists := func(uid string) bool { return uid == "running" }
+	before := time.Date(2026, 7, 1, 12, 30, 0, 0, time.UTC)
+	after := time.Date(2026, 7, 1, 13, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name      string
+		now       time.Time
+		podExists func(string) bool
+		wantToken []string
+		wantPods  []string
+		wantSAs   int
+	}{
+		{name: "Before expires hints", now: before, podExists: podExists, wantToken: []string{"hinted", "unhinted"}, wantPods: []string{"running", "deleted-hinted"}, wantSAs: 1},
+		{name: "After expires hints", now: after, podExists: podExists, wantToken: []string{"unhinted"}, wantPods: []string{"running"}, wantSAs: 1},
+		{name: "Pods not watched", now: after, podExists: nil, wantToken: []string{"unhinted"}, wantPods: []string{"running", "deleted", "deleted-hinted"}, wantSAs: 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pruned := list.Prune(tt.now, tt.podExists)
+
+			var tokens, pods []string
+			for _, entry := range pruned.Tokens {
+				tokens = append(tokens, entry.TokenID)
+			}
+			for _, entry := range pruned.Pods {
+				pods = append(pods, entry.UID)
+			}
+			if !equalStringSlices(tokens, tt.wantToken) {
+				t.Errorf("tokens = %v, want %v", tokens, tt.wantToken)
+			}
+			if !equalStringSlices(pods, tt.wantPods) {
+				t.Errorf("pods = %v, want %v", pods, tt.wantPods)
+			}
+			if len(pruned.ServiceAccounts) != tt.wantSAs {
+				t.Errorf("serviceAccounts = %+v, want %d", pruned.ServiceAccounts, tt.wantSAs)
+			}
+		})
+	}
+
+	if list.Len() != 6 {
+		t.Errorf("Prune() modified the original list")
+	}
+}
+
+// TestClient_WatchRevocationList tests that ConfigMap changes update the revocation list
+func TestClient_WatchRevocationList(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	fakeClient := fake.NewSimpleClientset()
+	client := NewClient(informers.NewSharedInformerFactory(fakeClient, 0), zap.NewNop())
+
+	var mu sync.Mutex
+	var current *RevocationList
+	calls := 0
+	state := func() (*RevocationList, int) {
+		mu.Lock()
+		defer mu.Unlock()
+		return current, calls
+	}
+
+	factory := informers.NewSharedInformerFactoryWithOptions(fakeClient, 0, informers.WithNamespace("nats-system"))
+	err := client.WatchRevocationList(factory, "revocations", DefaultRevocationKey, func(list *RevocationList) {
+		mu.Lock()
+		defer mu.Unlock()
+		current = list
+		calls++
+	})
+	if err != nil {
+		t.Fatalf("WatchRevocationList() error = %v", err)
+	}
+
+	stopCh := make(chan struct{})
+	defer close(stopCh)
+	factory.Start(stopCh)
+	factory.WaitForCacheSync(stopCh)
+
+	expires := time.Now().Add(time.Hour).UTC().Format(time.RFC3339)
+	cm := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: "revocations", Namespace: "nats-system"},
+		Data: map[string]string{DefaultRevocationKey: `
+tokens:
+  - jti: leaked
+    expires: "` + expires + `"
+  - jti: old
+    expires: "2020-01-01T00:00:00Z"
+`},
+	}
+	if _, err := fakeClient.CoreV1().ConfigMaps("nats-system").Create(ctx, cm, metav1.CreateOptions{}); err != nil {
+		t.Fatalf("Failed to create ConfigMap: %v", err)
+	}
+	time.Sleep(100 * time.Millisecond)
+
+	list, _ := state()
+	if list.Len() != 1 || list.Tokens[0].TokenID != "leaked" {
+		t.Fatalf("list after create = %+v, want the entry past its expires hint pruned", list)
+	}
+
+	// An invalid document keeps the previous list
+	cm.Data[DefaultRevocationKey] = "tokens:\n  - expires: \"2030-01-01T00:00:00Z\""
+	if _, err := fakeClient.CoreV1().ConfigMaps("nats-system").Update(ctx, cm, metav1.UpdateOptions{}); err != nil {
+		t.Fatalf("Failed to update ConfigMap: %v", err)
+	}
+	time.Sleep(100 * time.Millisecond)
+
+	if list, calls := state(); calls != 1 || list.Len() != 1 {
+		t.Fatalf("list after invalid update = %+v (%d calls), want previous list", list, calls)
+	}
+
+	if err := fakeClient.CoreV1().ConfigMaps("nats-system").Delete(ctx, "revocations", metav1.DeleteOptions{}); err != nil {
+		t.Fatalf("Failed to delete ConfigMap: %v", err)
+	}
+	time.Sleep(100 * time.Millisecond)
+
+	if list, _ := state(); list != nil {
+		t.Errorf("list after delete = %+v, want nil", list)
+	}
+}
+
+// TestClient_PodUIDExists tests looking up pod UIDs in the pod informer's cache
+func TestClient_PodUIDExists(t *testing.T) {
+	fakeClient := fake.NewSimpleClientset(&corev1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "api-1", Namespace: "shop", UID: "running"}})
+	factory := informers.NewSharedInformerFactory(fakeClient, 0)
+	client := NewClient(factory, zap.NewNop())
+
+	if client.podUIDExists() != nil {
+		t.Fatal("Expected no pod lookup when pods are not watched")
+	}
+
+	if err := client.WatchPods(factory); err != nil {
+		t.Fatalf("WatchPods() error = %v", err)
+	}
+	stopCh := make(chan struct{})
+	defer close(stopCh)
+	factory.Start(stopCh)
+	factory.WaitForCacheSync(stopCh)
+
+	podExists := client.podUIDExists()
+	if podExists == nil || !podExists("running") || podExists("deleted") {
+		t.Error("Expected only the running pod's UID to exist")
+	}
+}
+
+// TestClient_WatchRevocationListResync tests that entries are pruned on informer resyncs, without
+// the ConfigMap being updated, once they expire or their pod is deleted
+func TestClient_WatchRevocationListResync(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	expires := time.Now().Add(time.Second).UTC().Format(time.RFC3339Nano)
+	fakeClient := fake.NewSimpleClientset(
+		&corev1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "api-1", Namespace: "shop", UID: "compromised"}},
+		&corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{Name: "revocations", Namespace: "nats-system"},
+			Data: map[string]string{DefaultRevocationKey: `
+tokens:
+  - jti: leaked
+  - jti: expiring
+    expires: "` + expires + `"
+pods:
+  - uid: compromised
+`},
+		},
+	)
+	podFactory := informers.NewSharedInformerFactory(fakeClient, 0)
+	client := NewClient(podFactory, zap.NewNop())
+	if err := client.WatchPods(podFactory); err != nil {
+		t.Fatalf("WatchPods() error = %v", err)
+	}
+
+	var mu sync.Mutex
+	var current *RevocationList
+	state := func() *RevocationList {
+		mu.Lock()
+		defer mu.Unlock()
+		return current
+	}
+
+	// client-go resyncs no more often than once a second
+	factory := informers.NewSharedInformerFactoryWithOptions(fakeClient, time.Second, informers.WithNamespace("nats-system"))
+	err := client.WatchRevocationList(factory, "revocations", DefaultRevocationKey, func(list *RevocationList) {
+		mu.Lock()
+		defer mu.Unlock()
+		current = list
+	})
+	if err != nil {
+		t.Fatalf("WatchRevocationList() error = %v", err)
+	}
+
+	stopCh := make(chan struct{})
+	defer close(stopCh)
+	podFactory.Start(stopCh)
+	podFactory.WaitForCacheSync(stopCh)
+	factory.Start(stopCh)
+	factory.WaitForCacheSync(stopCh)
+
+	if list := state(); list.Len() != 3 {
+		t.Fatalf("list after sync = %+v, want all three entries", list)
+	}
+
+	if err := fakeClient.CoreV1().Pods("shop").Delete(ctx, "api-1", metav1.DeleteOptions{}); err != nil {
+		t.Fatalf("Failed to delete pod: %v", err)
+	}
+
+	for {
+		if list := state(); list.Len() == 1 && list.Tokens[0].TokenID == "leaked" {
+			return
+		}
+		select {
+		case <-ctx.Done():
+			t.Fatalf("list = %+v, want the expired and deleted pod entries pruned on resync", state())
+		case <-time.After(100 * time.Millisecond):
+		}
+	}
+}
@@ -0,0 +1,93 @@
+package k8s
+
+import (
+	"reflect"
+	"slices"
+
+	"k8s.io/apimachinery/pkg/labels"
+)
+
+// Reasons passed to the revoke handler when a ServiceAccount's existing clients should be
+// disconnected so they reconnect with current permissions.
+const (
+	RevokeDisabled           = "sa_disabled"
+	RevokePermissionsReduced = "permissions_reduced"
+	RevokeDeleted            = "sa_deleted"
+)
+
+// permissionsReduced reports whether clients authorized with the previous permissions hold rights
+// the current permissions no longer grant. Subject changes that only widen access are ignored.
+// Any change to connection restrictions or pod rules counts as a reduction, since comparing them
+// precisely is not worth a stale grant.
+func permissionsReduced(previous, current *Permissions) bool {
+	return !subjectsCovered(previous.Publish, current.Publish) ||
+		!subjectsCovered(previous.Subscribe, current.Subscribe) ||
+		!queuesCovered(previous.SubscribeQueues, current.SubscribeQueues) ||
+		!containsAll(previous.PublishDeny, current.PublishDeny) ||
+		!containsAll(previous.SubscribeDeny, current.SubscribeDeny) ||
+		responseReduced(previous.Response, current.Response) ||
+		!slices.Equal(previous.SourceCIDRs, current.SourceCIDRs) ||
+		!slices.Equal(previous.ConnectionTypes, current.ConnectionTypes) ||
+		!reflect.DeepEqual(previous.AllowedTimes, current.AllowedTimes) ||
+		!reflect.DeepEqual(previous.Limits, current.Limits) ||
+		selectorString(previous.NodeSelector) != selectorString(current.NodeSelector) ||
+		previous.PodMode != current.PodMode ||
+		!reflect.DeepEqual(previous.PodRules, current.PodRules)
+}
+
+// subjectsCovered reports whether every previous subject is still covered by a current subject.
+func subjectsCovered(previous, current []string) bool {
+	for _, subject := range previous {
+		if !slices.ContainsFunc(current, func(broader string) bool { return subjectCovers(broader, subject) }) {
+			return false
+		}
+	}
+	return true
+}
+
+// queuesCovered reports whether every previous queue subscription is still covered by a current
+// one in the same queue group.
+func queuesCovered(previous, current []QueueSubscription) bool {
+	for _, q := range previous {
+		if !slices.ContainsFunc(current, func(c QueueSubscription) bool {
+			return c.Queue == q.Queue && subjectCovers(c.Subject, q.Subject)
+		}) {
+			return false
+		}
+	}
+	return true
+}
+
+// containsAll reports whether every current entry was already present, i.e. nothing was added.
+func containsAll(previous, current []string) bool {
+	for _, entry := range current {
+		if !slices.Contains(previous, entry) {
+			return false
+		}
+	}
+	return true
+}
+
+// responseReduced reports whether fewer or shorter responses are allowed than before.
+func responseReduced(previous, current *ResponsePermission) bool {
+	switch {
+	case previous == nil:
+		return false
+	case current == nil:
+		return true
+	case current.Expires != previous.Expires:
+		return true
+	case previous.MaxMsgs < 0:
+		return current.MaxMsgs >= 0
+	default:
+		return current.MaxMsgs >= 0 && current.MaxMsgs < previous.MaxMsgs
+	}
+}
+
+// selectorString returns a comparable form of an optional label selector
+func selectorString(selector labels.Selector) string {
+	if selector == nil {
+		return ""
+	}
+	return selector.String()
+}
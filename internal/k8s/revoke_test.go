@@ -0,0 +1,97 @@
+package k8s
+
+import (
+	"net/netip"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// TestPermissionsReduced tests detecting permission changes that take rights away from clients
+func TestPermissionsReduced(t *testing.T) {
+	base := func() *Permissions {
+		return &Permissions{
+			Publish:         []string{"shop.>"},
+			Subscribe:       []string{"_INBOX.>", "shop.orders.*"},
+			SubscribeQueues: []QueueSubscription{{Subject: "jobs.>", Queue: "workers"}},
+			Response:        &ResponsePermission{MaxMsgs: 1},
+		}
+	}
+
+	tests := []struct {
+		name        string
+		modify      func(p *Permissions)
+		wantReduced bool
+	}{
+		{name: "Unchanged", modify: func(p *Permissions) {}, wantReduced: false},
+		{name: "Subject added", modify: func(p *Permissions) { p.Publish = append(p.Publish, "billing.>") }, wantReduced: false},
+		{name: "Subject widened", modify: func(p *Permissions) { p.Subscribe = []string{"_INBOX.>", "shop.>"} }, wantReduced: false},
+		{name: "Subject removed", modify: func(p *Permissions) { p.Publish = nil }, wantReduced: true},
+		{name: "Subject narrowed", modify: func(p *Permissions) { p.Publish = []string{"shop.orders.>"} }, wantReduced: true},
+		{name: "Queue group changed", modify: func(p *Permissions) { p.SubscribeQueues[0].Queue = "other" }, wantReduced: true},
+		{name: "Deny added", modify: func(p *Permissions) { p.PublishDeny = []string{"shop.admin.>"} }, wantReduced: true},
+		{name: "Responses disabled", modify: func(p *Permissions) { p.Response = nil }, wantReduced: true},
+		{name: "Responses increased", modify: func(p *Permissions) { p.Response.MaxMsgs = 5 }, wantReduced: false},
+		{name: "Source networks changed", modify: func(p *Permissions) {
+			p.SourceCIDRs = []netip.Prefix{netip.MustParsePrefix("10.0.0.0/8")}
+		}, wantReduced: true},
+		{name: "Disabled flag only", modify: func(p *Permissions) { p.DisabledUntil = time.Now() }, wantReduced: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			current := base()
+			tt.modify(current)
+			if got := permissionsReduced(base(), current); got != tt.wantReduced {
+				t.Errorf("permissionsReduced() = %v, want %v", got, tt.wantReduced)
+			}
+		})
+	}
+}
+
+// TestCache_RevokeHandler tests notifying the revoke handler when permissions are reduced or the SA is deleted
+func TestCache_RevokeHandler(t *testing.T) {
+	cache := NewCache(zap.NewNop())
+	reasons := make(chan string, 10)
+	cache.SetRevokeHandler(func(namespace, name, reason string) {
+		reasons <- reason
+	})
+
+	newSA := func(pub string) *corev1.ServiceAccount {
+		return &corev1.ServiceAccount{ObjectMeta: metav1.ObjectMeta{
+			Name:        "api",
+			Namespace:   "shop",
+			Annotations: map[string]string{AnnotationAllowedPubSubjects: pub},
+		}}
+	}
+
+	expect := func(want string) {
+		t.Helper()
+		select {
+		case got := <-reasons:
+			if got != want {
+				t.Errorf("revoke reason = %q, want %q", got, want)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("Expected revoke handler to be called with %q", want)
+		}
+	}
+
+	cache.upsert(newSA("billing.>"))
+	cache.upsert(newSA("billing.>, audit.>")) // widened: no revoke
+	cache.upsert(newSA("audit.>"))
+	expect(RevokePermissionsReduced)
+
+	cache.delete("shop", "api")
+	expect(RevokeDeleted)
+
+	cache.delete("shop", "api") // already gone: no revoke
+	select {
+	case got := <-reasons:
+		t.Errorf("Unexpected revoke with reason %q", got)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
@@ -0,0 +1,135 @@
+package k8s
+
+import (
+	"fmt"
+	"net/netip"
+	"strings"
+
+	"go.uber.org/zap"
+	corev1 "k8s.io/api/core/v1"
+)
+
+// AnnotationAllowedSourceCIDRs restricts the client networks a ServiceAccount may connect from
+// (comma-separated CIDRs or IP addresses, e.g. "10.0.0.0/8, 192.168.1.10").
+const AnnotationAllowedSourceCIDRs = "nats.io/allowed-source-cidrs"
+
+// noSources is the source restriction of a ServiceAccount whose annotation is invalid or leaves
+// no usable networks. The zero prefix is invalid and contains no address, so every client is
+// rejected rather than falling back to a wider restriction.
+var noSources = []netip.Prefix{{}}
+
+// ParseSourceCIDRs parses a list of CIDRs or bare IP addresses into network prefixes.
+// Bare addresses are treated as single-host prefixes.
+func ParseSourceCIDRs(values []string) ([]netip.Prefix, error) {
+	prefixes := make([]netip.Prefix, 0, len(values))
+	for _, value := range values {
+		value = strings.TrimSpace(value)
+		if value == "" {
+			continue
+		}
+
+		if !strings.Contains(value, "/") {
+			addr, err := netip.ParseAddr(value)
+			if err != nil {
+				return nil, fmt.Errorf("invalid source address %q: %w", value, err)
+			}
+			addr = addr.Unmap()
+			prefixes = append(prefixes, netip.PrefixFrom(addr, addr.BitLen()))
+			continue
+		}
+
+		prefix, err := netip.ParsePrefix(value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid source CIDR %q: %w", value, err)
+		}
+		prefixes = append(prefixes, prefix.Masked())
+	}
+	return prefixes, nil
+}
+
+// SourceAllowed reports whether a client host may use these permissions.
+// Any host is allowed when no source restriction applies.
+func (p *Permissions) SourceAllowed(host string) bool {
+	if len(p.SourceCIDRs) == 0 {
+		return true
+	}
+
+	addr, err := netip.ParseAddr(host)
+	if err != nil {
+		return false
+	}
+
+	return prefixesContain(p.SourceCIDRs, addr.Unmap())
+}
+
+// buildSourceCIDRs resolves the source networks for a ServiceAccount.
+// Annotation CIDRs can only narrow the global restriction: entries outside the global ranges are
+// dropped. When the annotation is absent the global ranges apply; when it is invalid or yields no
+// usable entries, no source is allowed so the restriction fails closed.
+func buildSourceCIDRs(sa *corev1.ServiceAccount, global []netip.Prefix, logger *zap.Logger) []netip.Prefix {
+	value, ok := sa.Annotations[AnnotationAllowedSourceCIDRs]
+	if !ok {
+		return global
+	}
+
+	parsed, err := ParseSourceCIDRs(strings.Split(value, ","))
+	if err != nil {
+		logInvalidAnnotation(sa, AnnotationAllowedSourceCIDRs, value, err, logger)
+		return noSources
+	}
+
+	allowed := make([]netip.Prefix, 0, len(parsed))
+	for _, prefix := range parsed {
+		if len(global) > 0 && !prefixesCover(global, prefix) {
+			logger.Warn("Dropped source CIDR outside the global allowed ranges",
+				zap.String("namespace", sa.Namespace),
+				zap.String("serviceaccount", sa.Name),
+				zap.String("cidr", prefix.String()))
+			continue
+		}
+		allowed = append(allowed, prefix)
+	}
+	if len(allowed) == 0 {
+		logInvalidAnnotation(sa, AnnotationAllowedSourceCIDRs, value, fmt.Errorf("no allowed source networks listed"), logger)
+		return noSources
+	}
+
+	return allowed
+}
+
+// prefixesContain reports whether any prefix contains the address.
+func prefixesContain(prefixes []netip.Prefix, addr netip.Addr) bool {
+	for _, prefix := range prefixes {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+// prefixesCover reports whether any prefix fully contains the given prefix.
+func prefixesCover(prefixes []netip.Prefix, inner netip.Prefix) bool {
+	for _, prefix := range prefixes {
+		if prefix.Bits() <= inner.Bits() && prefix.Contains(inner.Addr()) {
+			return true
+		}
+	}
+	return false
+}
+
+// SourceCIDRStrings formats network prefixes for use in NATS user claims.
+// Invalid prefixes (see noSources) are skipped; they never allow a client to be authorized.
+func SourceCIDRStrings(prefixes []netip.Prefix) []string {
+	if len(prefixes) == 0 {
+		return nil
+	}
+
+	cidrs := make([]string, 0, len(prefixes))
+	for _, prefix := range prefixes {
+		if !prefix.IsValid() {
+			continue
+		}
+		cidrs = append(cidrs, prefix.String())
+	}
+	return cidrs
+}
@@ -0,0 +1,130 @@
+package k8s
+
+import (
+	"net/netip"
+	"testing"
+
+	"go.uber.org/zap"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// TestParseSourceCIDRs tests parsing CIDRs and bare addresses into prefixes
+func TestParseSourceCIDRs(t *testing.T) {
+	tests := []struct {
+		name    string
+		values  []string
+		want    []string
+		wantErr bool
+	}{
+		{name: "IPv4 and IPv6 CIDRs", values: []string{"10.244.0.0/16", "fd00::/64"}, want: []string{"10.244.0.0/16", "fd00::/64"}},
+		{name: "Bare addresses", values: []string{"192.168.1.10", "::ffff:10.0.0.1"}, want: []string{"192.168.1.10/32", "10.0.0.1/32"}},
+		{name: "Host bits masked", values: []string{" 10.244.3.17/16 "}, want: []string{"10.244.0.0/16"}},
+		{name: "Empty entries skipped", values: []string{"", " "}, want: nil},
+		{name: "Invalid prefix length", values: []string{"10.0.0.0/33"}, wantErr: true},
+		{name: "Invalid address", values: []string{"pods.local"}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseSourceCIDRs(tt.values)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseSourceCIDRs() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if gotStrings := SourceCIDRStrings(got); !equalStringSlices(gotStrings, tt.want) {
+				t.Errorf("ParseSourceCIDRs() = %v, want %v", gotStrings, tt.want)
+			}
+		})
+	}
+}
+
+// TestCache_SourceCIDRs tests source network restrictions from global ranges and annotations
+func TestCache_SourceCIDRs(t *testing.T) {
+	podCIDRs := []netip.Prefix{netip.MustParsePrefix("10.244.0.0/16")}
+
+	tests := []struct {
+		name        string
+		global      []netip.Prefix
+		annotations map[string]string
+		want        []string
+		rejected    []string // Client hosts that must not be allowed
+	}{
+		{
+			name: "Unrestricted by default",
+			want: nil,
+		},
+		{
+			name:   "Global pod CIDR",
+			global: podCIDRs,
+			want:   []string{"10.244.0.0/16"},
+		},
+		{
+			name:        "Annotation without global restriction",
+			annotations: map[string]string{"nats.io/allowed-source-cidrs": "192.168.1.0/24, 192.168.2.10"},
+			want:        []string{"192.168.1.0/24", "192.168.2.10/32"},
+		},
+		{
+			name:        "Annotation narrows global restriction",
+			global:      podCIDRs,
+			annotations: map[string]string{"nats.io/allowed-source-cidrs": "10.244.8.0/24, 192.168.1.0/24"},
+			want:        []string{"10.244.8.0/24"},
+		},
+		{
+			name:        "Annotation cannot widen global restriction",
+			global:      podCIDRs,
+			annotations: map[string]string{"nats.io/allowed-source-cidrs": "0.0.0.0/0"},
+			want:        []string{},
+			rejected:    []string{"10.244.8.1", "8.8.8.8"},
+		},
+		{
+			name:        "Invalid annotation allows no source",
+			global:      podCIDRs,
+			annotations: map[string]string{"nats.io/allowed-source-cidrs": "10.244.8.0/24, not-a-cidr"},
+			want:        []string{},
+			rejected:    []string{"10.244.8.1", "8.8.8.8"},
+		},
+		{
+			name:        "Invalid annotation without global restriction allows no source",
+			annotations: map[string]string{"nats.io/allowed-source-cidrs": "10.0.0.0/33"},
+			want:        []string{},
+			rejected:    []string{"10.0.0.1", "8.8.8.8"},
+		},
+		{
+			name:        "Annotation entries all outside global restriction allow no source",
+			global:      podCIDRs,
+			annotations: map[string]string{"nats.io/allowed-source-cidrs": "192.168.1.0/24, 192.168.2.10"},
+			want:        []string{},
+			rejected:    []string{"10.244.8.1", "192.168.1.5"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cache := NewCacheWithOptions(zap.NewNop(), Options{SourceCIDRs: tt.global})
+			cache.upsert(&corev1.ServiceAccount{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:        "api",
+					Namespace:   "payments",
+					Annotations: tt.annotations,
+				},
+			})
+
+			perms, found := cache.Lookup("payments", "api")
+			if !found {
+				t.Fatal("Expected ServiceAccount to be in cache after upsert")
+			}
+
+			if got := SourceCIDRStrings(perms.SourceCIDRs); !equalStringSlices(got, tt.want) {
+				t.Errorf("SourceCIDRs = %v, want %v", got, tt.want)
+			}
+			for _, host := range tt.rejected {
+				if perms.SourceAllowed(host) {
+					t.Errorf("SourceAllowed(%q) = true, want false", host)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,107 @@
+package k8s
+
+import (
+	"fmt"
+	"strings"
+)
+
+// validateSubject checks that a subject is a syntactically valid NATS permission subject.
+// Wildcards are allowed: '*' must be a whole token and '>' must be the whole last token.
+// {{.Pod}} placeholders are treated as a single literal token.
+func validateSubject(subject string) error {
+	if subject == "" {
+		return fmt.Errorf("empty subject")
+	}
+
+	checked := strings.ReplaceAll(subject, PlaceholderPod, "pod")
+	if strings.ContainsAny(checked, " \t\r\n") {
+		return fmt.Errorf("subject %q contains whitespace", subject)
+	}
+
+	tokens := strings.Split(checked, ".")
+	for i, token := range tokens {
+		switch {
+		case token == "":
+			return fmt.Errorf("subject %q contains an empty token", subject)
+		case token == ">":
+			if i != len(tokens)-1 {
+				return fmt.Errorf("subject %q has '>' before the last token", subject)
+			}
+		case token == "*":
+			// Single-token wildcard is valid anywhere
+		case strings.ContainsAny(token, "*>"):
+			return fmt.Errorf("subject %q has a wildcard inside token %q", subject, token)
+		}
+	}
+
+	return nil
+}
+
+// subjectCovers reports whether every subject matched by pattern is also matched by broader.
+func subjectCovers(broader, pattern string) bool {
+	b := strings.Split(broader, ".")
+	p := strings.Split(pattern, ".")
+
+	for i, token := range b {
+		if token == ">" {
+			// '>' needs at least one remaining token to match
+			return len(p) > i
+		}
+		if i >= len(p) {
+			return false
+		}
+		if p[i] == ">" {
+			return false
+		}
+		if token != "*" && token != p[i] {
+			return false
+		}
+	}
+
+	return len(b) == len(p)
+}
+
+// normalizeSubjects removes duplicate subjects and subjects already covered by a broader
+// wildcard in the same list. The order of the remaining subjects is preserved.
+// Returns the kept subjects and the removed ones.
+func normalizeSubjects(subjects []string) (kept, removed []string) {
+	kept = make([]string, 0, len(subjects))
+	seen := make(map[string]bool, len(subjects))
+
+	for i, subject := range subjects {
+		if seen[subject] {
+			removed = append(removed, subject)
+			continue
+		}
+		seen[subject] = true
+
+		if isCoveredByOther(subjects, i) {
+			removed = append(removed, subject)
+			continue
+		}
+		kept = append(kept, subject)
+	}
+
+	return kept, removed
+}
+
+// isCoveredByOther reports whether subjects[idx] is covered by a different, broader subject in the list.
+// Subjects containing {{.Pod}} are never treated as covered or covering, since the placeholder
+// only becomes a single token at authorization time.
+func isCoveredByOther(subjects []string, idx int) bool {
+	subject := subjects[idx]
+	if strings.Contains(subject, PlaceholderPod) {
+		return false
+	}
+
+	for i, other := range subjects {
+		if i == idx || other == subject || strings.Contains(other, PlaceholderPod) {
+			continue
+		}
+		if subjectCovers(other, subject) {
+			return true
+		}
+	}
+
+	return false
+}
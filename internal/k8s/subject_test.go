@@ -0,0 +1,140 @@
+package k8s
+
+import (
+	"testing"
+
+	"go.uber.org/zap"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// TestValidateSubject tests NATS subject syntax validation
+func TestValidateSubject(t *testing.T) {
+	tests := []struct {
+		name    string
+		subject string
+		wantErr bool
+	}{
+		{name: "Literal subject", subject: "orders.created", wantErr: false},
+		{name: "Full wildcard", subject: "orders.>", wantErr: false},
+		{name: "Bare full wildcard", subject: ">", wantErr: false},
+		{name: "Token wildcard", subject: "orders.*.created", wantErr: false},
+		{name: "Pod placeholder", subject: "pods.{{.Pod}}.>", wantErr: false},
+		{name: "Empty subject", subject: "", wantErr: true},
+		{name: "Empty token", subject: "foo..bar", wantErr: true},
+		{name: "Leading dot", subject: ".foo", wantErr: true},
+		{name: "Trailing dot", subject: "foo.", wantErr: true},
+		{name: "Full wildcard not last", subject: "foo.>.bar", wantErr: true},
+		{name: "Whitespace", subject: "a b", wantErr: true},
+		{name: "Tab", subject: "a\tb", wantErr: true},
+		{name: "Wildcard inside token", subject: "foo.ba*", wantErr: true},
+		{name: "Full wildcard inside token", subject: "foo.bar>", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateSubject(tt.subject)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("validateSubject(%q) error = %v, wantErr %v", tt.subject, err, tt.wantErr)
+			}
+		})
+	}
+}
+
+// TestSubjectCovers tests wildcard coverage between subjects
+func TestSubjectCovers(t *testing.T) {
+	tests := []struct {
+		broader string
+		pattern string
+		want    bool
+	}{
+		{broader: "orders.>", pattern: "orders.created", want: true},
+		{broader: "orders.>", pattern: "orders.*.created", want: true},
+		{broader: "orders.>", pattern: "orders", want: false},
+		{broader: ">", pattern: "anything.at.all", want: true},
+		{broader: "orders.*", pattern: "orders.created", want: true},
+		{broader: "orders.*", pattern: "orders.created.eu", want: false},
+		{broader: "orders.*", pattern: "orders.>", want: false},
+		{broader: "orders.*.>", pattern: "orders.>", want: false},
+		{broader: "_INBOX.>", pattern: "_INBOX_ns_sa.>", want: false},
+		{broader: "orders.created", pattern: "orders.*", want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.broader+" covers "+tt.pattern, func(t *testing.T) {
+			if got := subjectCovers(tt.broader, tt.pattern); got != tt.want {
+				t.Errorf("subjectCovers(%q, %q) = %v, want %v", tt.broader, tt.pattern, got, tt.want)
+			}
+		})
+	}
+}
+
+// TestNormalizeSubjects tests removal of duplicate and covered subjects
+func TestNormalizeSubjects(t *testing.T) {
+	tests := []struct {
+		name        string
+		subjects    []string
+		wantKept    []string
+		wantRemoved []string
+	}{
+		{
+			name:     "No redundancy",
+			subjects: []string{"_INBOX.>", "_INBOX_ns_sa.>", "ns.>"},
+			wantKept: []string{"_INBOX.>", "_INBOX_ns_sa.>", "ns.>"},
+		},
+		{
+			name:        "Duplicates removed",
+			subjects:    []string{"ns.>", "shared.status", "ns.>"},
+			wantKept:    []string{"ns.>", "shared.status"},
+			wantRemoved: []string{"ns.>"},
+		},
+		{
+			name:        "Covered subjects removed regardless of order",
+			subjects:    []string{"ns.orders.created", "ns.>", "ns.*.updated"},
+			wantKept:    []string{"ns.>"},
+			wantRemoved: []string{"ns.orders.created", "ns.*.updated"},
+		},
+		{
+			name:     "Pod placeholder subjects kept",
+			subjects: []string{"pods.*.*.>", "pods.{{.Pod}}.>"},
+			wantKept: []string{"pods.*.*.>", "pods.{{.Pod}}.>"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			kept, removed := normalizeSubjects(tt.subjects)
+			if !equalStringSlices(kept, tt.wantKept) {
+				t.Errorf("normalizeSubjects() kept = %v, want %v", kept, tt.wantKept)
+			}
+			if !equalStringSlices(removed, tt.wantRemoved) {
+				t.Errorf("normalizeSubjects() removed = %v, want %v", removed, tt.wantRemoved)
+			}
+		})
+	}
+}
+
+// TestCache_InvalidAnnotationSubjects tests that malformed and redundant annotation subjects are dropped
+func TestCache_InvalidAnnotationSubjects(t *testing.T) {
+	cache := NewCache(zap.NewNop())
+
+	cache.upsert(&corev1.ServiceAccount{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "api",
+			Namespace: "payments",
+			Annotations: map[string]string{
+				"nats.io/allowed-pub-subjects": "foo..bar, foo.>.bar, a b, payments.orders, shared.>, shared.status, shared.>",
+			},
+		},
+	})
+
+	pubPerms, _, found := cache.Get("payments", "api")
+	if !found {
+		t.Fatal("Expected ServiceAccount to be in cache after upsert")
+	}
+
+	want := []string{"payments.>", "shared.>"}
+	if !equalStringSlices(pubPerms, want) {
+		t.Errorf("pubPerms = %v, want %v", pubPerms, want)
+	}
+}
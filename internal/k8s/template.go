@@ -0,0 +1,115 @@
+package k8s
+
+import (
+	"strings"
+)
+
+const (
+	// PlaceholderNamespace is replaced with the ServiceAccount namespace.
+	PlaceholderNamespace = "{{.Namespace}}"
+	// PlaceholderServiceAccount is replaced with the ServiceAccount name.
+	PlaceholderServiceAccount = "{{.ServiceAccount}}"
+	// PlaceholderPod is replaced with the pod name bound to the presented token.
+	// It can only be resolved at authorization time, so it is left in place by the cache.
+	PlaceholderPod = "{{.Pod}}"
+	// PlaceholderCluster is replaced with the configured cluster name.
+	PlaceholderCluster = "{{.Cluster}}"
+)
+
+// SharedInboxSubject is the shared inbox granted by default, which lets any subscriber
+// in the account see every other client's request-reply responses.
+const SharedInboxSubject = "_INBOX.>"
+
+// privateInboxTemplate is the per-ServiceAccount inbox granted in strict inbox mode.
+const privateInboxTemplate = "_INBOX_" + PlaceholderNamespace + "_" + PlaceholderServiceAccount + ".>"
+
+// DefaultPublishTemplates are the publish subjects granted to every ServiceAccount
+// when no templates are configured.
+var DefaultPublishTemplates = []string{
+	PlaceholderNamespace + ".>",
+}
+
+// DefaultSubscribeTemplates are the subscribe subjects granted to every ServiceAccount
+// when no templates are configured.
+//   - _INBOX.> for default convenience (works with standard NATS clients)
+//   - _INBOX_<namespace>_<serviceaccount>.> for private inbox pattern (enhanced security)
+//     Note: Uses underscore separators to prevent _INBOX.> from matching the private inbox
+//   - <namespace>.> for namespace scope
+var DefaultSubscribeTemplates = []string{
+	SharedInboxSubject,
+	privateInboxTemplate,
+	PlaceholderNamespace + ".>",
+}
+
+// templateData holds the values substituted into subject templates.
+type templateData struct {
+	Namespace      string
+	ServiceAccount string
+	Cluster        string
+}
+
+// renderSubjects substitutes the ServiceAccount-level placeholders in each subject.
+// {{.Pod}} is preserved for ExpandPodSubjects. Subjects that still contain an
+// unknown placeholder after substitution are returned separately as invalid.
+func renderSubjects(templates []string, data templateData) (subjects, invalid []string) {
+	replacer := strings.NewReplacer(
+		PlaceholderNamespace, data.Namespace,
+		PlaceholderServiceAccount, data.ServiceAccount,
+		PlaceholderCluster, data.Cluster,
+	)
+
+	subjects = make([]string, 0, len(templates))
+	for _, tmpl := range templates {
+		rendered := replacer.Replace(tmpl)
+		if strings.Contains(strings.ReplaceAll(rendered, PlaceholderPod, ""), "{{") {
+			invalid = append(invalid, tmpl)
+			continue
+		}
+		subjects = append(subjects, rendered)
+	}
+
+	return subjects, invalid
+}
+
+// ExpandPodSubjects substitutes {{.Pod}} with the given pod name in granted subjects.
+// Subjects that reference {{.Pod}} are dropped when pod is empty, since the
+// literal placeholder must never be granted, and when pod is not a single subject
+// token (pod names may contain dots), since the expanded subject would no longer have
+// the token structure that was validated and checked against the registry.
+func ExpandPodSubjects(subjects []string, pod string) []string {
+	return expandPodSubjects(subjects, pod, validPodToken(pod))
+}
+
+// ExpandPodDenySubjects substitutes {{.Pod}} with the given pod name in deny subjects.
+// Pod names cannot contain wildcards, so a pod name spanning several tokens still denies
+// only literal subjects and is substituted as is rather than dropping the deny entry.
+func ExpandPodDenySubjects(subjects []string, pod string) []string {
+	return expandPodSubjects(subjects, pod, pod != "")
+}
+
+// expandPodSubjects substitutes {{.Pod}} with pod, dropping subjects that reference it
+// unless usable is set.
+func expandPodSubjects(subjects []string, pod string, usable bool) []string {
+	if subjects == nil {
+		return nil
+	}
+
+	expanded := make([]string, 0, len(subjects))
+	for _, subject := range subjects {
+		if !strings.Contains(subject, PlaceholderPod) {
+			expanded = append(expanded, subject)
+			continue
+		}
+		if !usable {
+			continue
+		}
+		expanded = append(expanded, strings.ReplaceAll(subject, PlaceholderPod, pod))
+	}
+
+	return expanded
+}
+
+// validPodToken reports whether a pod name can replace {{.Pod}} as a single literal subject token.
+func validPodToken(pod string) bool {
+	return pod != "" && !strings.ContainsAny(pod, ".*> \t\r\n")
+}
@@ -0,0 +1,139 @@
+package k8s
+
+import (
+	"testing"
+
+	"go.uber.org/zap"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// TestRenderSubjects tests placeholder substitution in subject templates
+func TestRenderSubjects(t *testing.T) {
+	data := templateData{
+		Namespace:      "payments",
+		ServiceAccount: "api",
+		Cluster:        "eu-west-1",
+	}
+
+	tests := []struct {
+		name         string
+		templates    []string
+		wantSubjects []string
+		wantInvalid  []string
+	}{
+		{
+			name:         "Namespace and ServiceAccount placeholders",
+			templates:    []string{"tenant.{{.Namespace}}.{{.ServiceAccount}}.>"},
+			wantSubjects: []string{"tenant.payments.api.>"},
+		},
+		{
+			name:         "Cluster placeholder",
+			templates:    []string{"{{.Cluster}}.{{.Namespace}}.>"},
+			wantSubjects: []string{"eu-west-1.payments.>"},
+		},
+		{
+			name:         "Pod placeholder preserved for authorization time",
+			templates:    []string{"pods.{{.Pod}}.>"},
+			wantSubjects: []string{"pods.{{.Pod}}.>"},
+		},
+		{
+			name:         "Subjects without placeholders unchanged",
+			templates:    []string{"_INBOX.>", "shared.status"},
+			wantSubjects: []string{"_INBOX.>", "shared.status"},
+		},
+		{
+			name:         "Unknown placeholder rejected",
+			templates:    []string{"{{.Node}}.>", "ok.>"},
+			wantSubjects: []string{"ok.>"},
+			wantInvalid:  []string{"{{.Node}}.>"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gotSubjects, gotInvalid := renderSubjects(tt.templates, data)
+			if !equalStringSlices(gotSubjects, tt.wantSubjects) {
+				t.Errorf("renderSubjects() subjects = %v, want %v", gotSubjects, tt.wantSubjects)
+			}
+			if !equalStringSlices(gotInvalid, tt.wantInvalid) {
+				t.Errorf("renderSubjects() invalid = %v, want %v", gotInvalid, tt.wantInvalid)
+			}
+		})
+	}
+}
+
+// TestExpandPodSubjects tests resolving the pod placeholder at authorization time
+func TestExpandPodSubjects(t *testing.T) {
+	subjects := []string{"payments.>", "pods.{{.Pod}}.>"}
+
+	got := ExpandPodSubjects(subjects, "api-7f9c-abcde")
+	want := []string{"payments.>", "pods.api-7f9c-abcde.>"}
+	if !equalStringSlices(got, want) {
+		t.Errorf("ExpandPodSubjects() = %v, want %v", got, want)
+	}
+
+	// Tokens not bound to a pod must never be granted the literal placeholder
+	got = ExpandPodSubjects(subjects, "")
+	want = []string{"payments.>"}
+	if !equalStringSlices(got, want) {
+		t.Errorf("ExpandPodSubjects() without pod = %v, want %v", got, want)
+	}
+
+	// Pod names with dots would change the token structure of the granted subject
+	got = ExpandPodSubjects([]string{"payments.>", "svc.{{.Pod}}"}, "a.b")
+	want = []string{"payments.>"}
+	if !equalStringSlices(got, want) {
+		t.Errorf("ExpandPodSubjects() with dotted pod = %v, want %v", got, want)
+	}
+}
+
+// TestExpandPodDenySubjects tests that deny entries are kept for dotted pod names
+func TestExpandPodDenySubjects(t *testing.T) {
+	subjects := []string{"payments.admin", "svc.{{.Pod}}"}
+
+	got := ExpandPodDenySubjects(subjects, "a.b")
+	want := []string{"payments.admin", "svc.a.b"}
+	if !equalStringSlices(got, want) {
+		t.Errorf("ExpandPodDenySubjects() = %v, want %v", got, want)
+	}
+
+	got = ExpandPodDenySubjects(subjects, "")
+	want = []string{"payments.admin"}
+	if !equalStringSlices(got, want) {
+		t.Errorf("ExpandPodDenySubjects() without pod = %v, want %v", got, want)
+	}
+}
+
+// TestCache_CustomTemplates tests building permissions from configured default templates
+func TestCache_CustomTemplates(t *testing.T) {
+	cache := NewCacheWithOptions(zap.NewNop(), Options{
+		PublishTemplates:   []string{"tenant.{{.Namespace}}.{{.ServiceAccount}}.>"},
+		SubscribeTemplates: []string{"_INBOX_{{.Namespace}}_{{.ServiceAccount}}.>", "tenant.{{.Namespace}}.>"},
+	})
+
+	cache.upsert(&corev1.ServiceAccount{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "api",
+			Namespace: "payments",
+			Annotations: map[string]string{
+				"nats.io/allowed-pub-subjects": "svc.{{.ServiceAccount}}.>",
+			},
+		},
+	})
+
+	pubPerms, subPerms, found := cache.Get("payments", "api")
+	if !found {
+		t.Fatal("Expected ServiceAccount to be in cache after upsert")
+	}
+
+	wantPub := []string{"tenant.payments.api.>", "svc.api.>"}
+	if !equalStringSlices(pubPerms, wantPub) {
+		t.Errorf("pubPerms = %v, want %v", pubPerms, wantPub)
+	}
+
+	wantSub := []string{"_INBOX_payments_api.>", "tenant.payments.>"}
+	if !equalStringSlices(subPerms, wantSub) {
+		t.Errorf("subPerms = %v, want %v", subPerms, wantSub)
+	}
+}
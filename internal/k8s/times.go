@@ -0,0 +1,196 @@
+package k8s
+
+import (
+	"fmt"
+	"strings"
+	"time"
+	// Embed the timezone database so windows work in minimal container images
+	_ "time/tzdata"
+
+	"go.uber.org/zap"
+	corev1 "k8s.io/api/core/v1"
+)
+
+// AnnotationAllowedTimes restricts when a ServiceAccount may connect, as optional days, one or
+// more time ranges and an optional IANA timezone (default UTC), e.g. "Mon-Fri 08:00-18:00 Europe/London".
+const AnnotationAllowedTimes = "nats.io/allowed-times"
+
+// timeOfDayFormat is the time format used in NATS user claim time ranges
+const timeOfDayFormat = "15:04:05"
+
+// weekdays maps three-letter day names to weekdays
+var weekdays = map[string]time.Weekday{
+	"sun": time.Sunday,
+	"mon": time.Monday,
+	"tue": time.Tuesday,
+	"wed": time.Wednesday,
+	"thu": time.Thursday,
+	"fri": time.Friday,
+	"sat": time.Saturday,
+}
+
+// TimeRange is a daily connection window in "HH:MM:SS" form, matching NATS user claim time ranges.
+type TimeRange struct {
+	Start string
+	End   string
+}
+
+// AllowedTimes restricts connections to time windows on selected days.
+type AllowedTimes struct {
+	// Days the windows apply to; empty means every day.
+	Days []time.Weekday
+	// Ranges are the daily windows. Each range starts before it ends.
+	Ranges []TimeRange
+	// Location is the timezone the windows are evaluated in.
+	Location *time.Location
+}
+
+// ParseAllowedTimes parses a time window specification such as
+// "Mon-Fri 08:00-18:00 Europe/London" or "Sat,Sun 09:00-12:00,14:00-16:00".
+// Windows cannot cross midnight; split them into two ranges instead.
+func ParseAllowedTimes(value string) (*AllowedTimes, error) {
+	fields := strings.Fields(value)
+	if len(fields) == 0 {
+		return nil, fmt.Errorf("no time ranges specified")
+	}
+
+	allowed := &AllowedTimes{Location: time.UTC}
+
+	if days, err := parseDays(fields[0]); err == nil {
+		allowed.Days = days
+		fields = fields[1:]
+	}
+
+	if len(fields) > 0 && !strings.Contains(fields[len(fields)-1], ":") {
+		loc, err := time.LoadLocation(fields[len(fields)-1])
+		if err != nil {
+			return nil, fmt.Errorf("invalid timezone %q: %w", fields[len(fields)-1], err)
+		}
+		allowed.Location = loc
+		fields = fields[:len(fields)-1]
+	}
+
+	for _, field := range fields {
+		for _, part := range strings.Split(field, ",") {
+			if part == "" {
+				continue
+			}
+			r, err := parseTimeRange(part)
+			if err != nil {
+				return nil, err
+			}
+			allowed.Ranges = append(allowed.Ranges, r)
+		}
+	}
+	if len(allowed.Ranges) == 0 {
+		return nil, fmt.Errorf("no time ranges specified")
+	}
+
+	return allowed, nil
+}
+
+// Contains reports whether t falls inside one of the windows.
+func (a *AllowedTimes) Contains(t time.Time) bool {
+	t = t.In(a.Location)
+
+	if len(a.Days) > 0 {
+		dayAllowed := false
+		for _, day := range a.Days {
+			if day == t.Weekday() {
+				dayAllowed = true
+				break
+			}
+		}
+		if !dayAllowed {
+			return false
+		}
+	}
+
+	// Time ranges are zero-padded, so they compare correctly as strings
+	now := t.Format(timeOfDayFormat)
+	for _, r := range a.Ranges {
+		if now >= r.Start && now < r.End {
+			return true
+		}
+	}
+	return false
+}
+
+// parseDays parses a day specification such as "Mon-Fri", "Sat,Sun" or "Mon-Wed,Fri".
+func parseDays(value string) ([]time.Weekday, error) {
+	var days []time.Weekday
+	for _, part := range strings.Split(value, ",") {
+		first, last, isRange := strings.Cut(strings.ToLower(part), "-")
+
+		start, ok := weekdays[first]
+		if !ok {
+			return nil, fmt.Errorf("invalid day %q", first)
+		}
+		if !isRange {
+			days = append(days, start)
+			continue
+		}
+
+		end, ok := weekdays[last]
+		if !ok {
+			return nil, fmt.Errorf("invalid day %q", last)
+		}
+		for day := start; ; day = (day + 1) % 7 {
+			days = append(days, day)
+			if day == end {
+				break
+			}
+		}
+	}
+	return days, nil
+}
+
+// parseTimeRange parses a range such as "08:00-18:00" or "08:00:00-18:30:00".
+func parseTimeRange(value string) (TimeRange, error) {
+	startValue, endValue, ok := strings.Cut(value, "-")
+	if !ok {
+		return TimeRange{}, fmt.Errorf("invalid time range %q: expected HH:MM-HH:MM", value)
+	}
+
+	start, err := parseTimeOfDay(startValue)
+	if err != nil {
+		return TimeRange{}, fmt.Errorf("invalid time range %q: %w", value, err)
+	}
+	end, err := parseTimeOfDay(endValue)
+	if err != nil {
+		return TimeRange{}, fmt.Errorf("invalid time range %q: %w", value, err)
+	}
+	if start >= end {
+		return TimeRange{}, fmt.Errorf("invalid time range %q: start must be before end", value)
+	}
+
+	return TimeRange{Start: start, End: end}, nil
+}
+
+// parseTimeOfDay normalizes "HH:MM" or "HH:MM:SS" to "HH:MM:SS".
+func parseTimeOfDay(value string) (string, error) {
+	for _, layout := range []string{"15:04", timeOfDayFormat} {
+		if t, err := time.Parse(layout, value); err == nil {
+			return t.Format(timeOfDayFormat), nil
+		}
+	}
+	return "", fmt.Errorf("invalid time %q", value)
+}
+
+// buildAllowedTimes parses the allowed times annotation for a ServiceAccount.
+// Returns nil when the ServiceAccount may connect at any time. An invalid annotation is logged
+// and denies all connections rather than silently lifting the restriction.
+func buildAllowedTimes(sa *corev1.ServiceAccount, logger *zap.Logger) *AllowedTimes {
+	value, ok := sa.Annotations[AnnotationAllowedTimes]
+	if !ok {
+		return nil
+	}
+
+	allowed, err := ParseAllowedTimes(value)
+	if err != nil {
+		logInvalidAnnotation(sa, AnnotationAllowedTimes, value, err, logger)
+		return &AllowedTimes{Location: time.UTC}
+	}
+
+	return allowed
+}
@@ -0,0 +1,133 @@
+package k8s
+
+import (
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// TestParseAllowedTimes tests parsing time window specifications
+func TestParseAllowedTimes(t *testing.T) {
+	tests := []struct {
+		name         string
+		value        string
+		wantDays     []time.Weekday
+		wantRanges   []TimeRange
+		wantLocation string
+		wantErr      bool
+	}{
+		{
+			name:         "Weekdays with timezone",
+			value:        "Mon-Fri 08:00-18:00 Europe/London",
+			wantDays:     []time.Weekday{time.Monday, time.Tuesday, time.Wednesday, time.Thursday, time.Friday},
+			wantRanges:   []TimeRange{{Start: "08:00:00", End: "18:00:00"}},
+			wantLocation: "Europe/London",
+		},
+		{
+			name:         "Every day in UTC",
+			value:        "09:00-12:00,13:00:30-17:00",
+			wantRanges:   []TimeRange{{Start: "09:00:00", End: "12:00:00"}, {Start: "13:00:30", End: "17:00:00"}},
+			wantLocation: "UTC",
+		},
+		{
+			name:         "Day list and wrapping day range",
+			value:        "Fri-Sun,Wed 22:00-23:59",
+			wantDays:     []time.Weekday{time.Friday, time.Saturday, time.Sunday, time.Wednesday},
+			wantRanges:   []TimeRange{{Start: "22:00:00", End: "23:59:00"}},
+			wantLocation: "UTC",
+		},
+		{name: "Empty", value: "", wantErr: true},
+		{name: "Days only", value: "Mon-Fri", wantErr: true},
+		{name: "Crosses midnight", value: "22:00-06:00", wantErr: true},
+		{name: "Invalid time", value: "08:00-25:00", wantErr: true},
+		{name: "Invalid timezone", value: "08:00-18:00 Mars/Olympus", wantErr: true},
+		{name: "Invalid day", value: "Mon-Fry 08:00-18:00", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseAllowedTimes(tt.value)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseAllowedTimes() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+
+			if len(got.Days) != len(tt.wantDays) {
+				t.Fatalf("Days = %v, want %v", got.Days, tt.wantDays)
+			}
+			for i := range got.Days {
+				if got.Days[i] != tt.wantDays[i] {
+					t.Errorf("Days = %v, want %v", got.Days, tt.wantDays)
+				}
+			}
+			if len(got.Ranges) != len(tt.wantRanges) {
+				t.Fatalf("Ranges = %v, want %v", got.Ranges, tt.wantRanges)
+			}
+			for i := range got.Ranges {
+				if got.Ranges[i] != tt.wantRanges[i] {
+					t.Errorf("Ranges = %v, want %v", got.Ranges, tt.wantRanges)
+				}
+			}
+			if got.Location.String() != tt.wantLocation {
+				t.Errorf("Location = %v, want %v", got.Location, tt.wantLocation)
+			}
+		})
+	}
+}
+
+// TestAllowedTimes_Contains tests evaluating time windows in their timezone
+func TestAllowedTimes_Contains(t *testing.T) {
+	allowed, err := ParseAllowedTimes("Mon-Fri 08:00-18:00 Europe/London")
+	if err != nil {
+		t.Fatalf("Failed to parse allowed times: %v", err)
+	}
+
+	tests := []struct {
+		name string
+		time time.Time
+		want bool
+	}{
+		// 2026-07-15 is a Wednesday; London is UTC+1 in summer
+		{name: "Inside window", time: time.Date(2026, 7, 15, 12, 0, 0, 0, time.UTC), want: true},
+		{name: "Window start in local time", time: time.Date(2026, 7, 15, 7, 0, 0, 0, time.UTC), want: true},
+		{name: "Before window in local time", time: time.Date(2026, 7, 15, 6, 59, 0, 0, time.UTC), want: false},
+		{name: "Window end is exclusive", time: time.Date(2026, 7, 15, 17, 0, 0, 0, time.UTC), want: false},
+		{name: "Weekend", time: time.Date(2026, 7, 18, 12, 0, 0, 0, time.UTC), want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := allowed.Contains(tt.time); got != tt.want {
+				t.Errorf("Contains(%v) = %v, want %v", tt.time, got, tt.want)
+			}
+		})
+	}
+}
+
+// TestCache_InvalidAllowedTimesDeniesAll tests that an invalid window never lifts the restriction
+func TestCache_InvalidAllowedTimesDeniesAll(t *testing.T) {
+	cache := NewCache(zap.NewNop())
+	cache.upsert(&corev1.ServiceAccount{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        "batch",
+			Namespace:   "reports",
+			Annotations: map[string]string{"nats.io/allowed-times": "22:00-06:00"},
+		},
+	})
+
+	perms, found := cache.Lookup("reports", "batch")
+	if !found {
+		t.Fatal("Expected ServiceAccount to be in cache after upsert")
+	}
+	if perms.AllowedTimes == nil {
+		t.Fatal("AllowedTimes = nil, want a restriction that denies all connections")
+	}
+	if perms.AllowedTimes.Contains(time.Now()) {
+		t.Error("Expected invalid allowed times to deny all connections")
+	}
+}
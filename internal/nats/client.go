@@ -16,7 +16,9 @@ import (
 	"github.com/nats-io/nkeys"
 	"github.com/synadia-io/callout.go"
 	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
 
+	"github.com/portswigger-tim/nats-k8s-oidc-callout/internal/audit"
 	"github.com/portswigger-tim/nats-k8s-oidc-callout/internal/auth"
 	"github.com/portswigger-tim/nats-k8s-oidc-callout/internal/logging"
 )
@@ -41,6 +43,8 @@ type Client struct {
 	conn        *natsclient.Conn
 	service     *callout.AuthorizationService
 	signingKey  nkeys.KeyPair
+	tracker     *ConnectionTracker // nil disables connection tracking
+	auditors    []audit.Recorder
 	logger      *zap.Logger
 }
 
@@ -106,6 +110,21 @@ func (c *Client) SetSigningKey(key nkeys.KeyPair) {
 	c.signingKey = key
 }
 
+// SetConnectionTracker records every authorized connection in tracker
+func (c *Client) SetConnectionTracker(tracker *ConnectionTracker) {
+	c.tracker = tracker
+}
+
+// AddAuditRecorder sends an audit event for every authorization decision to recorder
+func (c *Client) AddAuditRecorder(recorder audit.Recorder) {
+	c.auditors = append(c.auditors, recorder)
+}
+
+// Conn returns the NATS connection, or nil before Start
+func (c *Client) Conn() *natsclient.Conn {
+	return c.conn
+}
+
 // Start connects to NATS and starts the auth callout service
 func (c *Client) Start(ctx context.Context) error {
 	// Verify signing key is set
@@ -135,97 +154,200 @@ func (c *Client) Start(ctx context.Context) error {
 	}
 	c.conn = conn
 
-	// Create authorizer function that bridges NATS and our auth handler
-	authorizer := func(req *jwt.AuthorizationRequest) (string, error) {
-		// Extract JWT token from request
-		// The token is provided by the client in the connection options
-		// For now, we'll extract it from the ConnectOptions if available
-		token := c.extractToken(req)
-
-		if token == "" {
-			// Reject requests without a token by not returning a JWT
-			// This causes the connection to timeout
-			c.logger.Debug("auth request rejected: no token provided",
-				zap.String("user_nkey", req.UserNkey))
-			return "", fmt.Errorf("no token provided")
-		}
+	// Create auth callout service
+	service, err := callout.NewAuthorizationService(
+		conn,
+		callout.Authorizer(c.authorize),
+		callout.ResponseSignerKey(c.signingKey),
+	)
+	if err != nil {
+		conn.Close()
+		return fmt.Errorf("failed to create authorization service: %w", err)
+	}
 
-		// Call our auth handler
-		authReq := &auth.AuthRequest{
-			Token: token,
-		}
+	c.service = service
+	return nil
+}
 
-		c.logger.Debug("calling auth handler with token")
-		authResp := c.authHandler.Authorize(authReq)
+// authorize bridges an auth callout request to the auth handler and returns the encoded user JWT.
+// Requests without a token are passed on too, so they are rate limited and counted as token failures.
+func (c *Client) authorize(req *jwt.AuthorizationRequest) (string, error) {
+	// Extract JWT token from request
+	// The token is provided by the client in the connection options
+	// For now, we'll extract it from the ConnectOptions if available
+	token := c.extractToken(req)
+
+	// Call our auth handler, which also denies missing tokens
+	authReq := &auth.AuthRequest{
+		Token:          token,
+		ClientHost:     req.ClientInformation.Host,
+		ConnectionType: connectionType(req.ClientInformation),
+	}
 
-		c.logger.Debug("auth handler response",
-			zap.Bool("allowed", authResp.Allowed),
-			zap.Strings("publish_permissions", authResp.PublishPermissions),
-			zap.Strings("subscribe_permissions", authResp.SubscribePermissions))
+	c.logger.Debug("calling auth handler with token")
+	authResp := c.authHandler.Authorize(authReq)
+
+	c.logger.Debug("auth handler response",
+		zap.Bool("allowed", authResp.Allowed),
+		zap.Strings("publish_permissions", authResp.PublishPermissions),
+		zap.Strings("subscribe_permissions", authResp.SubscribePermissions))
+
+	// If denied, reject by not returning a JWT
+	if !authResp.Allowed {
+		c.logger.Log(denialLogLevel(authResp.Reason), "auth request denied",
+			zap.String("user_nkey", req.UserNkey),
+			zap.String("client_host", req.ClientInformation.Host),
+			zap.String("connection_type", connectionType(req.ClientInformation)),
+			zap.String("reason", authResp.Reason),
+			zap.String("detail", authResp.Detail))
+		c.recordAudit(req, token, authResp, nil)
+		return "", fmt.Errorf("authorization failed")
+	}
 
-		// If denied, reject by not returning a JWT
-		if !authResp.Allowed {
-			c.logger.Debug("auth request denied",
-				zap.String("user_nkey", req.UserNkey))
-			return "", fmt.Errorf("authorization failed")
-		}
+	// Build NATS user claims
+	uc := c.buildUserClaims(req.UserNkey, authResp)
+
+	c.logger.Debug("built user claims",
+		zap.String("subject", uc.Subject),
+		zap.String("audience", uc.Audience),
+		zap.Any("pub_allow", uc.Pub.Allow),
+		zap.Any("sub_allow", uc.Sub.Allow),
+		zap.Any("pub_deny", uc.Pub.Deny),
+		zap.Any("sub_deny", uc.Sub.Deny),
+		zap.Any("resp", uc.Resp),
+		zap.Strings("src", uc.Src),
+		zap.Strings("connection_types", uc.AllowedConnectionTypes),
+		zap.Any("times", uc.Times),
+		zap.Int64("expires", uc.Expires))
+
+	// Encode and return JWT
+	encodedJWT, err := uc.Encode(c.signingKey)
+	if err != nil {
+		c.logger.Error("failed to encode auth response JWT",
+			zap.Error(err),
+			zap.String("user_nkey", req.UserNkey))
+		return "", err
+	}
 
-		// Build NATS user claims
-		uc := jwt.NewUserClaims(req.UserNkey)
+	c.logger.Debug("encoded auth response JWT",
+		zap.Int("jwt_length", len(encodedJWT)))
 
-		// Set the audience to the configured NATS account
-		// This enables multi-tenancy by assigning clients to specific accounts
-		uc.Audience = c.account
+	// Remember where the client connected so it can be disconnected when its permissions change
+	if c.tracker != nil {
+		c.tracker.Track(authResp.Namespace, authResp.ServiceAccount, req.Server.ID, req.ClientInformation.ID)
+	}
+	c.recordAudit(req, token, authResp, uc)
 
-		uc.Pub.Allow.Add(authResp.PublishPermissions...)
-		uc.Sub.Allow.Add(authResp.SubscribePermissions...)
+	return encodedJWT, nil
+}
 
-		// Enable response permissions (equivalent to allow_responses: true)
-		// This allows responders to publish to reply subjects during request handling
-		// MaxMsgs: 1 = allow one response per request (NATS default)
-		// Expires: 0 = no time limit
-		uc.Resp = &jwt.ResponsePermission{
-			MaxMsgs: 1,
-			Expires: 0,
-		}
+// denialLogLevel returns the level for logging a denial. Policy denials an operator acts on are
+// logged at Info; routine token and permission failures stay at Debug.
+func denialLogLevel(reason string) zapcore.Level {
+	switch reason {
+	case auth.ReasonLockdown, auth.ReasonTokenRevoked, auth.ReasonRateLimited:
+		return zapcore.InfoLevel
+	default:
+		return zapcore.DebugLevel
+	}
+}
 
-		uc.Expires = time.Now().Add(DefaultTokenExpiry).Unix()
+// recordAudit sends an audit event for an authorization decision to every recorder.
+// uc holds the issued user claims and is nil for denials.
+func (c *Client) recordAudit(req *jwt.AuthorizationRequest, token string, authResp *auth.AuthResponse, uc *jwt.UserClaims) {
+	if len(c.auditors) == 0 {
+		return
+	}
 
-		c.logger.Debug("built user claims",
-			zap.String("subject", uc.Subject),
-			zap.String("audience", uc.Audience),
-			zap.Any("pub_allow", uc.Pub.Allow),
-			zap.Any("sub_allow", uc.Sub.Allow),
-			zap.Int64("expires", uc.Expires))
+	event := audit.Event{
+		Timestamp:      time.Now().UTC(),
+		Namespace:      authResp.Namespace,
+		ServiceAccount: authResp.ServiceAccount,
+		Pod:            authResp.Pod,
+		ClientIP:       req.ClientInformation.Host,
+		ConnectionType: connectionType(req.ClientInformation),
+		ServerID:       req.Server.ID,
+		Result:         audit.ResultDenied,
+		Reason:         authResp.Reason,
+		Detail:         authResp.Detail,
+		TokenID:        authResp.TokenID,
+		Token:          token,
+	}
+	if uc != nil {
+		event.Result = audit.ResultAllowed
+		event.PublishGranted = uc.Pub.Allow
+		event.SubscribeGranted = uc.Sub.Allow
+	}
 
-		// Encode and return JWT
-		encodedJWT, err := uc.Encode(c.signingKey)
-		if err != nil {
-			c.logger.Error("failed to encode auth response JWT",
-				zap.Error(err),
-				zap.String("user_nkey", req.UserNkey))
-			return "", err
+	for _, recorder := range c.auditors {
+		recorder.Record(event)
+	}
+}
+
+// buildUserClaims builds the NATS user claims for an allowed authorization response
+func (c *Client) buildUserClaims(userNkey string, authResp *auth.AuthResponse) *jwt.UserClaims {
+	uc := jwt.NewUserClaims(userNkey)
+
+	// Set the audience to the configured NATS account
+	// This enables multi-tenancy by assigning clients to specific accounts
+	uc.Audience = c.account
+
+	// Identify the ServiceAccount so its connections can be found through the system account
+	if authResp.ServiceAccount != "" {
+		uc.Name = authResp.Namespace + "/" + authResp.ServiceAccount
+		uc.Tags.Add(serviceAccountTags(authResp.Namespace, authResp.ServiceAccount)...)
+		if authResp.Pod != "" {
+			uc.Tags.Add(TagPod + ":" + authResp.Pod)
 		}
+	}
 
-		c.logger.Debug("encoded auth response JWT",
-			zap.Int("jwt_length", len(encodedJWT)))
+	uc.Pub.Allow.Add(authResp.PublishPermissions...)
+	uc.Sub.Allow.Add(authResp.SubscribePermissions...)
+	uc.Pub.Deny.Add(authResp.PublishDeny...)
+	uc.Sub.Deny.Add(authResp.SubscribeDeny...)
 
-		return encodedJWT, nil
+	// Enable response permissions (equivalent to allow_responses) unless disabled for this client
+	// This allows responders to publish to reply subjects during request handling
+	// MaxMsgs: responses allowed per request (-1 = unlimited)
+	// Expires: time allowed to respond (0 = NATS server default)
+	if authResp.ResponsePermission != nil {
+		uc.Resp = &jwt.ResponsePermission{
+			MaxMsgs: authResp.ResponsePermission.MaxMsgs,
+			Expires: authResp.ResponsePermission.Expires,
+		}
 	}
 
-	// Create auth callout service
-	service, err := callout.NewAuthorizationService(
-		conn,
-		callout.Authorizer(authorizer),
-		callout.ResponseSignerKey(c.signingKey),
-	)
-	if err != nil {
-		conn.Close()
-		return fmt.Errorf("failed to create authorization service: %w", err)
+	// Pin the client to its allowed source networks so the server enforces them too
+	uc.Src.Add(authResp.SourceCIDRs...)
+
+	// Restrict the connection types the server accepts with these claims
+	uc.AllowedConnectionTypes.Add(authResp.ConnectionTypes...)
+
+	// Time windows let the server disconnect the client when its window closes.
+	// Days are not expressible in user claims and are only checked at authorization time.
+	if authResp.AllowedTimes != nil {
+		for _, r := range authResp.AllowedTimes.Ranges {
+			uc.Times = append(uc.Times, jwt.TimeRange{Start: r.Start, End: r.End})
+		}
+		uc.Locale = authResp.AllowedTimes.Location.String()
 	}
 
-	c.service = service
-	return nil
+	// Per-connection limits; unset limits keep the unlimited defaults
+	if authResp.Limits != nil {
+		if authResp.Limits.Subs != nil {
+			uc.Limits.Subs = *authResp.Limits.Subs
+		}
+		if authResp.Limits.Payload != nil {
+			uc.Limits.Payload = *authResp.Limits.Payload
+		}
+		if authResp.Limits.Data != nil {
+			uc.Limits.Data = *authResp.Limits.Data
+		}
+	}
+
+	uc.Expires = time.Now().Add(DefaultTokenExpiry).Unix()
+
+	return uc
 }
 
 // configureAuthentication configures NATS connection authentication options based on the configured method.
@@ -275,13 +397,21 @@ func (c *Client) configureAuthentication() ([]natsclient.Option, error) {
 	return opts, nil
 }
 
+// StopService stops answering auth requests while keeping the NATS connection open, so audit
+// events for the last decisions can still be published on it. Shutdown calls it if needed.
+func (c *Client) StopService() {
+	if c.service == nil {
+		return
+	}
+	if err := c.service.Stop(); err != nil {
+		c.logger.Error("failed to stop NATS service", zap.Error(err))
+	}
+	c.service = nil
+}
+
 // Shutdown gracefully shuts down the client
 func (c *Client) Shutdown(ctx context.Context) error {
-	if c.service != nil {
-		if err := c.service.Stop(); err != nil {
-			c.logger.Error("failed to stop NATS service", zap.Error(err))
-		}
-	}
+	c.StopService()
 
 	if c.conn != nil {
 		c.conn.Close()
@@ -422,6 +552,7 @@ func (c *Client) extractToken(req *jwt.AuthorizationRequest) string {
 	c.logger.Debug("extracting token from auth request",
 		zap.String("jwt_field", logging.RedactJWT(req.ConnectOptions.JWT)),
 		zap.String("token_field", logging.RedactJWT(req.ConnectOptions.Token)),
+		zap.String("client_type", req.ClientInformation.Type),
 		zap.String("username", req.ConnectOptions.Username))
 
 	// Check for JWT in connect options (standard field)
@@ -436,6 +567,31 @@ func (c *Client) extractToken(req *jwt.AuthorizationRequest) string {
 		return req.ConnectOptions.Token
 	}
 
+	// MQTT clients have no token field, so the token is sent as the CONNECT password
+	if connectionType(req.ClientInformation) == jwt.ConnectionTypeMqtt && req.ConnectOptions.Password != "" {
+		c.logger.Debug("token found in MQTT password field")
+		return req.ConnectOptions.Password
+	}
+
 	c.logger.Debug("no token found in auth request")
 	return ""
 }
+
+// connectionType maps the client information in an authorization request to a NATS
+// connection type as used in user claims. Returns an empty string for unknown clients.
+func connectionType(ci jwt.ClientInformation) string {
+	if ci.Kind == "Leafnode" {
+		return jwt.ConnectionTypeLeafnode
+	}
+
+	switch ci.Type {
+	case "nats":
+		return jwt.ConnectionTypeStandard
+	case "websocket":
+		return jwt.ConnectionTypeWebsocket
+	case "mqtt":
+		return jwt.ConnectionTypeMqtt
+	default:
+		return ""
+	}
+}
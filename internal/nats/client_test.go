@@ -3,15 +3,20 @@ package nats
 import (
 	"context"
 	"os"
+	"slices"
 	"strings"
 	"testing"
 	"time"
 
 	"github.com/nats-io/jwt/v2"
+	natsclient "github.com/nats-io/nats.go"
 	"github.com/nats-io/nkeys"
 	"go.uber.org/zap"
+	"go.uber.org/zap/zaptest/observer"
 
+	"github.com/portswigger-tim/nats-k8s-oidc-callout/internal/audit"
 	internalAuth "github.com/portswigger-tim/nats-k8s-oidc-callout/internal/auth"
+	"github.com/portswigger-tim/nats-k8s-oidc-callout/internal/k8s"
 )
 
 // Mock auth handler for testing
@@ -123,6 +128,65 @@ func TestClient_Shutdown(t *testing.T) {
 	}
 }
 
+// TestClient_StopService tests that stopping the callout service keeps the connection open, so
+// buffered audit events can still be published before Shutdown closes it
+func TestClient_StopService(t *testing.T) {
+	conn := startTestServer(t)
+	received := make(chan *natsclient.Msg, 1)
+	if _, err := conn.ChanSubscribe("audit.events", received); err != nil {
+		t.Fatalf("Failed to subscribe to audit events: %v", err)
+	}
+	if err := conn.Flush(); err != nil {
+		t.Fatalf("Failed to flush subscription: %v", err)
+	}
+
+	authHandler := &mockAuthHandler{
+		authorizeFunc: func(req *internalAuth.AuthRequest) *internalAuth.AuthResponse {
+			return &internalAuth.AuthResponse{Allowed: false}
+		},
+	}
+	client, err := NewClient(conn.ConnectedUrl(), "", "", "$G", authHandler, zap.NewNop())
+	if err != nil {
+		t.Fatalf("Failed to create client: %v", err)
+	}
+	signingKey, err := nkeys.CreateAccount()
+	if err != nil {
+		t.Fatalf("Failed to create signing key: %v", err)
+	}
+	client.SetSigningKey(signingKey)
+	if err := client.Start(context.Background()); err != nil {
+		t.Fatalf("Start() error = %v", err)
+	}
+
+	publisher := audit.NewPublisher("audit.events", "", audit.DefaultBufferSize, zap.NewNop())
+	if err := publisher.Start(client.Conn()); err != nil {
+		t.Fatalf("Failed to start audit publisher: %v", err)
+	}
+
+	client.StopService()
+	if !client.Conn().IsConnected() {
+		t.Fatal("Expected the connection to stay open after StopService")
+	}
+	publisher.Record(audit.Event{Result: audit.ResultDenied, Reason: internalAuth.ReasonLockdown})
+	publisher.Stop()
+
+	if err := client.Shutdown(context.Background()); err != nil {
+		t.Fatalf("Shutdown() error = %v", err)
+	}
+	if !client.Conn().IsClosed() {
+		t.Error("Expected the connection to be closed after Shutdown")
+	}
+
+	select {
+	case msg := <-received:
+		if !strings.Contains(string(msg.Data), internalAuth.ReasonLockdown) {
+			t.Errorf("Audit event = %s, want the buffered denial", msg.Data)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("Expected the buffered audit event to be published before the connection closed")
+	}
+}
+
 // TestExtractToken tests JWT token extraction from authorization requests
 func TestExtractToken(t *testing.T) {
 	tests := []struct {
@@ -158,6 +222,28 @@ func TestExtractToken(t *testing.T) {
 			},
 			wantJWT: "eyJhbGciOiJSUzI1NiIsInR5cCI6IkpXVCJ9.jwt.field",
 		},
+		{
+			name: "Token in MQTT password field",
+			request: &jwt.AuthorizationRequest{
+				ClientInformation: jwt.ClientInformation{Kind: "Client", Type: "mqtt"},
+				ConnectOptions: jwt.ConnectOptions{
+					Username: "sensor",
+					Password: "eyJhbGciOiJSUzI1NiIsInR5cCI6IkpXVCJ9.mqtt.password",
+				},
+			},
+			wantJWT: "eyJhbGciOiJSUzI1NiIsInR5cCI6IkpXVCJ9.mqtt.password",
+		},
+		{
+			name: "Password ignored for standard clients",
+			request: &jwt.AuthorizationRequest{
+				ClientInformation: jwt.ClientInformation{Kind: "Client", Type: "nats"},
+				ConnectOptions: jwt.ConnectOptions{
+					Username: "user",
+					Password: "eyJhbGciOiJSUzI1NiIsInR5cCI6IkpXVCJ9.nats.password",
+				},
+			},
+			wantJWT: "",
+		},
 		{
 			name: "Empty when no token provided",
 			request: &jwt.AuthorizationRequest{
@@ -222,8 +308,7 @@ func TestClient_AuthorizerFunction(t *testing.T) {
 			name:  "Empty token rejected",
 			token: "",
 			authHandler: func(req *internalAuth.AuthRequest) *internalAuth.AuthResponse {
-				t.Error("Auth handler should not be called with empty token")
-				return &internalAuth.AuthResponse{Allowed: false}
+				return &internalAuth.AuthResponse{Allowed: false, Reason: internalAuth.ReasonMissingToken}
 			},
 			wantError:  true,
 			wantPubLen: 0,
@@ -265,14 +350,6 @@ func TestClient_AuthorizerFunction(t *testing.T) {
 			// Call the internal authorizer logic (simulate)
 			token := client.extractToken(req)
 
-			if token == "" {
-				// Should be rejected
-				if !tt.wantError {
-					t.Error("Expected success but got empty token")
-				}
-				return
-			}
-
 			authReq := &internalAuth.AuthRequest{Token: token}
 			authResp := authHandler.Authorize(authReq)
 
@@ -457,6 +534,394 @@ func TestClient_PermissionsMapping(t *testing.T) {
 	}
 }
 
+// TestClient_BuildUserClaimsResponsePermission tests mapping response permissions to user claims
+func TestClient_BuildUserClaimsResponsePermission(t *testing.T) {
+	userKey, _ := nkeys.CreateUser()
+	userPubKey, _ := userKey.PublicKey()
+
+	tests := []struct {
+		name     string
+		response *k8s.ResponsePermission
+		wantResp *jwt.ResponsePermission
+	}{
+		{
+			name:     "Default single response",
+			response: &k8s.ResponsePermission{MaxMsgs: 1},
+			wantResp: &jwt.ResponsePermission{MaxMsgs: 1, Expires: 0},
+		},
+		{
+			name:     "Streaming responder with TTL",
+			response: &k8s.ResponsePermission{MaxMsgs: -1, Expires: 5 * time.Second},
+			wantResp: &jwt.ResponsePermission{MaxMsgs: -1, Expires: 5 * time.Second},
+		},
+		{
+			name:     "Responses disabled",
+			response: nil,
+			wantResp: nil,
+		},
+	}
+
+	client := &Client{account: "APP", logger: zap.NewNop()}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			uc := client.buildUserClaims(userPubKey, &internalAuth.AuthResponse{
+				Allowed:              true,
+				PublishPermissions:   []string{"test.>"},
+				SubscribePermissions: []string{"test.>"},
+				ResponsePermission:   tt.response,
+			})
+
+			if uc.Audience != "APP" {
+				t.Errorf("Audience = %q, want %q", uc.Audience, "APP")
+			}
+
+			if tt.wantResp == nil {
+				if uc.Resp != nil {
+					t.Errorf("Resp = %+v, want nil", uc.Resp)
+				}
+				return
+			}
+
+			if uc.Resp == nil {
+				t.Fatal("Expected response permission to be set")
+			}
+			if *uc.Resp != *tt.wantResp {
+				t.Errorf("Resp = %+v, want %+v", *uc.Resp, *tt.wantResp)
+			}
+		})
+	}
+}
+
+// TestClient_BuildUserClaimsRestrictions tests pinning user claims to allowed source networks and connection types
+func TestClient_BuildUserClaimsRestrictions(t *testing.T) {
+	userKey, _ := nkeys.CreateUser()
+	userPubKey, _ := userKey.PublicKey()
+
+	client := &Client{account: "APP", logger: zap.NewNop()}
+
+	uc := client.buildUserClaims(userPubKey, &internalAuth.AuthResponse{
+		Allowed:         true,
+		SourceCIDRs:     []string{"10.244.0.0/16", "fd00::/64"},
+		ConnectionTypes: []string{jwt.ConnectionTypeStandard},
+	})
+	if !uc.Src.Contains("10.244.0.0/16") || !uc.Src.Contains("fd00::/64") || len(uc.Src) != 2 {
+		t.Errorf("Src = %v, want [10.244.0.0/16 fd00::/64]", uc.Src)
+	}
+	if !uc.AllowedConnectionTypes.Contains(jwt.ConnectionTypeStandard) || len(uc.AllowedConnectionTypes) != 1 {
+		t.Errorf("AllowedConnectionTypes = %v, want [STANDARD]", uc.AllowedConnectionTypes)
+	}
+
+	uc = client.buildUserClaims(userPubKey, &internalAuth.AuthResponse{Allowed: true})
+	if len(uc.Src) != 0 {
+		t.Errorf("Src = %v, want empty for unrestricted clients", uc.Src)
+	}
+}
+
+// TestClient_BuildUserClaimsQueueSubscriptions tests that queue subscriptions are granted as "subject queue" entries
+func TestClient_BuildUserClaimsQueueSubscriptions(t *testing.T) {
+	userKey, _ := nkeys.CreateUser()
+	userPubKey, _ := userKey.PublicKey()
+
+	client := &Client{account: "APP", logger: zap.NewNop()}
+	uc := client.buildUserClaims(userPubKey, &internalAuth.AuthResponse{
+		Allowed:              true,
+		SubscribePermissions: []string{"_INBOX.>", "orders.> workers"},
+	})
+
+	if !uc.Sub.Allow.Contains("orders.> workers") {
+		t.Errorf("Sub.Allow = %v, want it to contain %q", uc.Sub.Allow, "orders.> workers")
+	}
+
+	vr := jwt.CreateValidationResults()
+	uc.Validate(vr)
+	if !vr.IsEmpty() {
+		t.Errorf("Expected valid user claims, got %v", vr.Errors())
+	}
+}
+
+// TestClient_BuildUserClaimsDenyAndLimits tests mapping deny lists and connection limits to user claims
+func TestClient_BuildUserClaimsDenyAndLimits(t *testing.T) {
+	userKey, _ := nkeys.CreateUser()
+	userPubKey, _ := userKey.PublicKey()
+
+	subs := int64(100)
+	client := &Client{account: "APP", logger: zap.NewNop()}
+	uc := client.buildUserClaims(userPubKey, &internalAuth.AuthResponse{
+		Allowed:              true,
+		PublishPermissions:   []string{"shop.>"},
+		SubscribePermissions: []string{"shop.>"},
+		PublishDeny:          []string{"shop.admin.>"},
+		SubscribeDeny:        []string{"shop.secrets.>"},
+		Limits:               &k8s.Limits{Subs: &subs},
+	})
+
+	if !uc.Pub.Deny.Contains("shop.admin.>") || len(uc.Pub.Deny) != 1 {
+		t.Errorf("Pub.Deny = %v, want [shop.admin.>]", uc.Pub.Deny)
+	}
+	if !uc.Sub.Deny.Contains("shop.secrets.>") || len(uc.Sub.Deny) != 1 {
+		t.Errorf("Sub.Deny = %v, want [shop.secrets.>]", uc.Sub.Deny)
+	}
+	if uc.Limits.Subs != 100 {
+		t.Errorf("Limits.Subs = %d, want 100", uc.Limits.Subs)
+	}
+	if uc.Limits.Payload != jwt.NoLimit || uc.Limits.Data != jwt.NoLimit {
+		t.Errorf("Limits.Payload = %d, Limits.Data = %d, want unlimited", uc.Limits.Payload, uc.Limits.Data)
+	}
+}
+
+// TestClient_BuildUserClaimsServiceAccountTags tests identifying the ServiceAccount in user claims
+func TestClient_BuildUserClaimsServiceAccountTags(t *testing.T) {
+	userKey, _ := nkeys.CreateUser()
+	userPubKey, _ := userKey.PublicKey()
+
+	client := &Client{account: "APP", logger: zap.NewNop()}
+	uc := client.buildUserClaims(userPubKey, &internalAuth.AuthResponse{
+		Allowed:        true,
+		Namespace:      "shop",
+		ServiceAccount: "api",
+		Pod:            "api-7d9f-x2",
+	})
+
+	if uc.Name != "shop/api" {
+		t.Errorf("Name = %q, want %q", uc.Name, "shop/api")
+	}
+	if !uc.Tags.Contains("nats.io/namespace:shop") || !uc.Tags.Contains("nats.io/serviceaccount:api") {
+		t.Errorf("Tags = %v, want namespace and serviceaccount tags", uc.Tags)
+	}
+	if !uc.Tags.Contains("nats.io/pod:api-7d9f-x2") {
+		t.Errorf("Tags = %v, want pod tag", uc.Tags)
+	}
+}
+
+// recordingAuditor collects audit events for tests
+type recordingAuditor struct {
+	events []audit.Event
+}
+
+func (r *recordingAuditor) Record(event audit.Event) {
+	r.events = append(r.events, event)
+}
+
+// TestClient_AuthorizeMissingToken tests that requests without a token reach the auth handler,
+// so they are audited, counted and rate limited like other token failures
+func TestClient_AuthorizeMissingToken(t *testing.T) {
+	req := &jwt.AuthorizationRequest{}
+	req.Server.ID = "SERVER1"
+	req.ClientInformation.Host = "10.0.0.7"
+
+	var got *internalAuth.AuthRequest
+	authHandler := &mockAuthHandler{
+		authorizeFunc: func(req *internalAuth.AuthRequest) *internalAuth.AuthResponse {
+			got = req
+			return &internalAuth.AuthResponse{Allowed: false, Reason: internalAuth.ReasonMissingToken}
+		},
+	}
+	client, err := NewClient("nats://localhost:4222", "", "", "$G", authHandler, zap.NewNop())
+	if err != nil {
+		t.Fatalf("Failed to create client: %v", err)
+	}
+	auditor := &recordingAuditor{}
+	client.AddAuditRecorder(auditor)
+
+	if _, err := client.authorize(req); err == nil {
+		t.Fatal("Expected a request without a token to be rejected")
+	}
+	if got == nil {
+		t.Fatal("Expected the auth handler to be called")
+	}
+	if got.Token != "" || got.ClientHost != "10.0.0.7" {
+		t.Errorf("AuthRequest = %+v, want an empty token from 10.0.0.7", got)
+	}
+	if len(auditor.events) != 1 || auditor.events[0].Reason != internalAuth.ReasonMissingToken {
+		t.Errorf("Audit events = %+v, want one missing_token denial", auditor.events)
+	}
+
+	// With a real handler, repeated requests without a token block the client IP
+	handler := internalAuth.NewHandler(nil, nil, zap.NewNop())
+	handler.SetRateLimiter(internalAuth.NewRateLimiter(internalAuth.RateLimits{
+		BlockAfterFailures: 2,
+		BlockDuration:      time.Minute,
+	}, zap.NewNop()))
+	client.authHandler = handler
+	auditor.events = nil
+
+	for i := 0; i < 3; i++ {
+		if _, err := client.authorize(req); err == nil {
+			t.Fatalf("Request %d: expected rejection", i+1)
+		}
+	}
+	wantReasons := []string{internalAuth.ReasonMissingToken, internalAuth.ReasonMissingToken, internalAuth.ReasonRateLimited}
+	if len(auditor.events) != len(wantReasons) {
+		t.Fatalf("Recorded %d events, want %d", len(auditor.events), len(wantReasons))
+	}
+	for i, want := range wantReasons {
+		if auditor.events[i].Reason != want {
+			t.Errorf("Event %d reason = %q, want %q", i+1, auditor.events[i].Reason, want)
+		}
+	}
+}
+
+// TestClient_AuthorizeDenialLogLevel tests that policy denials are logged at Info with their
+// reason, while routine token failures stay at Debug
+func TestClient_AuthorizeDenialLogLevel(t *testing.T) {
+	tests := []struct {
+		reason   string
+		wantInfo bool
+	}{
+		{reason: internalAuth.ReasonLockdown, wantInfo: true},
+		{reason: internalAuth.ReasonTokenRevoked, wantInfo: true},
+		{reason: internalAuth.ReasonRateLimited, wantInfo: true},
+		{reason: internalAuth.ReasonInvalidToken, wantInfo: false},
+		{reason: internalAuth.ReasonMissingToken, wantInfo: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.reason, func(t *testing.T) {
+			core, logs := observer.New(zap.InfoLevel)
+			authHandler := &mockAuthHandler{
+				authorizeFunc: func(req *internalAuth.AuthRequest) *internalAuth.AuthResponse {
+					return &internalAuth.AuthResponse{Allowed: false, Reason: tt.reason, Detail: "maintenance"}
+				},
+			}
+			client, err := NewClient("nats://localhost:4222", "", "", "$G", authHandler, zap.New(core))
+			if err != nil {
+				t.Fatalf("Failed to create client: %v", err)
+			}
+
+			if _, err := client.authorize(&jwt.AuthorizationRequest{}); err == nil {
+				t.Fatal("Expected the request to be rejected")
+			}
+
+			denials := logs.FilterMessage("auth request denied").All()
+			if !tt.wantInfo {
+				if len(denials) != 0 {
+					t.Errorf("Logged %d denials at Info, want none", len(denials))
+				}
+				return
+			}
+			if len(denials) != 1 {
+				t.Fatalf("Logged %d denials at Info, want 1", len(denials))
+			}
+			fields := denials[0].ContextMap()
+			if fields["reason"] != tt.reason || fields["detail"] != "maintenance" {
+				t.Errorf("Denial fields = %v, want reason %q and its detail", fields, tt.reason)
+			}
+		})
+	}
+}
+
+// TestClient_RecordAudit tests building audit events for allowed and denied decisions
+func TestClient_RecordAudit(t *testing.T) {
+	userKey, _ := nkeys.CreateUser()
+	userPubKey, _ := userKey.PublicKey()
+
+	auditor := &recordingAuditor{}
+	client := &Client{account: "APP", logger: zap.NewNop()}
+	client.AddAuditRecorder(auditor)
+
+	req := &jwt.AuthorizationRequest{}
+	req.Server.ID = "SERVER1"
+	req.ClientInformation.Host = "10.0.0.7"
+	req.ClientInformation.Kind = "Client"
+	req.ClientInformation.Type = "nats"
+
+	allowed := &internalAuth.AuthResponse{
+		Allowed:              true,
+		Namespace:            "shop",
+		ServiceAccount:       "api",
+		Pod:                  "api-1",
+		TokenID:              "jti-1",
+		PublishPermissions:   []string{"shop.>"},
+		SubscribePermissions: []string{"shop.>"},
+	}
+	client.recordAudit(req, "header.payload.signature", allowed, client.buildUserClaims(userPubKey, allowed))
+	client.recordAudit(req, "", &internalAuth.AuthResponse{
+		Namespace:      "shop",
+		ServiceAccount: "api",
+		Reason:         internalAuth.ReasonSourceNotAllowed,
+	}, nil)
+
+	if len(auditor.events) != 2 {
+		t.Fatalf("Recorded %d events, want 2", len(auditor.events))
+	}
+
+	got := auditor.events[0]
+	if got.Result != audit.ResultAllowed || got.Namespace != "shop" || got.ServiceAccount != "api" ||
+		got.Pod != "api-1" || got.TokenID != "jti-1" || got.ServerID != "SERVER1" ||
+		got.ClientIP != "10.0.0.7" || got.ConnectionType != jwt.ConnectionTypeStandard {
+		t.Errorf("Allowed event = %+v", got)
+	}
+	if !slices.Contains(got.PublishGranted, "shop.>") || !slices.Contains(got.SubscribeGranted, "shop.>") {
+		t.Errorf("Granted subjects = %v / %v, want shop.>", got.PublishGranted, got.SubscribeGranted)
+	}
+	if got.Token != "header.payload.signature" {
+		t.Errorf("Token = %q, want the presented token for sinks to redact", got.Token)
+	}
+	if got.Timestamp.IsZero() {
+		t.Error("Expected timestamp to be set")
+	}
+
+	got = auditor.events[1]
+	if got.Result != audit.ResultDenied || got.Reason != internalAuth.ReasonSourceNotAllowed || got.PublishGranted != nil {
+		t.Errorf("Denied event = %+v", got)
+	}
+}
+
+// TestClient_BuildUserClaimsAllowedTimes tests mapping allowed time windows to user claims
+func TestClient_BuildUserClaimsAllowedTimes(t *testing.T) {
+	userKey, _ := nkeys.CreateUser()
+	userPubKey, _ := userKey.PublicKey()
+
+	allowedTimes, err := k8s.ParseAllowedTimes("Mon-Fri 08:00-12:00,13:00-18:00 Europe/London")
+	if err != nil {
+		t.Fatalf("Failed to parse allowed times: %v", err)
+	}
+
+	client := &Client{account: "APP", logger: zap.NewNop()}
+	uc := client.buildUserClaims(userPubKey, &internalAuth.AuthResponse{
+		Allowed:      true,
+		AllowedTimes: allowedTimes,
+	})
+
+	wantTimes := []jwt.TimeRange{{Start: "08:00:00", End: "12:00:00"}, {Start: "13:00:00", End: "18:00:00"}}
+	if len(uc.Times) != len(wantTimes) || uc.Times[0] != wantTimes[0] || uc.Times[1] != wantTimes[1] {
+		t.Errorf("Times = %v, want %v", uc.Times, wantTimes)
+	}
+	if uc.Locale != "Europe/London" {
+		t.Errorf("Locale = %q, want %q", uc.Locale, "Europe/London")
+	}
+
+	vr := jwt.CreateValidationResults()
+	uc.Validate(vr)
+	if !vr.IsEmpty() {
+		t.Errorf("Expected valid user claims, got %v", vr.Errors())
+	}
+}
+
+// TestConnectionType tests mapping authorization request client information to connection types
+func TestConnectionType(t *testing.T) {
+	tests := []struct {
+		name string
+		ci   jwt.ClientInformation
+		want string
+	}{
+		{name: "Standard client", ci: jwt.ClientInformation{Kind: "Client", Type: "nats"}, want: jwt.ConnectionTypeStandard},
+		{name: "Websocket client", ci: jwt.ClientInformation{Kind: "Client", Type: "websocket"}, want: jwt.ConnectionTypeWebsocket},
+		{name: "MQTT client", ci: jwt.ClientInformation{Kind: "Client", Type: "mqtt"}, want: jwt.ConnectionTypeMqtt},
+		{name: "Leafnode", ci: jwt.ClientInformation{Kind: "Leafnode"}, want: jwt.ConnectionTypeLeafnode},
+		{name: "Unknown", ci: jwt.ClientInformation{}, want: ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := connectionType(tt.ci); got != tt.want {
+				t.Errorf("connectionType() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
 // Helper function to check if StringList contains a string
 func contains(list jwt.StringList, s string) bool {
 	for _, item := range list {
@@ -0,0 +1,155 @@
+package nats
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	natsclient "github.com/nats-io/nats.go"
+	"go.uber.org/zap"
+	"golang.org/x/time/rate"
+
+	httpmetrics "github.com/portswigger-tim/nats-k8s-oidc-callout/internal/httpserver"
+)
+
+const (
+	serverKickSubject = "$SYS.REQ.SERVER.%s.KICK"
+
+	// noSuchClientError is the server's kick error for a client that has already disconnected
+	noSuchClientError = "no such client or leafnode id"
+)
+
+// Disconnector disconnects existing clients through the NATS system account. Connections are
+// found through a ConnectionTracker when one is set, or else by listing the account's connections
+// and matching the tags the callout sets on every user it authorizes.
+type Disconnector struct {
+	conn    *natsclient.Conn
+	account string
+	timeout time.Duration
+	tracker *ConnectionTracker // nil lists connections through CONNZ
+	limiter *rate.Limiter      // nil kicks without limit
+	logger  *zap.Logger
+}
+
+// NewDisconnector creates a disconnector using a system account connection (see ConnectSystemAccount).
+// account is the account authorized clients are assigned to (the NATS_ACCOUNT setting).
+func NewDisconnector(conn *natsclient.Conn, account string, logger *zap.Logger) *Disconnector {
+	return &Disconnector{
+		conn:    conn,
+		account: account,
+		timeout: DefaultSystemRequestTimeout,
+		logger:  logger,
+	}
+}
+
+// SetConnectionTracker makes the disconnector kick tracked connections instead of listing them
+func (d *Disconnector) SetConnectionTracker(tracker *ConnectionTracker) {
+	d.tracker = tracker
+}
+
+// SetRateLimit limits kicks to perSecond across all ServiceAccounts, so a bulk change such as a
+// subject registry update does not disconnect every client at once.
+func (d *Disconnector) SetRateLimit(perSecond int) {
+	d.limiter = rate.NewLimiter(rate.Limit(perSecond), perSecond)
+}
+
+// DisconnectServiceAccount disconnects every client connected as a ServiceAccount.
+// Returns the number of connections disconnected. Tracked connections that could not be
+// kicked stay tracked, so the next disconnect retries them.
+func (d *Disconnector) DisconnectServiceAccount(namespace, name string) (int, error) {
+	conns, err := d.connections(namespace, name)
+	if err != nil {
+		return 0, err
+	}
+
+	var errs []error
+	disconnected := 0
+	for _, conn := range conns {
+		if d.limiter != nil {
+			if err := d.limiter.Wait(context.Background()); err != nil {
+				errs = append(errs, err)
+				continue
+			}
+		}
+		kicked, err := d.kick(conn)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		if d.tracker != nil {
+			d.tracker.Untrack(namespace, name, conn)
+		}
+		if kicked {
+			disconnected++
+		}
+	}
+
+	return disconnected, errors.Join(errs...)
+}
+
+// HandleRevoke disconnects a ServiceAccount's clients and logs the outcome. reason is one of the
+// k8s.Revoke* reasons. Suitable for k8s.Client.OnServiceAccountRevoked.
+func (d *Disconnector) HandleRevoke(namespace, name, reason string) {
+	disconnected, err := d.DisconnectServiceAccount(namespace, name)
+	httpmetrics.AddDisconnectedClients(reason, disconnected)
+	if err != nil {
+		d.logger.Error("failed to disconnect ServiceAccount clients",
+			zap.String("namespace", namespace),
+			zap.String("serviceaccount", name),
+			zap.String("reason", reason),
+			zap.Int("disconnected", disconnected),
+			zap.Error(err))
+		return
+	}
+
+	d.logger.Info("disconnected ServiceAccount clients",
+		zap.String("namespace", namespace),
+		zap.String("serviceaccount", name),
+		zap.String("reason", reason),
+		zap.Int("disconnected", disconnected))
+}
+
+// connections returns the ServiceAccount's tracked connections, or lists them from every server
+// when no tracker is set
+func (d *Disconnector) connections(namespace, name string) ([]connection, error) {
+	if d.tracker != nil {
+		return d.tracker.Connections(namespace, name), nil
+	}
+
+	responses, err := gatherConnz(d.conn, d.account, d.timeout)
+	if err != nil {
+		return nil, err
+	}
+	return serviceAccountConnections(responses, namespace, name)
+}
+
+// kick disconnects a single client connection. Returns false if the client had already
+// disconnected, which is common for tracked connections.
+func (d *Disconnector) kick(conn connection) (bool, error) {
+	request, err := json.Marshal(map[string]uint64{"cid": conn.CID})
+	if err != nil {
+		return false, err
+	}
+
+	msg, err := d.conn.Request(fmt.Sprintf(serverKickSubject, conn.ServerID), request, d.timeout)
+	if err != nil {
+		return false, fmt.Errorf("failed to kick client %d on server %s: %w", conn.CID, conn.ServerID, err)
+	}
+
+	var resp struct {
+		Error *apiError `json:"error"`
+	}
+	if err := json.Unmarshal(msg.Data, &resp); err != nil {
+		return false, fmt.Errorf("invalid kick response from server %s: %w", conn.ServerID, err)
+	}
+	if resp.Error != nil {
+		if resp.Error.Description == noSuchClientError {
+			return false, nil
+		}
+		return false, fmt.Errorf("failed to kick client %d on server %s: %s", conn.CID, conn.ServerID, resp.Error.Description)
+	}
+
+	return true, nil
+}
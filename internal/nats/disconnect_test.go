@@ -0,0 +1,187 @@
+package nats
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/nats-io/nats-server/v2/server"
+	natsclient "github.com/nats-io/nats.go"
+	"go.uber.org/zap"
+)
+
+const (
+	kickOK           = `{"data":{}}`
+	kickNoSuchClient = `{"error":{"code":404,"description":"no such client or leafnode id"}}`
+	kickFailed       = `{"error":{"code":500,"description":"internal error"}}`
+)
+
+// startTestServer starts an embedded NATS server and returns a connection to it
+func startTestServer(t *testing.T) *natsclient.Conn {
+	t.Helper()
+
+	srv, err := server.NewServer(&server.Options{Host: "127.0.0.1", Port: -1, NoLog: true, NoSigs: true})
+	if err != nil {
+		t.Fatalf("Failed to create NATS server: %v", err)
+	}
+	go srv.Start()
+	if !srv.ReadyForConnections(5 * time.Second) {
+		t.Fatal("NATS server did not start")
+	}
+	t.Cleanup(srv.Shutdown)
+
+	conn, err := natsclient.Connect(srv.ClientURL())
+	if err != nil {
+		t.Fatalf("Failed to connect to NATS server: %v", err)
+	}
+	t.Cleanup(conn.Close)
+
+	return conn
+}
+
+// respondToKicks answers a server's kick requests with the response for each client ID.
+// Requests for other client IDs get no reply.
+func respondToKicks(t *testing.T, conn *natsclient.Conn, serverID string, responses map[uint64]string) {
+	t.Helper()
+
+	_, err := conn.Subscribe("$SYS.REQ.SERVER."+serverID+".KICK", func(msg *natsclient.Msg) {
+		var request struct {
+			CID uint64 `json:"cid"`
+		}
+		if err := json.Unmarshal(msg.Data, &request); err != nil {
+			return
+		}
+		if response, ok := responses[request.CID]; ok {
+			_ = msg.Respond([]byte(response))
+		}
+	})
+	if err != nil {
+		t.Fatalf("Failed to subscribe to kick requests: %v", err)
+	}
+	if err := conn.Flush(); err != nil {
+		t.Fatalf("Failed to flush subscription: %v", err)
+	}
+}
+
+// TestDisconnector_Kick tests interpreting kick responses
+func TestDisconnector_Kick(t *testing.T) {
+	conn := startTestServer(t)
+	respondToKicks(t, conn, "SERVER1", map[uint64]string{
+		1: kickOK,
+		2: kickNoSuchClient,
+		3: kickFailed,
+		4: `not json`,
+	})
+
+	disconnector := NewDisconnector(conn, "APP", zap.NewNop())
+	disconnector.timeout = 200 * time.Millisecond
+
+	tests := []struct {
+		name       string
+		conn       connection
+		wantKicked bool
+		wantErr    string
+	}{
+		{name: "Kicked", conn: connection{ServerID: "SERVER1", CID: 1}, wantKicked: true},
+		{name: "Already disconnected", conn: connection{ServerID: "SERVER1", CID: 2}, wantKicked: false},
+		{name: "Server error", conn: connection{ServerID: "SERVER1", CID: 3}, wantErr: "internal error"},
+		{name: "Invalid response", conn: connection{ServerID: "SERVER1", CID: 4}, wantErr: "invalid kick response"},
+		{name: "No reply", conn: connection{ServerID: "SERVER1", CID: 5}, wantErr: "failed to kick client 5"},
+		{name: "Unknown server", conn: connection{ServerID: "SERVER2", CID: 1}, wantErr: "failed to kick client 1 on server SERVER2"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			kicked, err := disconnector.kick(tt.conn)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("kick() error = %v, want error containing %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("kick() error = %v", err)
+			}
+			if kicked != tt.wantKicked {
+				t.Errorf("kick() = %v, want %v", kicked, tt.wantKicked)
+			}
+		})
+	}
+}
+
+// TestDisconnector_DisconnectServiceAccount tests kicking tracked connections, joining errors
+// and keeping connections that could not be kicked tracked for a retry
+func TestDisconnector_DisconnectServiceAccount(t *testing.T) {
+	conn := startTestServer(t)
+	respondToKicks(t, conn, "SERVER1", map[uint64]string{
+		1: kickOK,
+		2: kickNoSuchClient,
+		3: kickFailed,
+	})
+
+	tracker := NewConnectionTracker()
+	for _, cid := range []uint64{1, 2, 3} {
+		tracker.Track("shop", "api", "SERVER1", cid)
+	}
+	tracker.Track("shop", "api", "SERVER2", 1)
+	tracker.Track("shop", "worker", "SERVER1", 1)
+
+	disconnector := NewDisconnector(conn, "APP", zap.NewNop())
+	disconnector.timeout = 200 * time.Millisecond
+	disconnector.SetConnectionTracker(tracker)
+
+	disconnected, err := disconnector.DisconnectServiceAccount("shop", "api")
+	if disconnected != 1 {
+		t.Errorf("disconnected = %d, want 1", disconnected)
+	}
+	if err == nil {
+		t.Fatal("Expected an error for the failed kicks")
+	}
+	for _, want := range []string{"client 3 on server SERVER1", "client 1 on server SERVER2"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("error %q does not mention %q", err, want)
+		}
+	}
+
+	remaining := tracker.Connections("shop", "api")
+	if len(remaining) != 2 {
+		t.Fatalf("tracked connections = %v, want the two failed kicks", remaining)
+	}
+	for _, conn := range remaining {
+		if conn != (connection{ServerID: "SERVER1", CID: 3}) && conn != (connection{ServerID: "SERVER2", CID: 1}) {
+			t.Errorf("unexpected tracked connection %v", conn)
+		}
+	}
+	if got := tracker.Connections("shop", "worker"); len(got) != 1 {
+		t.Errorf("other ServiceAccount's connections = %v, want them untouched", got)
+	}
+}
+
+// TestDisconnector_RateLimit tests spacing kicks out with the rate limiter
+func TestDisconnector_RateLimit(t *testing.T) {
+	conn := startTestServer(t)
+	respondToKicks(t, conn, "SERVER1", map[uint64]string{1: kickOK, 2: kickOK, 3: kickOK})
+
+	tracker := NewConnectionTracker()
+	for _, cid := range []uint64{1, 2, 3} {
+		tracker.Track("shop", "api", "SERVER1", cid)
+	}
+
+	disconnector := NewDisconnector(conn, "APP", zap.NewNop())
+	disconnector.SetConnectionTracker(tracker)
+	disconnector.SetRateLimit(2)
+
+	// The burst covers the first two kicks; the third waits for a token
+	start := time.Now()
+	disconnected, err := disconnector.DisconnectServiceAccount("shop", "api")
+	if err != nil {
+		t.Fatalf("DisconnectServiceAccount() error = %v", err)
+	}
+	if disconnected != 3 {
+		t.Errorf("disconnected = %d, want 3", disconnected)
+	}
+	if elapsed := time.Since(start); elapsed < 400*time.Millisecond {
+		t.Errorf("kicks took %v, want the third to wait for the rate limit", elapsed)
+	}
+}
@@ -0,0 +1,295 @@
+package nats
+
+import (
+	"cmp"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/nats-io/jwt/v2"
+	natsclient "github.com/nats-io/nats.go"
+	"go.uber.org/zap"
+
+	httpmetrics "github.com/portswigger-tim/nats-k8s-oidc-callout/internal/httpserver"
+)
+
+const (
+	accountConnectSubject    = "$SYS.ACCOUNT.%s.CONNECT"
+	accountDisconnectSubject = "$SYS.ACCOUNT.%s.DISCONNECT"
+)
+
+// ConnectionInfo describes a live client connection authorized by this callout
+type ConnectionInfo struct {
+	Namespace      string    `json:"namespace"`
+	ServiceAccount string    `json:"serviceaccount"`
+	Pod            string    `json:"pod,omitempty"`
+	ServerID       string    `json:"server_id"`
+	ServerName     string    `json:"server_name,omitempty"`
+	ClientID       uint64    `json:"client_id"`
+	Host           string    `json:"host,omitempty"`
+	Name           string    `json:"name,omitempty"` // Connection name set by the client
+	ConnectionType string    `json:"connection_type,omitempty"`
+	ConnectedAt    time.Time `json:"connected_at"`
+}
+
+// InventoryResponse is the JSON body served by the inventory endpoint
+type InventoryResponse struct {
+	Count       int              `json:"count"`
+	Connections []ConnectionInfo `json:"connections"`
+}
+
+// connectionEvent is the subset of the server's client connect and disconnect advisories used
+// by the inventory
+type connectionEvent struct {
+	Server struct {
+		ID   string `json:"id"`
+		Name string `json:"name"`
+	} `json:"server"`
+	Client struct {
+		ID         uint64    `json:"id"`
+		Host       string    `json:"host"`
+		Name       string    `json:"name"`
+		Kind       string    `json:"kind"`
+		ClientType string    `json:"client_type"`
+		Start      time.Time `json:"start"`
+		Tags       []string  `json:"tags"`
+	} `json:"client"`
+}
+
+// Inventory keeps the live connections authorized by this callout, built from the system
+// account's CONNECT and DISCONNECT advisories and correlated through the identity tags set on
+// every issued user.
+type Inventory struct {
+	mu     sync.RWMutex
+	conns  map[connection]ConnectionInfo
+	counts map[string]int // key: "namespace/name"
+	// departed records connections reported disconnected while seeding, so a CONNZ listing taken
+	// before their DISCONNECT advisory does not add them back. nil once seeding is done.
+	departed map[connection]struct{}
+	subs     []*natsclient.Subscription
+	logger   *zap.Logger
+}
+
+// NewInventory creates an empty connection inventory
+func NewInventory(logger *zap.Logger) *Inventory {
+	return &Inventory{
+		conns:  make(map[connection]ConnectionInfo),
+		counts: make(map[string]int),
+		logger: logger,
+	}
+}
+
+// Start subscribes to the account's connection advisories using a system account connection,
+// then seeds the inventory with the connections that already exist.
+func (i *Inventory) Start(conn *natsclient.Conn, account string) error {
+	i.mu.Lock()
+	i.departed = make(map[connection]struct{})
+	i.mu.Unlock()
+	defer i.finishSeeding()
+
+	for subject, handler := range map[string]natsclient.MsgHandler{
+		fmt.Sprintf(accountConnectSubject, account):    i.handleConnect,
+		fmt.Sprintf(accountDisconnectSubject, account): i.handleDisconnect,
+	} {
+		sub, err := conn.Subscribe(subject, handler)
+		if err != nil {
+			i.Stop()
+			return fmt.Errorf("failed to subscribe to %s: %w", subject, err)
+		}
+		i.subs = append(i.subs, sub)
+	}
+
+	// Subscribed first so connections made while seeding are not missed
+	responses, err := gatherConnz(conn, account, DefaultSystemRequestTimeout)
+	if err != nil {
+		return fmt.Errorf("failed to list existing connections: %w", err)
+	}
+	if err := i.seed(responses); err != nil {
+		return err
+	}
+
+	i.logger.Info("connection inventory started",
+		zap.String("account", account),
+		zap.Int("connections", i.Len()))
+	return nil
+}
+
+// Stop unsubscribes from the connection advisories
+func (i *Inventory) Stop() {
+	for _, sub := range i.subs {
+		_ = sub.Unsubscribe()
+	}
+	i.subs = nil
+}
+
+// finishSeeding stops recording departed connections
+func (i *Inventory) finishSeeding() {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	i.departed = nil
+}
+
+// seed adds connections from CONNZ responses that the advisories have not already reported,
+// skipping connections whose DISCONNECT advisory arrived first
+func (i *Inventory) seed(responses [][]byte) error {
+	parsed, err := parseConnz(responses)
+	if err != nil {
+		return err
+	}
+
+	for _, resp := range parsed {
+		for _, c := range resp.Data.Conns {
+			namespace, serviceAccount, pod, ok := identityFromTags(c.Tags)
+			if !ok {
+				continue
+			}
+			i.add(ConnectionInfo{
+				Namespace:      namespace,
+				ServiceAccount: serviceAccount,
+				Pod:            pod,
+				ServerID:       resp.Server.ID,
+				ServerName:     resp.Server.Name,
+				ClientID:       c.CID,
+				Host:           c.IP,
+				Name:           c.Name,
+				ConnectionType: connectionType(jwt.ClientInformation{Kind: c.Kind, Type: c.Type}),
+				ConnectedAt:    c.Start,
+			})
+		}
+	}
+	return nil
+}
+
+// handleConnect adds a connection reported by a CONNECT advisory
+func (i *Inventory) handleConnect(msg *natsclient.Msg) {
+	var event connectionEvent
+	if err := json.Unmarshal(msg.Data, &event); err != nil {
+		i.logger.Warn("invalid connect advisory", zap.Error(err))
+		return
+	}
+
+	namespace, serviceAccount, pod, ok := identityFromTags(event.Client.Tags)
+	if !ok {
+		return
+	}
+	i.add(ConnectionInfo{
+		Namespace:      namespace,
+		ServiceAccount: serviceAccount,
+		Pod:            pod,
+		ServerID:       event.Server.ID,
+		ServerName:     event.Server.Name,
+		ClientID:       event.Client.ID,
+		Host:           event.Client.Host,
+		Name:           event.Client.Name,
+		ConnectionType: connectionType(jwt.ClientInformation{Kind: event.Client.Kind, Type: event.Client.ClientType}),
+		ConnectedAt:    event.Client.Start,
+	})
+}
+
+// handleDisconnect removes a connection reported by a DISCONNECT advisory
+func (i *Inventory) handleDisconnect(msg *natsclient.Msg) {
+	var event connectionEvent
+	if err := json.Unmarshal(msg.Data, &event); err != nil {
+		i.logger.Warn("invalid disconnect advisory", zap.Error(err))
+		return
+	}
+
+	i.remove(connection{ServerID: event.Server.ID, CID: event.Client.ID})
+}
+
+// add records a connection and updates its ServiceAccount's gauge. Connections already reported
+// disconnected while seeding are ignored; client IDs are not reused by a server.
+func (i *Inventory) add(info ConnectionInfo) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	key := connection{ServerID: info.ServerID, CID: info.ClientID}
+	if _, exists := i.conns[key]; exists {
+		return
+	}
+	if _, departed := i.departed[key]; departed {
+		return
+	}
+	i.conns[key] = info
+
+	saKey := info.Namespace + "/" + info.ServiceAccount
+	i.counts[saKey]++
+	httpmetrics.SetServiceAccountConnections(info.Namespace, info.ServiceAccount, i.counts[saKey])
+}
+
+// remove forgets a connection and updates its ServiceAccount's gauge
+func (i *Inventory) remove(key connection) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	if i.departed != nil {
+		i.departed[key] = struct{}{}
+	}
+
+	info, exists := i.conns[key]
+	if !exists {
+		return
+	}
+	delete(i.conns, key)
+
+	saKey := info.Namespace + "/" + info.ServiceAccount
+	i.counts[saKey]--
+	if i.counts[saKey] <= 0 {
+		delete(i.counts, saKey)
+		httpmetrics.DeleteServiceAccountConnections(info.Namespace, info.ServiceAccount)
+		return
+	}
+	httpmetrics.SetServiceAccountConnections(info.Namespace, info.ServiceAccount, i.counts[saKey])
+}
+
+// Len returns the number of live connections
+func (i *Inventory) Len() int {
+	i.mu.RLock()
+	defer i.mu.RUnlock()
+
+	return len(i.conns)
+}
+
+// Connections returns the live connections, optionally filtered by namespace and ServiceAccount
+// (empty matches all), sorted by namespace, ServiceAccount, pod and connection time.
+func (i *Inventory) Connections(namespace, serviceAccount string) []ConnectionInfo {
+	i.mu.RLock()
+	conns := make([]ConnectionInfo, 0, len(i.conns))
+	for _, info := range i.conns {
+		if (namespace == "" || info.Namespace == namespace) && (serviceAccount == "" || info.ServiceAccount == serviceAccount) {
+			conns = append(conns, info)
+		}
+	}
+	i.mu.RUnlock()
+
+	slices.SortFunc(conns, func(a, b ConnectionInfo) int {
+		return cmp.Or(
+			cmp.Compare(a.Namespace, b.Namespace),
+			cmp.Compare(a.ServiceAccount, b.ServiceAccount),
+			cmp.Compare(a.Pod, b.Pod),
+			a.ConnectedAt.Compare(b.ConnectedAt),
+		)
+	})
+	return conns
+}
+
+// ServeHTTP serves the inventory as JSON. The namespace and serviceaccount query parameters
+// filter the connections.
+func (i *Inventory) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.Header().Set("Allow", http.MethodGet)
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	conns := i.Connections(r.URL.Query().Get("namespace"), r.URL.Query().Get("serviceaccount"))
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(InventoryResponse{Count: len(conns), Connections: conns}); err != nil {
+		i.logger.Error("failed to encode connection inventory", zap.Error(err))
+	}
+}
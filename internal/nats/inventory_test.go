@@ -0,0 +1,162 @@
+package nats
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	natsclient "github.com/nats-io/nats.go"
+	"go.uber.org/zap"
+)
+
+// connectAdvisory builds a connect or disconnect advisory for a client
+func connectAdvisory(serverID string, cid uint64, start string, tags ...string) *natsclient.Msg {
+	data, _ := json.Marshal(map[string]any{
+		"server": map[string]any{"id": serverID, "name": "nats-0"},
+		"client": map[string]any{
+			"id":          cid,
+			"host":        "10.0.0.1",
+			"kind":        "Client",
+			"client_type": "nats",
+			"start":       start,
+			"tags":        tags,
+		},
+	})
+	return &natsclient.Msg{Data: data}
+}
+
+// TestInventory_Advisories tests tracking connections from connect and disconnect advisories
+func TestInventory_Advisories(t *testing.T) {
+	inv := NewInventory(zap.NewNop())
+
+	inv.handleConnect(connectAdvisory("SERVER1", 1, "2026-07-01T12:00:00Z",
+		"nats.io/namespace:shop", "nats.io/serviceaccount:api", "nats.io/pod:api-1"))
+	inv.handleConnect(connectAdvisory("SERVER1", 2, "2026-07-01T12:01:00Z",
+		"nats.io/namespace:shop", "nats.io/serviceaccount:worker"))
+	// Clients authorized by someone else are ignored
+	inv.handleConnect(connectAdvisory("SERVER1", 3, "2026-07-01T12:02:00Z"))
+	// Duplicate advisories are not counted twice
+	inv.handleConnect(connectAdvisory("SERVER1", 1, "2026-07-01T12:00:00Z",
+		"nats.io/namespace:shop", "nats.io/serviceaccount:api", "nats.io/pod:api-1"))
+	inv.handleConnect(&natsclient.Msg{Data: []byte("not json")})
+
+	if got := inv.Len(); got != 2 {
+		t.Fatalf("Len() = %d, want 2", got)
+	}
+
+	conns := inv.Connections("shop", "api")
+	if len(conns) != 1 {
+		t.Fatalf("Connections(shop, api) = %v, want 1 connection", conns)
+	}
+	if conns[0].Pod != "api-1" || conns[0].ServerID != "SERVER1" || conns[0].ClientID != 1 || conns[0].ConnectionType != "STANDARD" {
+		t.Errorf("Connection = %+v", conns[0])
+	}
+
+	inv.handleDisconnect(connectAdvisory("SERVER1", 1, "2026-07-01T12:00:00Z"))
+	inv.handleDisconnect(connectAdvisory("SERVER2", 2, "2026-07-01T12:01:00Z"))
+
+	if got := inv.Len(); got != 1 {
+		t.Fatalf("Len() after disconnect = %d, want 1", got)
+	}
+	if conns := inv.Connections("shop", "api"); len(conns) != 0 {
+		t.Errorf("Connections(shop, api) after disconnect = %v, want none", conns)
+	}
+}
+
+// TestInventory_Seed tests loading existing connections from CONNZ responses
+func TestInventory_Seed(t *testing.T) {
+	inv := NewInventory(zap.NewNop())
+	inv.handleConnect(connectAdvisory("SERVER1", 4, "2026-07-01T12:00:00Z",
+		"nats.io/namespace:shop", "nats.io/serviceaccount:api"))
+
+	err := inv.seed([][]byte{
+		[]byte(`{"server":{"id":"SERVER1","name":"nats-0"},"data":{"connections":[
+			{"cid":4,"ip":"10.0.0.1","start":"2026-07-01T12:00:00Z","tags":["nats.io/namespace:shop","nats.io/serviceaccount:api"]},
+			{"cid":5,"ip":"10.0.0.2","start":"2026-07-01T11:00:00Z","tags":["nats.io/namespace:shop","nats.io/serviceaccount:api"]},
+			{"cid":6}
+		]}}`),
+		[]byte(`{"server":{"id":"SERVER2","name":"nats-1"},"data":{"connections":[
+			{"cid":4,"ip":"10.0.0.3","start":"2026-07-01T10:00:00Z","tags":["nats.io/namespace:billing","nats.io/serviceaccount:api"]}
+		]}}`),
+	})
+	if err != nil {
+		t.Fatalf("seed() error = %v", err)
+	}
+
+	conns := inv.Connections("", "")
+	if len(conns) != 3 {
+		t.Fatalf("Connections() = %v, want 3", conns)
+	}
+	// Sorted by namespace, then connection time
+	if conns[0].Namespace != "billing" || conns[1].ClientID != 5 || conns[2].ClientID != 4 {
+		t.Errorf("Connections() order = %+v", conns)
+	}
+	if conns := inv.Connections("", "api"); len(conns) != 3 {
+		t.Errorf("Connections(\"\", api) = %d connections, want 3", len(conns))
+	}
+
+	if err := inv.seed([][]byte{[]byte(`{"server":{"id":"SERVER3"},"error":{"code":500,"description":"boom"}}`)}); err == nil {
+		t.Error("Expected error for failed CONNZ response")
+	}
+}
+
+// TestInventory_SeedAfterDisconnect tests that a CONNZ listing taken before a DISCONNECT advisory
+// does not add the departed connection back
+func TestInventory_SeedAfterDisconnect(t *testing.T) {
+	inv := NewInventory(zap.NewNop())
+	inv.departed = make(map[connection]struct{}) // as set by Start while seeding
+
+	inv.handleDisconnect(connectAdvisory("SERVER1", 5, "2026-07-01T11:00:00Z"))
+
+	err := inv.seed([][]byte{
+		[]byte(`{"server":{"id":"SERVER1","name":"nats-0"},"data":{"connections":[
+			{"cid":5,"ip":"10.0.0.2","start":"2026-07-01T11:00:00Z","tags":["nats.io/namespace:shop","nats.io/serviceaccount:api"]},
+			{"cid":6,"ip":"10.0.0.3","start":"2026-07-01T11:30:00Z","tags":["nats.io/namespace:shop","nats.io/serviceaccount:api"]}
+		]}}`),
+	})
+	if err != nil {
+		t.Fatalf("seed() error = %v", err)
+	}
+	inv.finishSeeding()
+
+	conns := inv.Connections("", "")
+	if len(conns) != 1 || conns[0].ClientID != 6 {
+		t.Errorf("Connections() = %+v, want only client 6", conns)
+	}
+	if inv.departed != nil {
+		t.Error("Expected departed connections to be dropped after seeding")
+	}
+}
+
+// TestInventory_ServeHTTP tests serving the inventory as JSON
+func TestInventory_ServeHTTP(t *testing.T) {
+	inv := NewInventory(zap.NewNop())
+	inv.handleConnect(connectAdvisory("SERVER1", 1, "2026-07-01T12:00:00Z",
+		"nats.io/namespace:shop", "nats.io/serviceaccount:api"))
+	inv.handleConnect(connectAdvisory("SERVER1", 2, "2026-07-01T12:00:00Z",
+		"nats.io/namespace:billing", "nats.io/serviceaccount:api"))
+
+	rec := httptest.NewRecorder()
+	inv.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/connections?namespace=shop", nil))
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Status = %d, want %d", rec.Code, http.StatusOK)
+	}
+	if got := rec.Header().Get("Content-Type"); got != "application/json" {
+		t.Errorf("Content-Type = %q, want application/json", got)
+	}
+	var resp InventoryResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("Invalid response body: %v", err)
+	}
+	if resp.Count != 1 || len(resp.Connections) != 1 || resp.Connections[0].Namespace != "shop" {
+		t.Errorf("Response = %+v", resp)
+	}
+
+	rec = httptest.NewRecorder()
+	inv.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/connections", nil))
+	if rec.Code != http.StatusMethodNotAllowed {
+		t.Errorf("POST status = %d, want %d", rec.Code, http.StatusMethodNotAllowed)
+	}
+}
@@ -0,0 +1,183 @@
+package nats
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	natsclient "github.com/nats-io/nats.go"
+)
+
+const (
+	// TagNamespace and TagServiceAccount prefix the user claim tags identifying the
+	// ServiceAccount a client authenticated as, e.g. "nats.io/namespace:shop"
+	TagNamespace      = "nats.io/namespace"
+	TagServiceAccount = "nats.io/serviceaccount"
+	// TagPod identifies the pod bound to the client's token, when there is one
+	TagPod = "nats.io/pod"
+
+	// DefaultSystemRequestTimeout bounds system account requests, and how long connection
+	// listings are gathered from servers
+	DefaultSystemRequestTimeout = 2 * time.Second
+
+	// connzLimit is the maximum number of connections requested from each server
+	connzLimit = 10000
+
+	accountConnzSubject = "$SYS.REQ.ACCOUNT.%s.CONNZ"
+)
+
+// ConnectSystemAccount connects to NATS with system account credentials, used to list and
+// disconnect clients and to receive connection events.
+func ConnectSystemAccount(natsURL, systemCredsFile string) (*natsclient.Conn, error) {
+	if cleanPath := filepath.Clean(systemCredsFile); cleanPath != systemCredsFile {
+		return nil, fmt.Errorf("invalid system credentials file path: potential path traversal attempt")
+	}
+
+	// Credentials embedded in the URL belong to the callout user, not the system account
+	parsedURL, err := url.Parse(natsURL)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse NATS URL: %w", err)
+	}
+	parsedURL.User = nil
+
+	conn, err := natsclient.Connect(parsedURL.String(),
+		natsclient.Timeout(5*time.Second),
+		natsclient.Name("nats-k8s-oidc-callout-system"),
+		natsclient.UserCredentials(systemCredsFile),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to NATS with system credentials: %w", err)
+	}
+
+	return conn, nil
+}
+
+// serviceAccountTags returns the user claim tags identifying a ServiceAccount
+func serviceAccountTags(namespace, name string) []string {
+	return []string{TagNamespace + ":" + namespace, TagServiceAccount + ":" + name}
+}
+
+// identityFromTags extracts the namespace, ServiceAccount and pod from a client's user tags.
+// ok is false for clients this callout did not authorize.
+func identityFromTags(tags []string) (namespace, serviceAccount, pod string, ok bool) {
+	for _, tag := range tags {
+		key, value, found := strings.Cut(tag, ":")
+		if !found {
+			continue
+		}
+		switch key {
+		case TagNamespace:
+			namespace = value
+		case TagServiceAccount:
+			serviceAccount = value
+		case TagPod:
+			pod = value
+		}
+	}
+	return namespace, serviceAccount, pod, namespace != "" && serviceAccount != ""
+}
+
+// connection identifies a client connection on a server
+type connection struct {
+	ServerID string
+	CID      uint64
+}
+
+// apiError is the error in a system account API response
+type apiError struct {
+	Code        int    `json:"code"`
+	Description string `json:"description"`
+}
+
+// connzResponse is the subset of a server's CONNZ response used to find connections
+type connzResponse struct {
+	Server struct {
+		ID   string `json:"id"`
+		Name string `json:"name"`
+	} `json:"server"`
+	Data struct {
+		Conns []struct {
+			CID   uint64    `json:"cid"`
+			Kind  string    `json:"kind"`
+			IP    string    `json:"ip"`
+			Name  string    `json:"name"`
+			Type  string    `json:"type"`
+			Start time.Time `json:"start"`
+			Tags  []string  `json:"tags"`
+		} `json:"connections"`
+	} `json:"data"`
+	Error *apiError `json:"error"`
+}
+
+// gatherConnz requests an account's connections from every server. Servers reply
+// independently, so responses are collected until the timeout passes.
+func gatherConnz(conn *natsclient.Conn, account string, timeout time.Duration) ([][]byte, error) {
+	inbox := conn.NewRespInbox()
+	sub, err := conn.SubscribeSync(inbox)
+	if err != nil {
+		return nil, fmt.Errorf("failed to subscribe for connection listings: %w", err)
+	}
+	defer func() { _ = sub.Unsubscribe() }()
+
+	request, err := json.Marshal(map[string]any{"auth": true, "limit": connzLimit})
+	if err != nil {
+		return nil, err
+	}
+	if err := conn.PublishRequest(fmt.Sprintf(accountConnzSubject, account), inbox, request); err != nil {
+		return nil, fmt.Errorf("failed to request connection listings: %w", err)
+	}
+
+	var responses [][]byte
+	deadline := time.Now().Add(timeout)
+	for {
+		msg, err := sub.NextMsg(time.Until(deadline))
+		if errors.Is(err, natsclient.ErrTimeout) {
+			return responses, nil
+		}
+		if err != nil {
+			return responses, fmt.Errorf("failed to receive connection listings: %w", err)
+		}
+		responses = append(responses, msg.Data)
+	}
+}
+
+// parseConnz decodes CONNZ responses, failing on the first server error
+func parseConnz(responses [][]byte) ([]connzResponse, error) {
+	parsed := make([]connzResponse, 0, len(responses))
+	for _, data := range responses {
+		var resp connzResponse
+		if err := json.Unmarshal(data, &resp); err != nil {
+			return nil, fmt.Errorf("invalid connection listing: %w", err)
+		}
+		if resp.Error != nil {
+			return nil, fmt.Errorf("connection listing from server %s failed: %s", resp.Server.ID, resp.Error.Description)
+		}
+		parsed = append(parsed, resp)
+	}
+	return parsed, nil
+}
+
+// serviceAccountConnections finds the connections tagged with a ServiceAccount in CONNZ responses
+func serviceAccountConnections(responses [][]byte, namespace, name string) ([]connection, error) {
+	parsed, err := parseConnz(responses)
+	if err != nil {
+		return nil, err
+	}
+
+	tags := serviceAccountTags(namespace, name)
+	var conns []connection
+	for _, resp := range parsed {
+		for _, c := range resp.Data.Conns {
+			if slices.Contains(c.Tags, tags[0]) && slices.Contains(c.Tags, tags[1]) {
+				conns = append(conns, connection{ServerID: resp.Server.ID, CID: c.CID})
+			}
+		}
+	}
+
+	return conns, nil
+}
@@ -0,0 +1,81 @@
+package nats
+
+import (
+	"testing"
+)
+
+// TestServiceAccountConnections tests finding a ServiceAccount's connections in CONNZ responses
+func TestServiceAccountConnections(t *testing.T) {
+	responses := [][]byte{
+		[]byte(`{"server":{"id":"SERVER1"},"data":{"connections":[
+			{"cid":4,"tags":["nats.io/namespace:shop","nats.io/serviceaccount:api"]},
+			{"cid":5,"tags":["nats.io/namespace:shop","nats.io/serviceaccount:worker"]},
+			{"cid":6}
+		]}}`),
+		[]byte(`{"server":{"id":"SERVER2"},"data":{"connections":[
+			{"cid":4,"tags":["nats.io/namespace:billing","nats.io/serviceaccount:api"]},
+			{"cid":9,"tags":["nats.io/serviceaccount:api","nats.io/namespace:shop"]}
+		]}}`),
+	}
+
+	conns, err := serviceAccountConnections(responses, "shop", "api")
+	if err != nil {
+		t.Fatalf("serviceAccountConnections() error = %v", err)
+	}
+
+	want := []connection{{ServerID: "SERVER1", CID: 4}, {ServerID: "SERVER2", CID: 9}}
+	if len(conns) != len(want) {
+		t.Fatalf("connections = %v, want %v", conns, want)
+	}
+	for i := range want {
+		if conns[i] != want[i] {
+			t.Errorf("connection %d = %v, want %v", i, conns[i], want[i])
+		}
+	}
+}
+
+// TestServiceAccountConnectionsErrors tests rejecting invalid and failed CONNZ responses
+func TestServiceAccountConnectionsErrors(t *testing.T) {
+	tests := []struct {
+		name     string
+		response string
+	}{
+		{name: "Invalid JSON", response: `{"server":`},
+		{name: "Server error", response: `{"server":{"id":"SERVER1"},"error":{"code":403,"description":"not allowed"}}`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := serviceAccountConnections([][]byte{[]byte(tt.response)}, "shop", "api"); err == nil {
+				t.Error("Expected an error")
+			}
+		})
+	}
+}
+
+// TestIdentityFromTags tests extracting the issued identity from user tags
+func TestIdentityFromTags(t *testing.T) {
+	tests := []struct {
+		name    string
+		tags    []string
+		wantNS  string
+		wantSA  string
+		wantPod string
+		wantOK  bool
+	}{
+		{name: "Pod-bound token", tags: []string{"nats.io/namespace:shop", "nats.io/serviceaccount:api", "nats.io/pod:api-1"}, wantNS: "shop", wantSA: "api", wantPod: "api-1", wantOK: true},
+		{name: "Token without pod", tags: []string{"nats.io/serviceaccount:api", "nats.io/namespace:shop"}, wantNS: "shop", wantSA: "api", wantOK: true},
+		{name: "Other tags only", tags: []string{"team:payments", "legacy"}, wantOK: false},
+		{name: "Missing serviceaccount", tags: []string{"nats.io/namespace:shop"}, wantNS: "shop", wantOK: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			namespace, serviceAccount, pod, ok := identityFromTags(tt.tags)
+			if namespace != tt.wantNS || serviceAccount != tt.wantSA || pod != tt.wantPod || ok != tt.wantOK {
+				t.Errorf("identityFromTags() = %q, %q, %q, %v; want %q, %q, %q, %v",
+					namespace, serviceAccount, pod, ok, tt.wantNS, tt.wantSA, tt.wantPod, tt.wantOK)
+			}
+		})
+	}
+}
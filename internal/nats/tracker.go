@@ -0,0 +1,101 @@
+package nats
+
+import (
+	"sync"
+	"time"
+)
+
+// ConnectionTracker records the client and server IDs of the connections authorized for each
+// ServiceAccount, so they can be disconnected without listing every server's connections.
+// Entries are dropped once the user JWT issued for them has expired, since the server
+// disconnects the client at that point.
+type ConnectionTracker struct {
+	mu        sync.Mutex
+	conns     map[string]map[connection]time.Time // key: "namespace/name", value: time authorized
+	ttl       time.Duration
+	lastPrune time.Time
+	now       func() time.Time
+}
+
+// NewConnectionTracker creates an empty tracker whose entries expire with the issued user JWTs
+func NewConnectionTracker() *ConnectionTracker {
+	return &ConnectionTracker{
+		conns: make(map[string]map[connection]time.Time),
+		ttl:   DefaultTokenExpiry,
+		now:   time.Now,
+	}
+}
+
+// Track records a connection authorized for a ServiceAccount
+func (t *ConnectionTracker) Track(namespace, name, serverID string, clientID uint64) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	now := t.now()
+	if now.Sub(t.lastPrune) > t.ttl {
+		t.prune(now)
+	}
+
+	key := namespace + "/" + name
+	if t.conns[key] == nil {
+		t.conns[key] = make(map[connection]time.Time)
+	}
+	t.conns[key][connection{ServerID: serverID, CID: clientID}] = now
+}
+
+// Connections returns the unexpired connections tracked for a ServiceAccount. They stay tracked
+// until they are untracked or expire, so a failed disconnect can be retried.
+func (t *ConnectionTracker) Connections(namespace, name string) []connection {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	cutoff := t.now().Add(-t.ttl)
+	var conns []connection
+	for conn, authorized := range t.conns[namespace+"/"+name] {
+		if authorized.After(cutoff) {
+			conns = append(conns, conn)
+		}
+	}
+
+	return conns
+}
+
+// Untrack removes a connection once it has been disconnected
+func (t *ConnectionTracker) Untrack(namespace, name string, conn connection) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	key := namespace + "/" + name
+	delete(t.conns[key], conn)
+	if len(t.conns[key]) == 0 {
+		delete(t.conns, key)
+	}
+}
+
+// Len returns the number of tracked connections, including expired ones not yet pruned
+func (t *ConnectionTracker) Len() int {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	total := 0
+	for _, conns := range t.conns {
+		total += len(conns)
+	}
+	return total
+}
+
+// prune drops expired connections. The caller must hold the lock.
+func (t *ConnectionTracker) prune(now time.Time) {
+	cutoff := now.Add(-t.ttl)
+	for key, conns := range t.conns {
+		for conn, authorized := range conns {
+			if !authorized.After(cutoff) {
+				delete(conns, conn)
+			}
+		}
+		if len(conns) == 0 {
+			delete(t.conns, key)
+		}
+	}
+	t.lastPrune = now
+}
@@ -0,0 +1,41 @@
+package nats
+
+import (
+	"testing"
+	"time"
+)
+
+// TestConnectionTracker tests tracking, untracking and expiring authorized connections
+func TestConnectionTracker(t *testing.T) {
+	now := time.Date(2026, 7, 1, 12, 0, 0, 0, time.UTC)
+	tracker := NewConnectionTracker()
+	tracker.now = func() time.Time { return now }
+
+	tracker.Track("shop", "api", "SERVER1", 4)
+	tracker.Track("shop", "api", "SERVER2", 4)
+	tracker.Track("shop", "worker", "SERVER1", 5)
+
+	now = now.Add(DefaultTokenExpiry - time.Minute)
+	tracker.Track("shop", "api", "SERVER1", 7)
+
+	// The first two connections' user JWTs have expired by now
+	now = now.Add(2 * time.Minute)
+
+	conns := tracker.Connections("shop", "api")
+	if len(conns) != 1 || conns[0] != (connection{ServerID: "SERVER1", CID: 7}) {
+		t.Errorf("Connections(shop, api) = %v, want [{SERVER1 7}]", conns)
+	}
+	if conns := tracker.Connections("shop", "api"); len(conns) != 1 {
+		t.Errorf("Connections(shop, api) again = %v, want the connection to stay tracked", conns)
+	}
+	tracker.Untrack("shop", "api", connection{ServerID: "SERVER1", CID: 7})
+	if conns := tracker.Connections("shop", "api"); len(conns) != 0 {
+		t.Errorf("Connections(shop, api) after untrack = %v, want none", conns)
+	}
+
+	// Tracking prunes expired connections of other ServiceAccounts
+	tracker.Track("billing", "api", "SERVER1", 8)
+	if got := tracker.Len(); got != 1 {
+		t.Errorf("Len() = %d, want 1", got)
+	}
+}